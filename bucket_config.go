@@ -0,0 +1,83 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"io/fs"
+)
+
+// BucketConfigFilesystemProvider is a BucketFilesystemProvider that can also store the configuration of each bucket,
+// such as the bucket policy.
+type BucketConfigFilesystemProvider interface {
+	BucketFilesystemProvider
+
+	// OpenBucketConfig returns a writable filesystem for the configuration files at the root of the given bucket.
+	// The error MethodNotAllowed is returned if the configuration of the bucket cannot be changed.
+	OpenBucketConfig(bucket string) (WritableFS, error)
+}
+
+// errBucketConfigNotWritable is returned by a BucketConfigFilesystemProvider for a bucket that cannot be configured.
+var errBucketConfigNotWritable = &exception.Error{
+	ErrorCode: exception.MethodNotAllowed,
+	Message:   "The configuration of the specified bucket cannot be changed.",
+}
+
+// readBucketConfig reads the JSON configuration file name at the root of the bucket filesystem.
+// It returns nil if the configuration file does not exist.
+func readBucketConfig(fsys fs.FS, name string) ([]byte, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, unwrapFsError(err)
+	}
+
+	return b, nil
+}
+
+// writeBucketConfig atomically replaces the JSON configuration file name with v.
+// If v is nil, the configuration file is removed.
+func writeBucketConfig(wfs WritableFS, name string, v any) error {
+	if v == nil {
+		err := wfs.Remove(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writeAtomic(wfs, name, bytes.NewReader(b), nil)
+	return err
+}
+
+// openBucketConfig returns the writable configuration filesystem of the bucket in the request.
+// The configuration of a bucket can only be changed if the bucket is writable, or if the server manages buckets,
+// so that a read-only server never writes to the filesystem.
+func (s *Server) openBucketConfig(ctx *RequestContext) (WritableFS, *exception.Error) {
+	if !s.manageBuckets {
+		if _, werr := s.openWritable(ctx); werr != nil {
+			return nil, errBucketConfigNotWritable
+		}
+	}
+
+	provider, ok := s.filesystemProvider.(BucketConfigFilesystemProvider)
+	if !ok {
+		return nil, errBucketConfigNotWritable
+	}
+
+	wfs, err := provider.OpenBucketConfig(ctx.Bucket)
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	return wfs, nil
+}
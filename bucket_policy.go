@@ -0,0 +1,81 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"strings"
+)
+
+// bucketPolicyFile is the name of the file, at the root of each bucket, that contains the bucket policy.
+const bucketPolicyFile = reservedNamePrefix + "policy.json"
+
+// BucketPolicy is a resource based policy of a bucket.
+type BucketPolicy struct {
+	Version   string `json:",omitempty"`
+	Statement []*idp.ResourcePolicyStatement
+}
+
+// parseBucketPolicy parses and validates the policy of bucket.
+// Each statement must name at least one principal, action and resource, and every resource must be within the bucket.
+func parseBucketPolicy(b []byte, bucket string) (*BucketPolicy, error) {
+	errMalformedPolicy := func(message string) error {
+		return &exception.Error{
+			ErrorCode: exception.MalformedPolicy,
+			Message:   message,
+		}
+	}
+
+	// Unknown fields are rejected, so that a policy is never interpreted differently to how it was written
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	var policy BucketPolicy
+	err := dec.Decode(&policy)
+	if err != nil {
+		return nil, errMalformedPolicy("Policies must be valid JSON: " + err.Error())
+	}
+
+	if len(policy.Statement) == 0 {
+		return nil, errMalformedPolicy("Policy must contain at least one statement.")
+	}
+
+	for _, statement := range policy.Statement {
+		if statement == nil || len(statement.Principal) == 0 || len(statement.Action) == 0 || len(statement.Resource) == 0 {
+			return nil, errMalformedPolicy("Each statement must have a Principal, Action and Resource.")
+		}
+
+		for _, resource := range statement.Resource {
+			if resource != idp.Resource(bucket) && !strings.HasPrefix(string(resource), bucket+"/") {
+				return nil, errMalformedPolicy("Policy has a resource outside of the bucket: " + string(resource))
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+// readBucketPolicy reads the policy of bucket.
+// It returns nil if the bucket has no policy, or the bucket cannot be opened.
+func (s *Server) readBucketPolicy(bucket string) (*BucketPolicy, error) {
+	fsys, err := s.filesystemProvider.Open(bucket)
+	if err != nil {
+		return nil, nil
+	}
+
+	b, err := readBucketConfig(fsys, bucketPolicyFile)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	policy, err := parseBucketPolicy(b, bucket)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   "The policy of this bucket is not valid.",
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,182 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseBucketPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		valid  bool
+	}{
+		{name: "valid", valid: true, policy: `{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`},
+		{name: "bucket resource", valid: true, policy: `{"Version": "2012-10-17", "Statement": [{"Principal": ["*"], "Action": "s3:ListBucket", "Resource": "bucket"}]}`},
+		{name: "not json", valid: false, policy: `Statement`},
+		{name: "no statements", valid: false, policy: `{"Statement": []}`},
+		{name: "no principal", valid: false, policy: `{"Statement": [{"Action": "s3:GetObject", "Resource": "bucket/*"}]}`},
+		{name: "other bucket", valid: false, policy: `{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "other/*"}]}`},
+		{name: "bucket prefix", valid: false, policy: `{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket*"}]}`},
+		{name: "unknown field", valid: false, policy: `{"Statement": [{"Effect": "Deny", "Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBucketPolicy([]byte(tt.policy), "bucket")
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, &exception.Error{ErrorCode: exception.MalformedPolicy})
+			}
+		})
+	}
+}
+
+// testBucketPolicyServer is like testWritableServer, but the test identity is named alice and has the given identity policy.
+func testBucketPolicyServer(dir string, policy ...*idp.PolicyStatement) *Server {
+	srv := testWritableServer(dir)
+	srv.identities = idp.Keyring{
+		idp.TestIdentity.AccessKeyId: &idp.Identity{
+			Name:            "alice",
+			AccessKeyId:     idp.TestIdentity.AccessKeyId,
+			SecretAccessKey: idp.TestIdentity.SecretAccessKey,
+			Policy:          policy,
+		},
+	}
+
+	return srv
+}
+
+func TestServer_BucketPolicy(t *testing.T) {
+	t.Run("allow", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, bucketPolicyFile), []byte(`{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		testBucketPolicyServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "object", rw.Body.String())
+	})
+
+	t.Run("other principal", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, bucketPolicyFile), []byte(`{"Statement": [{"Principal": "bob", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		testBucketPolicyServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("explicit deny", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, bucketPolicyFile), []byte(`{"Statement": [{"Principal": "*", "Deny": true, "Action": "s3:GetObject", "Resource": "bucket/object.txt"}]}`), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		testBucketPolicyServer(dir, &idp.PolicyStatement{
+			Action:   []idp.Action{"*"},
+			Resource: []idp.Resource{"*"},
+		}).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, bucketPolicyFile), []byte(`invalid`), 0644)
+
+		srv := testBucketPolicyServer(dir, &idp.PolicyStatement{
+			Action:   []idp.Action{"*"},
+			Resource: []idp.Resource{"*"},
+		})
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidBucketState)
+
+		// The policy can still be deleted
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "policy", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("put get delete", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testWritableServer(dir)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "policy", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchBucketPolicy)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/", "policy", nil, []byte(`{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`))
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir, bucketPolicyFile)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "policy", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.JSONEq(t, `{"Statement": [{"Principal": ["alice"], "Deny": false, "Action": ["s3:GetObject"], "Resource": ["bucket/*"]}]}`, rw.Body.String())
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "policy", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		dir := t.TempDir()
+
+		srv := testWritableServer(dir)
+		srv.filesystemProvider = &SingleBucketFilesystem{FS: DirFS(dir)}
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/", "policy", nil, []byte(`{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "bucket/*"}]}`))
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "policy", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("put malformed", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/", "policy", nil, []byte(`{"Statement": [{"Principal": "alice", "Action": "s3:GetObject", "Resource": "*"}]}`))
+		testWritableServer(t.TempDir()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MalformedPolicy)
+	})
+}
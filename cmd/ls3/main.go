@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	cryto_rand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -19,9 +21,11 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"sync/atomic"
+	"syscall"
 	"text/template"
 	"time"
 )
@@ -30,7 +34,8 @@ const ls3StartupTemplate = `
 [Lightweight Object Storage Server]
 Version           {{ .Version }}
 Directory         {{ .AbsPath }}{{ .Sep }}[*]
-Endpoint          http://{{if .Domain }}{{ .Domain }}{{ else }}{{ .Host }}{{ end }}:{{ .Port }}
+Endpoint          {{ .Scheme }}://{{if .Domain }}{{ .Domain }}{{ else }}{{ .Host }}{{ end }}:{{ .Port }}
+Region            {{ .Region }}
 Public Access     {{ .PublicAccess }}
 Access Key ID     {{ .AccessKeyId }}
 Secret Access Key {{ .SecretAccessKey }}
@@ -78,6 +83,93 @@ func readPolicyFromFile(f string) ([]*idp.PolicyStatement, error) {
 	return acl, nil
 }
 
+func readRolesFromFile(f string) (idp.Roles, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var list []*idp.Role
+	err = json.NewDecoder(r).Decode(&list)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles = make(idp.Roles, len(list))
+	for i, role := range list {
+		if _, ok := roles[role.Name]; ok || role.Name == "" {
+			return nil, fmt.Errorf("role %d (%s): each role must have a unique name", i, role.Name)
+		}
+
+		roles[role.Name] = role
+	}
+
+	return roles, nil
+}
+
+func readWebIdentityIssuersFromFile(f string) (idp.WebIdentityIssuers, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var issuers idp.WebIdentityIssuers
+	err = json.NewDecoder(r).Decode(&issuers)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, issuer := range issuers {
+		err = issuer.Load()
+		if err != nil {
+			return nil, fmt.Errorf("web identity issuer %d (%s): %w", i, issuer.Issuer, err)
+		}
+	}
+
+	return issuers, nil
+}
+
+func readCertificateIdentitiesFromFile(f string) ([]*idp.CertificateIdentity, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var identities []*idp.CertificateIdentity
+	err = json.NewDecoder(r).Decode(&identities)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, identity := range identities {
+		if identity == nil || identity.Name == "" || (identity.Subject == "" && len(identity.SAN) == 0) {
+			return nil, fmt.Errorf("certificate identity %d: each identity must have a name and a subject or SAN", i)
+		}
+	}
+
+	return identities, nil
+}
+
+func readCertPoolFromFile(f string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no PEM encoded certificates found", f)
+	}
+
+	return pool, nil
+}
+
 func NewServerPool(ctx context.Context, log *zap.Logger) *ServerPool {
 	ctx, cancel := context.WithCancel(ctx)
 	return &ServerPool{
@@ -110,7 +202,12 @@ func (p *ServerPool) Start(server *http.Server) {
 
 		p.log.Info(fmt.Sprintf("Start HTTP server on %s", server.Addr))
 
-		err := server.ListenAndServe()
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if err != nil {
 			p.log.Error("HTTP server stopped", zap.Error(err))
 		}
@@ -132,16 +229,36 @@ func (p *ServerPool) Wait() {
 }
 
 type Command struct {
-	ListenAddr          string `long:"listen-addr" env:"LISTEN_ADDRESS" default:"127.0.0.1:9000" description:"HTTP listen address"`
-	MetricsListenAddr   string `long:"metrics-listen-addr" env:"METRICS_LISTEN_ADDRESS" default:"127.0.0.1:9001" description:"HTTP listen address for the metrics server"`
-	Domain              string `long:"domain" env:"DOMAIN" description:"Host style addressing on this domain"`
-	AccessKeyId         string `long:"access-key-id" env:"ACCESS_KEY_ID" description:"Set the access key id. Generated if not provided."`
-	SecretAccessKey     string `long:"secret-access-key" env:"SECRET_ACCESS_KEY" description:"Set the secret access key. Generated if not provided. If provided, access key id must also be provided"`
-	GlobalPolicyFile    string `long:"global-policy" env:"GLOBAL_POLICY_FILE" description:"Read the global server access policy from this file."`
-	CredentialsFile     string `long:"credentials" env:"CREDENTIALS_FILE" description:"Read credentials from this file."`
-	PublicAccess        bool   `long:"public-access" env:"PUBLIC_ACCESS" description:"Enable public access to all resources provided by this server. When enabled, adds UNAUTHENTICATED to the default policy. The behaviour of the UNAUTHENTICATED identity can still be managed through a custom identity or the global policy"`
-	TrustRealIP         bool   `long:"http-trust-real-ip" env:"HTTP_TRUST_REAL_IP" description:"Trust the value of X-Real-Ip. Only use with an intermediate proxy"`
-	TrustForwardedProto bool   `long:"http-trust-forwarded-proto" env:"HTTP_TRUST_FORWARDED_PROTO" description:"Trust the value of X-Forwarded-Proto. Only use with an intermediate proxy"`
+	ListenAddr          string        `long:"listen-addr" env:"LISTEN_ADDRESS" default:"127.0.0.1:9000" description:"HTTP listen address"`
+	MetricsListenAddr   string        `long:"metrics-listen-addr" env:"METRICS_LISTEN_ADDRESS" default:"127.0.0.1:9001" description:"HTTP listen address for the metrics server"`
+	Domain              string        `long:"domain" env:"DOMAIN" description:"Host style addressing on this domain"`
+	Region              string        `long:"region" env:"REGION" default:"us-east-1" description:"The region of the server, that requests must be signed for. Buckets created with a location constraint are in their own region"`
+	WebsiteDomain       string        `long:"website-domain" env:"WEBSITE_DOMAIN" description:"Serve buckets as static websites using host style addressing on this domain"`
+	AccessKeyId         string        `long:"access-key-id" env:"ACCESS_KEY_ID" description:"Set the access key id. Generated if not provided."`
+	SecretAccessKey     string        `long:"secret-access-key" env:"SECRET_ACCESS_KEY" description:"Set the secret access key. Generated if not provided. If provided, access key id must also be provided"`
+	GlobalPolicyFile    string        `long:"global-policy" env:"GLOBAL_POLICY_FILE" description:"Read the global server access policy from this file."`
+	CredentialsFile     string        `long:"credentials" env:"CREDENTIALS_FILE" description:"Read credentials from this file."`
+	SessionKey          string        `long:"session-key" env:"SESSION_KEY" description:"Enable temporary credentials from AssumeRole and GetSessionToken, using this secret key to seal session tokens"`
+	RolesFile           string        `long:"roles" env:"ROLES_FILE" description:"Read the roles that can be assumed with AssumeRole from this file. Requires a session key"`
+	CertIdentityFile    string        `long:"certificate-identities" env:"CERTIFICATE_IDENTITIES_FILE" description:"Read the identities of verified client certificates from this file. Requires a client CA"`
+	WebIdentityFile     string        `long:"web-identity-issuers" env:"WEB_IDENTITY_ISSUERS_FILE" description:"Read the trusted issuers of web identity tokens for AssumeRoleWithWebIdentity from this file. Requires a session key"`
+	SignatureV2         bool          `long:"signature-v2" env:"SIGNATURE_V2" description:"Also accept requests signed with the legacy AWS Signature Version 2"`
+	MaxRequestSkew      time.Duration `long:"max-request-skew" env:"MAX_REQUEST_SKEW" default:"15m" description:"Reject signed requests with a time that differs from the server time by more than this"`
+	ReplayCacheSize     int           `long:"replay-cache-size" env:"REPLAY_CACHE_SIZE" description:"Reject exact replays of signed requests, remembering up to this many recent signatures. Disabled if 0"`
+	LockoutThreshold    int           `long:"lockout-threshold" env:"LOCKOUT_THRESHOLD" description:"Temporarily block a client IP or access key id after this many failed signature verifications. Disabled if 0"`
+	LockoutDecay        time.Duration `long:"lockout-decay" env:"LOCKOUT_DECAY" default:"1m" description:"Forget one failed signature verification of a client IP or access key id after this long"`
+	LockoutDuration     time.Duration `long:"lockout-duration" env:"LOCKOUT_DURATION" default:"15m" description:"Block a locked out client IP or access key id for this long"`
+	PublicAccess        bool          `long:"public-access" env:"PUBLIC_ACCESS" description:"Enable public access to all resources provided by this server. When enabled, adds UNAUTHENTICATED to the default policy. The behaviour of the UNAUTHENTICATED identity can still be managed through a custom identity or the global policy"`
+	TLSCert             string        `long:"tls-cert" env:"TLS_CERT_FILE" description:"Serve HTTPS using the PEM encoded certificate in this file. Reloaded on SIGHUP"`
+	TLSKey              string        `long:"tls-key" env:"TLS_KEY_FILE" description:"The PEM encoded private key of the TLS certificate. Reloaded on SIGHUP"`
+	TLSClientCA         string        `long:"tls-client-ca" env:"TLS_CLIENT_CA_FILE" description:"Verify client certificates against the PEM encoded certificate authorities in this file"`
+	TrustRealIP         bool          `long:"http-trust-real-ip" env:"HTTP_TRUST_REAL_IP" description:"Trust the value of X-Real-Ip. Only use with an intermediate proxy"`
+	TrustForwardedProto bool          `long:"http-trust-forwarded-proto" env:"HTTP_TRUST_FORWARDED_PROTO" description:"Trust the value of X-Forwarded-Proto. Only use with an intermediate proxy"`
+	ETagCacheFile       string        `long:"etag-cache" env:"ETAG_CACHE_FILE" description:"Persist computed object ETags to this file between restarts"`
+	ETagCacheSize       int           `long:"etag-cache-size" env:"ETAG_CACHE_SIZE" default:"100000" description:"Maximum number of object ETags to cache"`
+	Writable            []string      `long:"writable" env:"WRITABLE" env-delim:"," description:"Allow objects to be written to this bucket. Use * to allow writing to all buckets"`
+	ManageBuckets       bool          `long:"manage-buckets" env:"MANAGE_BUCKETS" description:"Allow buckets to be created and deleted"`
+	SnapshotDir         string        `long:"snapshot-dir" env:"SNAPSHOT_DIR" description:"Serve each snapshot in this directory of a bucket as a version of its objects, such as .zfs/snapshot"`
 
 	Positional struct {
 		Path string `required:"true" description:"The root directory to serve"`
@@ -240,11 +357,96 @@ func Main(log *zap.Logger) error {
 		identityProvider = idp.MultiIdentityProvider{fromFile, defaultKeyring}
 	}
 
+	if cmd.CertIdentityFile != "" {
+		if cmd.TLSClientCA == "" {
+			return errors.New("a client CA must be provided to authenticate client certificates")
+		}
+
+		certIdentities, err := readCertificateIdentitiesFromFile(cmd.CertIdentityFile)
+		if err != nil {
+			return err
+		}
+
+		identityProvider = &idp.CertificateProvider{
+			Provider:   identityProvider,
+			Identities: certIdentities,
+		}
+	}
+
+	if (cmd.RolesFile != "" || cmd.WebIdentityFile != "") && cmd.SessionKey == "" {
+		return errors.New("a session key must be provided to assume roles")
+	}
+
+	if cmd.SessionKey != "" {
+		sessions := &idp.SessionProvider{
+			Provider: identityProvider,
+			Key:      []byte(cmd.SessionKey),
+		}
+
+		if cmd.RolesFile != "" {
+			sessions.Roles, err = readRolesFromFile(cmd.RolesFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cmd.WebIdentityFile != "" {
+			sessions.Issuers, err = readWebIdentityIssuersFromFile(cmd.WebIdentityFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		identityProvider = sessions
+	}
+
 	absPath, err := filepath.Abs(cmd.Positional.Path)
 	if err != nil {
 		return err
 	}
 
+	etagCache := ls3.NewETagCache(cmd.ETagCacheSize)
+	if cmd.ETagCacheFile != "" {
+		err = etagCache.LoadFile(cmd.ETagCacheFile)
+		if err != nil {
+			log.Warn("Unable to load the ETag cache. Object ETags will be recomputed.", zap.Error(err))
+		}
+	}
+
+	// HTTPS is served directly if a certificate is provided
+	var (
+		tlsConfig *tls.Config
+		keyPair   *security.KeyPair
+		scheme    = "http"
+	)
+
+	if cmd.TLSCert != "" || cmd.TLSKey != "" {
+		keyPair, err = security.LoadKeyPair(cmd.TLSCert, cmd.TLSKey)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: keyPair.GetCertificate,
+		}
+		scheme = "https"
+	}
+
+	if cmd.TLSClientCA != "" {
+		if tlsConfig == nil {
+			return errors.New("a TLS certificate and key must be provided to verify client certificates")
+		}
+
+		tlsConfig.ClientCAs, err = readCertPoolFromFile(cmd.TLSClientCA)
+		if err != nil {
+			return err
+		}
+
+		// Clients without a certificate can still authenticate by signing their requests
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
 	info, _ := debug.ReadBuildInfo()
 
 	host, port, _ := net.SplitHostPort(cmd.ListenAddr)
@@ -254,31 +456,72 @@ func Main(log *zap.Logger) error {
 		"Version":         getBuildVersion(info),
 		"AbsPath":         absPath,
 		"Sep":             string(os.PathSeparator),
+		"Scheme":          scheme,
 		"Host":            host,
 		"Port":            port,
 		"PublicAccess":    cmd.PublicAccess,
 		"Domain":          cmd.Domain,
+		"Region":          cmd.Region,
 		"AccessKeyId":     cmd.AccessKeyId,
 		"SecretAccessKey": cmd.SecretAccessKey,
 	})
 
+	var signV4 = ls3.SignAWSV4{
+		Region:  cmd.Region,
+		MaxSkew: cmd.MaxRequestSkew,
+	}
+
+	var signV2 = ls3.SignAWSV2{
+		Domain:  cmd.Domain,
+		MaxSkew: cmd.MaxRequestSkew,
+	}
+
+	if cmd.ReplayCacheSize > 0 {
+		signV4.Replay = ls3.NewReplayCache(cmd.ReplayCacheSize)
+		signV2.Replay = signV4.Replay
+	}
+
+	var signer ls3.Signer = signV4
+	if cmd.SignatureV2 {
+		signer = ls3.MultiSigner{signV4, signV2}
+	}
+
 	var (
 		ctx           = interrupt.Context(context.Background())
 		serverPool    = NewServerPool(ctx, log)
 		serverOptions = &ls3.ServerOptions{
-			Log:          log,
-			Signer:       ls3.SignAWSV4{},
-			Identity:     identityProvider,
-			Domain:       cmd.Domain,
-			GlobalPolicy: globalPolicy,
-			ClientIP:     security.DirectClientIP,
-			ClientTLS:    security.DirectClientTLS,
+			Log:           log,
+			Signer:        signer,
+			Identity:      identityProvider,
+			Domain:        cmd.Domain,
+			Region:        cmd.Region,
+			WebsiteDomain: cmd.WebsiteDomain,
+			GlobalPolicy:  globalPolicy,
+			ClientIP:      security.DirectClientIP,
+			ClientTLS:     security.DirectClientTLS,
+			ETagCache:     etagCache,
+			ManageBuckets: cmd.ManageBuckets,
 			Filesystem: &ls3.SubdirBucketFilesystem{
-				FS: os.DirFS(absPath),
+				FS:          ls3.DirFS(absPath),
+				SnapshotDir: cmd.SnapshotDir,
+				Writable:    cmd.Writable,
 			},
 		}
 	)
 
+	if cmd.LockoutThreshold > 0 {
+		serverOptions.ClientLockout = security.NewLockout(cmd.LockoutThreshold, cmd.LockoutDecay, cmd.LockoutDuration)
+		serverOptions.KeyLockout = security.NewLockout(cmd.LockoutThreshold, cmd.LockoutDecay, cmd.LockoutDuration)
+	}
+
+	if len(cmd.Writable) > 0 {
+		log.Warn("Objects can be written to buckets", zap.Strings("buckets", cmd.Writable))
+	}
+
+	if cmd.ManageBuckets {
+		log.Warn("Buckets can be created and deleted")
+	}
+
 	if cmd.TrustRealIP {
 		log.Warn("Trusting HTTP header X-Real-Ip")
 		serverOptions.ClientIP = security.ForwardedRealIP
@@ -292,8 +535,13 @@ func Main(log *zap.Logger) error {
 		Addr:        cmd.ListenAddr,
 		Handler:     ls3.NewServer(serverOptions),
 		ConnContext: security.ConnContext,
+		TLSConfig:   tlsConfig,
 	})
 
+	if keyPair != nil {
+		go reloadKeyPairOnSignal(ctx, log, keyPair)
+	}
+
 	if cmd.MetricsListenAddr != "" {
 		serverPool.Start(&http.Server{
 			Addr:    cmd.MetricsListenAddr,
@@ -301,11 +549,62 @@ func Main(log *zap.Logger) error {
 		})
 	}
 
+	if cmd.ETagCacheFile != "" {
+		go saveETagCachePeriodically(ctx, log, etagCache, cmd.ETagCacheFile)
+	}
+
 	serverPool.Wait()
 
+	if cmd.ETagCacheFile != "" {
+		err = etagCache.SaveFile(cmd.ETagCacheFile)
+		if err != nil {
+			log.Error("Unable to save the ETag cache", zap.Error(err))
+		}
+	}
+
 	return nil
 }
 
+// reloadKeyPairOnSignal reloads the TLS certificate and key from their files on SIGHUP until ctx is cancelled.
+func reloadKeyPairOnSignal(ctx context.Context, log *zap.Logger, keyPair *security.KeyPair) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			err := keyPair.Reload()
+			if err != nil {
+				log.Error("Unable to reload the TLS certificate", zap.Error(err))
+				continue
+			}
+
+			log.Info("Reloaded the TLS certificate")
+		}
+	}
+}
+
+// saveETagCachePeriodically saves the ETag cache to a file at regular intervals until ctx is cancelled.
+func saveETagCachePeriodically(ctx context.Context, log *zap.Logger, cache *ls3.ETagCache, name string) {
+	ticker := time.NewTicker(time.Minute * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cache.SaveFile(name)
+			if err != nil {
+				log.Error("Unable to save the ETag cache", zap.Error(err))
+			}
+		}
+	}
+}
+
 func main() {
 	cfg := zap.NewDevelopmentConfig()
 	log, _ := cfg.Build()
@@ -2,16 +2,19 @@ package ls3
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/xml"
 	"github.com/google/uuid"
 	"github.com/relvacode/ls3/exception"
 	"github.com/relvacode/ls3/idp"
+	"github.com/relvacode/ls3/security"
 	"go.uber.org/zap"
 	"io"
 	"io/fs"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 var xmlContentHeader = []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
@@ -21,8 +24,11 @@ type RequestContext struct {
 	ID         uuid.UUID
 	Bucket     string
 	Filesystem fs.FS
-	Request    *http.Request
-	Identity   *idp.Identity
+	// VersionId is the requested version of the object.
+	// If set, Filesystem is the filesystem of that version of the bucket.
+	VersionId string
+	Request   *http.Request
+	Identity  *idp.Identity
 
 	// The client IP address
 	RemoteIP net.IP
@@ -30,6 +36,12 @@ type RequestContext struct {
 	Secure bool
 
 	globalPolicy []*idp.PolicyStatement
+	etags        *ETagCache
+	// bucketPolicy returns the policy of a bucket, or nil if the bucket has no policy.
+	// If nil, bucket policies are not used.
+	bucketPolicy func(bucket string) (*BucketPolicy, error)
+	// bucketPolicies caches the policy of each bucket for this request.
+	bucketPolicies map[string]*BucketPolicy
 
 	rw http.ResponseWriter
 	// flag to indicate the context has already tried to encode the original payload.
@@ -48,15 +60,96 @@ func (ctx *RequestContext) Get(k string) (string, bool) {
 		return ctx.Identity.Name, true
 	case "ls3:authenticated":
 		return strconv.FormatBool(ctx.Identity.AccessKeyId != idp.IdentityUnauthenticatedPublic), true
+	case "ls3:tls:subject":
+		if cert, ok := security.VerifiedClientCertificate(ctx.Request); ok {
+			return cert.Subject.String(), true
+		}
+
+		return "", false
+	case "ls3:tls:san":
+		if cert, ok := security.VerifiedClientCertificate(ctx.Request); ok {
+			if names := idp.CertificateSANs(cert); len(names) > 0 {
+				return names[0], true
+			}
+		}
+
+		return "", false
 	default:
+		// The claims of the web identity token of a session
+		if session := ctx.Identity.Session; session != nil && session.WebIdentity != nil {
+			return session.WebIdentity.Get(k)
+		}
+
 		return "", false
 	}
 }
 
+// versionCacheKey returns the key that identifies a version of a bucket in a cache.
+func versionCacheKey(bucket, versionId string) string {
+	if versionId == "" || versionId == nullVersionId {
+		return bucket
+	}
+
+	return bucket + "?versionId=" + versionId
+}
+
+// objectCacheKey returns the key that identifies the object in the current version of the bucket in a cache.
+func (ctx *RequestContext) objectCacheKey(key string) string {
+	return versionCacheKey(ctx.Bucket, ctx.VersionId) + "/" + key
+}
+
+// objectReadAction returns the action required to read the requested object.
+// Reading a specific version of an object requires GetObjectVersion instead of GetObject.
+func (ctx *RequestContext) objectReadAction() idp.Action {
+	if ctx.VersionId != "" {
+		return idp.GetObjectVersion
+	}
+
+	return idp.GetObject
+}
+
+// bucketPolicyDecision returns the decision of the policy of the bucket of resource for the current identity.
+func (ctx *RequestContext) bucketPolicyDecision(action idp.Action, resource idp.Resource, policyContext idp.PolicyContextVars) (idp.PolicyDecision, *exception.Error) {
+	// Only S3 actions apply to a bucket
+	bucket, _, _ := strings.Cut(string(resource), "/")
+	if bucket == "" || ctx.bucketPolicy == nil || !strings.HasPrefix(string(action), "s3:") {
+		return idp.ImplicitDeny, nil
+	}
+
+	policy, ok := ctx.bucketPolicies[bucket]
+	if !ok {
+		var err error
+		policy, err = ctx.bucketPolicy(bucket)
+		if err != nil {
+			// An invalid bucket policy denies all access to the bucket,
+			// except to replace or delete the policy by an identity that is otherwise allowed to do so.
+			switch action {
+			case idp.PutBucketPolicy, idp.DeleteBucketPolicy:
+				return idp.ImplicitDeny, nil
+			}
+
+			return idp.ExplicitDeny, exception.ErrorFrom(err)
+		}
+
+		if ctx.bucketPolicies == nil {
+			ctx.bucketPolicies = make(map[string]*BucketPolicy)
+		}
+		ctx.bucketPolicies[bucket] = policy
+	}
+
+	if policy == nil {
+		return idp.ImplicitDeny, nil
+	}
+
+	return idp.DecideResourcePolicy(ctx.Identity.Name, action, resource, policy.Statement, policyContext), nil
+}
+
 // CheckAccess verifies that the current identity has the appropriate permissions to execute the given access for the given resource.
 // vars are additional PolicyContextVars that will be used in the conditional policy evaluation.
 // CheckAccess will first verify that the request meets the global policy,
-// if that succeeds it will then check the identity specific PolicyStatement.
+// if that succeeds it will then check the identity specific PolicyStatement and the policy of the bucket.
+// Access is granted if either allows access, and neither explicitly denies access.
+// If the identity is a temporary session with a session policy, the session policy must also allow access.
 func (ctx *RequestContext) CheckAccess(action idp.Action, resource idp.Resource, vars idp.PolicyContextVars) *exception.Error {
 	ctx.Logger = ctx.Logger.With(
 		zap.String("action", string(action)),
@@ -74,13 +167,35 @@ func (ctx *RequestContext) CheckAccess(action idp.Action, resource idp.Resource,
 		return err
 	}
 
-	// Check if identity specific policy matches request
-	err = idp.EvaluatePolicy(action, resource, ctx.Identity.Policy, policyContext)
-	if err != nil {
+	// Check if identity specific policy or bucket policy matches request
+	decision := idp.DecidePolicy(action, resource, ctx.Identity.Policy, policyContext)
+	if decision != idp.ExplicitDeny {
+		bucketDecision, err := ctx.bucketPolicyDecision(action, resource, policyContext)
+		if err != nil {
+			statApiCall.WithLabelValues((string)(action), (string)(resource), ctx.Identity.Name, ctx.RemoteIP.String()).Add(1)
+
+			ctx.Logger.Error("Unable to read the bucket policy", zap.Error(err))
+			return err
+		}
+
+		if bucketDecision > decision {
+			decision = bucketDecision
+		}
+	}
+
+	// A session policy limits the access of a temporary session
+	if session := ctx.Identity.Session; decision == idp.Allow && session != nil && session.Policy != nil {
+		decision = idp.DecidePolicy(action, resource, session.Policy, policyContext)
+	}
+
+	if decision != idp.Allow {
 		statApiCall.WithLabelValues((string)(action), (string)(resource), ctx.Identity.Name, ctx.RemoteIP.String()).Add(1)
 
-		ctx.Logger.Error("Access to resource is denied by identity specific policy")
-		return err
+		ctx.Logger.Error("Access to resource is denied by identity specific policy, bucket policy or session policy")
+		return &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "You do not have permission to access this resource.",
+		}
 	}
 
 	statApiCall.WithLabelValues((string)(action), (string)(resource), ctx.Identity.Name, ctx.RemoteIP.String()).Add(1)
@@ -88,6 +203,55 @@ func (ctx *RequestContext) CheckAccess(action idp.Action, resource idp.Resource,
 	return nil
 }
 
+// ReadBody reads the entire request body, which may be at most maxSize bytes.
+// If the request has a Content-MD5 header then the body must match it.
+func (ctx *RequestContext) ReadBody(maxSize int64) ([]byte, *exception.Error) {
+	expectMD5, err := contentMD5(ctx.Request.Header)
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	// Reading to the end of the body verifies the payload checksum
+	b, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSize+1))
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	if int64(len(b)) > maxSize {
+		return nil, &exception.Error{
+			ErrorCode: exception.MaxMessageLengthExceeded,
+			Message:   "Your request was too big.",
+		}
+	}
+
+	if sum := md5.Sum(b); expectMD5 != nil && !bytes.Equal(sum[:], expectMD5) {
+		return nil, &exception.Error{
+			ErrorCode: exception.BadDigest,
+			Message:   "The Content-MD5 you specified did not match what we received.",
+		}
+	}
+
+	return b, nil
+}
+
+// ReadXML reads the entire request body using ReadBody, and decodes it as XML into v.
+func (ctx *RequestContext) ReadXML(v any, maxSize int64) *exception.Error {
+	b, rerr := ctx.ReadBody(maxSize)
+	if rerr != nil {
+		return rerr
+	}
+
+	err := xml.Unmarshal(b, v)
+	if err != nil {
+		return &exception.Error{
+			ErrorCode: exception.MalformedXML,
+			Message:   "The XML you provided was not well-formed or did not validate against our published schema.",
+		}
+	}
+
+	return nil
+}
+
 func (ctx *RequestContext) Header() http.Header {
 	return ctx.rw.Header()
 }
@@ -143,6 +307,10 @@ func (ctx *RequestContext) SendKnownError(err *exception.Error) {
 
 	statApiError.WithLabelValues(ctx.Identity.Name, ctx.RemoteIP.String(), err.Code).Add(1)
 
+	if err.Region != "" {
+		ctx.rw.Header().Set("x-amz-bucket-region", err.Region)
+	}
+
 	ctx.SendXML(err.StatusCode, &ErrorPayload{
 		Error:     *err,
 		Resource:  ctx.Request.URL.Path,
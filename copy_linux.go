@@ -0,0 +1,75 @@
+//go:build linux
+
+package ls3
+
+import (
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"syscall"
+)
+
+// copyToFile copies the contents of src to the new file dst.
+// If src is a file on the same filesystem as dst, the data is shared with a reflink where supported, such as on btrfs and XFS.
+// Otherwise, io.Copy uses copy_file_range between two operating system files.
+func copyToFile(dst WritableFile, src io.Reader) (int64, error) {
+	if n, ok := cloneFile(dst, src); ok {
+		return n, nil
+	}
+
+	return io.Copy(dst, src)
+}
+
+// cloneFile clones the entire contents of src into dst using FICLONE.
+// It returns false if src is not an unread operating system file, or the file could not be cloned.
+func cloneFile(dst WritableFile, src io.Reader) (int64, bool) {
+	srcFile, ok := src.(*os.File)
+	if !ok {
+		return 0, false
+	}
+
+	dstConn, ok := dst.(syscall.Conn)
+	if !ok {
+		return 0, false
+	}
+
+	// FICLONE always clones the entire file
+	if offset, err := srcFile.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		return 0, false
+	}
+
+	fi, err := srcFile.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0, false
+	}
+
+	srcRaw, err := srcFile.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	dstRaw, err := dstConn.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var cloneErr error
+	err = dstRaw.Control(func(dstFd uintptr) {
+		err := srcRaw.Control(func(srcFd uintptr) {
+			cloneErr = unix.IoctlFileClone(int(dstFd), int(srcFd))
+		})
+		if cloneErr == nil {
+			cloneErr = err
+		}
+	})
+	if err != nil || cloneErr != nil {
+		return 0, false
+	}
+
+	// Leave src as if it had been read
+	if _, err := srcFile.Seek(0, io.SeekEnd); err != nil {
+		return 0, false
+	}
+
+	return fi.Size(), true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ls3
+
+import "io"
+
+// copyToFile copies the contents of src to the new file dst.
+func copyToFile(dst WritableFile, src io.Reader) (int64, error) {
+	return io.Copy(dst, src)
+}
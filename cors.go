@@ -0,0 +1,145 @@
+package ls3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bucketCorsFile is the name of the file, at the root of each bucket, that contains the CORS configuration of the bucket.
+const bucketCorsFile = reservedNamePrefix + "cors.json"
+
+// CORSRule describes the cross-origin requests that are allowed to a bucket.
+type CORSRule struct {
+	ID            string `xml:",omitempty" json:",omitempty"`
+	AllowedHeader []string
+	AllowedMethod []string
+	AllowedOrigin []string
+	ExposeHeader  []string
+	MaxAgeSeconds int `xml:",omitempty" json:",omitempty"`
+}
+
+// CORSConfiguration is the CORS configuration of a bucket.
+type CORSConfiguration struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CORSConfiguration" json:"-"`
+	CORSRule []*CORSRule
+}
+
+// wildcardMatchAny returns true if value matches any of the wildcard rules, ignoring case if foldCase is true.
+func wildcardMatchAny(rules []string, value string, foldCase bool) bool {
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+
+	for _, rule := range rules {
+		if foldCase {
+			rule = strings.ToLower(rule)
+		}
+
+		if rule == value || idp.WildcardMatch(rule, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allows returns true if the rule allows a request from origin using method, which may send the given headers.
+func (rule *CORSRule) allows(origin, method string, headers []string) bool {
+	if !wildcardMatchAny(rule.AllowedOrigin, origin, false) {
+		return false
+	}
+
+	var methodAllowed bool
+	for _, allowed := range rule.AllowedMethod {
+		if allowed == method {
+			methodAllowed = true
+			break
+		}
+	}
+
+	if !methodAllowed {
+		return false
+	}
+
+	for _, header := range headers {
+		if !wildcardMatchAny(rule.AllowedHeader, header, true) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match returns the first rule that allows a request from origin using method, which may send the given headers.
+// It returns nil if no rule allows the request, or if c is nil.
+func (c *CORSConfiguration) Match(origin, method string, headers []string) *CORSRule {
+	if c == nil {
+		return nil
+	}
+
+	for _, rule := range c.CORSRule {
+		if rule.allows(origin, method, headers) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// setCorsHeaders sets the Access-Control-* response headers of a request from origin that is allowed by rule.
+func setCorsHeaders(header http.Header, rule *CORSRule, origin string) {
+	header.Add("Vary", "Origin")
+
+	// A wildcard rule allows any origin, but never with credentials
+	if len(rule.AllowedOrigin) == 1 && rule.AllowedOrigin[0] == "*" {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	header.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethod, ", "))
+
+	if len(rule.ExposeHeader) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeader, ", "))
+	}
+
+	if rule.MaxAgeSeconds > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+}
+
+// readBucketCors reads the CORS configuration at the root of the bucket filesystem.
+// It returns nil if the bucket has no CORS configuration.
+func readBucketCors(fsys fs.FS) (*CORSConfiguration, error) {
+	b, err := readBucketConfig(fsys, bucketCorsFile)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	var config CORSConfiguration
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   "The CORS configuration of this bucket is not valid.",
+		}
+	}
+
+	for _, rule := range config.CORSRule {
+		if rule == nil || len(rule.AllowedOrigin) == 0 || len(rule.AllowedMethod) == 0 {
+			return nil, &exception.Error{
+				ErrorCode: exception.InvalidBucketState,
+				Message:   "Each CORS rule of this bucket must have an AllowedOrigin and AllowedMethod.",
+			}
+		}
+	}
+
+	return &config, nil
+}
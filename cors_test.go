@@ -0,0 +1,178 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCORSConfiguration_Match(t *testing.T) {
+	config := &CORSConfiguration{
+		CORSRule: []*CORSRule{
+			{
+				ID:            "app",
+				AllowedOrigin: []string{"https://*.example.com"},
+				AllowedMethod: []string{"GET", "PUT"},
+				AllowedHeader: []string{"x-amz-*", "Content-Type"},
+			},
+			{
+				ID:            "public",
+				AllowedOrigin: []string{"*"},
+				AllowedMethod: []string{"GET"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers []string
+		rule    string
+	}{
+		{name: "app", origin: "https://app.example.com", method: "PUT", headers: []string{"X-Amz-Date", "content-type"}, rule: "app"},
+		{name: "public", origin: "https://other.com", method: "GET", rule: "public"},
+		{name: "method", origin: "https://other.com", method: "PUT"},
+		{name: "header", origin: "https://app.example.com", method: "PUT", headers: []string{"Authorization"}},
+		{name: "header fallback", origin: "https://app.example.com", method: "GET", headers: []string{"Authorization"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := config.Match(tt.origin, tt.method, tt.headers)
+			if tt.rule == "" {
+				assert.Nil(t, rule)
+				return
+			}
+
+			if assert.NotNil(t, rule) {
+				assert.Equal(t, tt.rule, rule.ID)
+			}
+		})
+	}
+
+	assert.Nil(t, (*CORSConfiguration)(nil).Match("https://app.example.com", "GET", nil))
+}
+
+// testCorsDir returns a directory served as a bucket with a CORS configuration that allows GET from https://example.com.
+func testCorsDir(t *testing.T) string {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, bucketCorsFile), []byte(`{
+  "CORSRule": [
+    {
+      "AllowedOrigin": ["https://example.com"],
+      "AllowedMethod": ["GET", "HEAD"],
+      "AllowedHeader": ["*"],
+      "ExposeHeader": ["ETag"],
+      "MaxAgeSeconds": 3600
+    }
+  ]
+}`), 0644)
+
+	return dir
+}
+
+func testPreflightRequest(origin, method, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, "http://bucket.testing/bucket/object.txt", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method != "" {
+		req.Header.Set("Access-Control-Request-Method", method)
+	}
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+
+	return req
+}
+
+func TestServer_OptionsObject(t *testing.T) {
+	t.Run("allowed", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(testCorsDir(t)).ServeHTTP(rw, testPreflightRequest("https://example.com", "GET", "authorization, x-amz-date"))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "https://example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, HEAD", rw.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "authorization, x-amz-date", rw.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "ETag", rw.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "3600", rw.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(testCorsDir(t)).ServeHTTP(rw, testPreflightRequest("https://example.com", "PUT", ""))
+
+		AssertIsResponseError(t, rw, exception.AccessForbidden)
+		assert.Empty(t, rw.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(t.TempDir()).ServeHTTP(rw, testPreflightRequest("https://example.com", "GET", ""))
+
+		AssertIsResponseError(t, rw, exception.AccessForbidden)
+	})
+
+	t.Run("missing origin", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(testCorsDir(t)).ServeHTTP(rw, testPreflightRequest("", "GET", ""))
+
+		AssertIsResponseError(t, rw, exception.BadRequest)
+	})
+}
+
+func TestServer_CorsResponseHeaders(t *testing.T) {
+	srv := testWritableServer(testCorsDir(t))
+
+	t.Run("allowed", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", http.Header{
+			"Origin": []string{"https://example.com"},
+		}, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "https://example.com", rw.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag", rw.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", http.Header{
+			"Origin": []string{"https://other.com"},
+		}, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Empty(t, rw.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestServer_GetBucketCors(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(testCorsDir(t)).ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "cors", nil, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var config CORSConfiguration
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &config))
+		if assert.Len(t, config.CORSRule, 1) {
+			assert.Equal(t, []string{"https://example.com"}, config.CORSRule[0].AllowedOrigin)
+			assert.Equal(t, 3600, config.CORSRule[0].MaxAgeSeconds)
+		}
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWritableServer(t.TempDir()).ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "cors", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.NoSuchCORSConfiguration)
+	})
+}
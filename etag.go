@@ -0,0 +1,276 @@
+package ls3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultETagCacheSize is the default maximum number of entries held by an ETagCache.
+const DefaultETagCacheSize = 100000
+
+// computeETag computes the S3 style ETag of the given reader.
+// The ETag is the hex encoded MD5 sum of the entire contents of the reader.
+func computeETag(r io.Reader) (string, error) {
+	h := md5.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type etagCacheEntry struct {
+	Size    int64
+	ModTime int64
+	Inode   uint64
+	ETag    string
+	// ChecksumSHA256 is the base64 encoded SHA-256 checksum of the file, if it has been computed.
+	ChecksumSHA256 string `json:",omitempty"`
+}
+
+// newETagEntry returns the cache entry of etag for the file fi.
+func newETagEntry(fi fs.FileInfo, etag string) *etagCacheEntry {
+	return &etagCacheEntry{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UnixNano(),
+		Inode:   fileInode(fi),
+		ETag:    etag,
+	}
+}
+
+// matches returns true if the cache entry was computed for the given file.
+func (e *etagCacheEntry) matches(fi fs.FileInfo) bool {
+	return e.Size == fi.Size() && e.ModTime == fi.ModTime().UnixNano() && e.Inode == fileInode(fi)
+}
+
+// NewETagCache creates a new ETagCache that holds up to size entries.
+// If size is less than 1 then DefaultETagCacheSize is used.
+func NewETagCache(size int) *ETagCache {
+	if size < 1 {
+		size = DefaultETagCacheSize
+	}
+
+	return &ETagCache{
+		size:    size,
+		entries: make(map[string]*etagCacheEntry),
+	}
+}
+
+// ETagCache caches computed object ETags by object path.
+// A cached ETag is only valid as long as the size, modification time and inode of the file are unchanged.
+// The cache may be persisted between runs using Load and Save.
+type ETagCache struct {
+	size int
+
+	mx      sync.RWMutex
+	entries map[string]*etagCacheEntry
+}
+
+// Lookup returns the cached ETag for the object path.
+// Returns false if there is no entry for the path, or the entry is no longer valid for fi.
+func (c *ETagCache) Lookup(path string, fi fs.FileInfo) (string, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	entry, ok := c.entries[path]
+	if !ok || !entry.matches(fi) {
+		return "", false
+	}
+
+	return entry.ETag, true
+}
+
+// store stores the cache entry for the object path.
+// If the cache is full, then an arbitrary entry is evicted.
+func (c *ETagCache) store(path string, entry *etagCacheEntry) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.entries[path]; !ok && len(c.entries) >= c.size {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+
+	c.entries[path] = entry
+}
+
+// Store stores the ETag for the object path.
+// If the cache is full, then an arbitrary entry is evicted.
+func (c *ETagCache) Store(path string, fi fs.FileInfo, etag string) {
+	c.store(path, newETagEntry(fi, etag))
+}
+
+// storedETag returns the ETag stored in the metadata of the object name, if it is valid for fi.
+func storedETag(fsys fs.FS, name string, fi fs.FileInfo) (string, bool) {
+	meta, err := readObjectMetadata(fsys, name)
+	if err != nil || meta == nil || meta.ETag == nil || !meta.ETag.matches(fi) {
+		return "", false
+	}
+
+	return meta.ETag.ETag, true
+}
+
+// Cached returns the ETag for the file name in fsys, identified in the cache by path, without reading the file.
+// If there is no valid cache entry then the ETag stored in the metadata of the object is used.
+// Returns false if the ETag is not known without reading the file.
+func (c *ETagCache) Cached(fsys fs.FS, path string, name string, fi fs.FileInfo) (string, bool) {
+	if etag, ok := c.Lookup(path, fi); ok {
+		return etag, true
+	}
+
+	if etag, ok := storedETag(fsys, name, fi); ok {
+		c.Store(path, fi, etag)
+		return etag, true
+	}
+
+	return "", false
+}
+
+// Get returns the ETag for the file name in fsys, identified in the cache by path.
+// If the ETag is not cached, see Cached, then the file is read to compute the ETag.
+func (c *ETagCache) Get(fsys fs.FS, path string, name string, fi fs.FileInfo) (string, error) {
+	if etag, ok := c.Cached(fsys, path, name, fi); ok {
+		return etag, nil
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	etag, err := computeETag(f)
+	if err != nil {
+		return "", err
+	}
+
+	c.Store(path, fi, etag)
+	return etag, nil
+}
+
+// Checksum returns the base64 encoded SHA-256 checksum of the file name in fsys, identified in the cache by path.
+// The checksum is cached alongside the ETag, and if it is not cached then both are computed from a single read of the file.
+func (c *ETagCache) Checksum(fsys fs.FS, path string, name string, fi fs.FileInfo) (string, error) {
+	c.mx.RLock()
+	entry, ok := c.entries[path]
+	if ok && entry.matches(fi) && entry.ChecksumSHA256 != "" {
+		checksum := entry.ChecksumSHA256
+		c.mx.RUnlock()
+		return checksum, nil
+	}
+	c.mx.RUnlock()
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	var md5Hash, sha256Hash = md5.New(), sha256.New()
+	_, err = io.Copy(io.MultiWriter(md5Hash, sha256Hash), f)
+	if err != nil {
+		return "", err
+	}
+
+	// An ETag that is not the MD5 sum of the content, such as the ETag of a multipart upload, is kept
+	etag, ok := c.Lookup(path, fi)
+	if !ok {
+		etag, ok = storedETag(fsys, name, fi)
+	}
+	if !ok {
+		etag = hex.EncodeToString(md5Hash.Sum(nil))
+	}
+
+	entry = newETagEntry(fi, etag)
+	entry.ChecksumSHA256 = base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil))
+
+	c.store(path, entry)
+	return entry.ChecksumSHA256, nil
+}
+
+// Load reads cache entries previously written by Save.
+// Loaded entries replace any existing entry for the same path, and entries for new paths are loaded while the cache is not full.
+func (c *ETagCache) Load(r io.Reader) error {
+	var entries map[string]*etagCacheEntry
+	err := json.NewDecoder(r).Decode(&entries)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for k, entry := range entries {
+		if entry == nil {
+			continue
+		}
+
+		if _, ok := c.entries[k]; !ok && len(c.entries) >= c.size {
+			continue
+		}
+
+		c.entries[k] = entry
+	}
+
+	return nil
+}
+
+// Save writes all cache entries to w.
+func (c *ETagCache) Save(w io.Writer) error {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return json.NewEncoder(w).Encode(c.entries)
+}
+
+// LoadFile loads the cache from the named file.
+// A missing file is not an error.
+func (c *ETagCache) LoadFile(name string) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return c.Load(f)
+}
+
+// SaveFile atomically replaces the named file with the contents of the cache.
+func (c *ETagCache) SaveFile(name string) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".ls3-etag-cache-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	err = c.Save(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
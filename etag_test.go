@@ -0,0 +1,128 @@
+package ls3
+
+import (
+	"bytes"
+	"github.com/psanford/memfs"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestETagCache(t *testing.T) {
+	fsys := memfs.New()
+	_ = fsys.WriteFile("object.txt", []byte("Hello, World!"), 0644)
+
+	fi, _ := fs.Stat(fsys, "object.txt")
+	cache := NewETagCache(1)
+
+	t.Run("miss", func(t *testing.T) {
+		_, ok := cache.Lookup("bucket/object.txt", fi)
+		assert.False(t, ok)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		// The file is not read to compute a missing ETag
+		_, ok := cache.Cached(fsys, "bucket/object.txt", "object.txt", fi)
+		assert.False(t, ok)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		etag, err := cache.Get(fsys, "bucket/object.txt", "object.txt", fi)
+		assert.NoError(t, err)
+		assert.Equal(t, "65a8e27d8879283831b664bd8b7f0ad4", etag)
+
+		etag, ok := cache.Lookup("bucket/object.txt", fi)
+		assert.True(t, ok)
+		assert.Equal(t, "65a8e27d8879283831b664bd8b7f0ad4", etag)
+	})
+
+	t.Run("checksum", func(t *testing.T) {
+		checksum, err := cache.Checksum(fsys, "bucket/object.txt", "object.txt", fi)
+		assert.NoError(t, err)
+		assert.Equal(t, "3/1gIbsr1bCvZ2KQgJ7DpTGR3YHH9wpLKGiKNiGCmG8=", checksum)
+
+		// The checksum is cached alongside the ETag, so the file is not read again
+		checksum, err = cache.Checksum(memfs.New(), "bucket/object.txt", "object.txt", fi)
+		assert.NoError(t, err)
+		assert.Equal(t, "3/1gIbsr1bCvZ2KQgJ7DpTGR3YHH9wpLKGiKNiGCmG8=", checksum)
+
+		etag, ok := cache.Lookup("bucket/object.txt", fi)
+		assert.True(t, ok)
+		assert.Equal(t, "65a8e27d8879283831b664bd8b7f0ad4", etag)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		_ = fsys.WriteFile("object.txt", []byte("Hello, Other World!"), 0644)
+		modified, _ := fs.Stat(fsys, "object.txt")
+
+		_, ok := cache.Lookup("bucket/object.txt", modified)
+		assert.False(t, ok)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		cache.Store("bucket/other.txt", fi, "other")
+
+		_, ok := cache.Lookup("bucket/object.txt", fi)
+		assert.False(t, ok)
+	})
+
+	t.Run("persist", func(t *testing.T) {
+		var b bytes.Buffer
+		assert.NoError(t, cache.Save(&b))
+
+		loaded := NewETagCache(1)
+		assert.NoError(t, loaded.Load(&b))
+
+		etag, ok := loaded.Lookup("bucket/other.txt", fi)
+		assert.True(t, ok)
+		assert.Equal(t, "other", etag)
+	})
+
+	t.Run("load null", func(t *testing.T) {
+		loaded := NewETagCache(1)
+		assert.NoError(t, loaded.Load(strings.NewReader(`{"bucket/object.txt": null}`)))
+
+		_, ok := loaded.Lookup("bucket/object.txt", fi)
+		assert.False(t, ok)
+	})
+
+	t.Run("load full", func(t *testing.T) {
+		var b bytes.Buffer
+		assert.NoError(t, cache.Save(&b))
+
+		loaded := NewETagCache(1)
+		loaded.Store("bucket/other.txt", fi, "previous")
+		assert.NoError(t, loaded.Load(&b))
+
+		// Existing paths are replaced even if the cache is full
+		etag, ok := loaded.Lookup("bucket/other.txt", fi)
+		assert.True(t, ok)
+		assert.Equal(t, "other", etag)
+	})
+}
+
+func TestBucketIterator_UseETagCache(t *testing.T) {
+	fsys := memfs.New()
+	_ = fsys.WriteFile("cached.txt", []byte("cached"), 0644)
+	_ = fsys.WriteFile("uncached.txt", []byte("uncached"), 0644)
+
+	fi, _ := fs.Stat(fsys, "cached.txt")
+	cache := NewETagCache(0)
+	cache.Store("bucket/cached.txt", fi, "etag")
+
+	it := NewBucketIterator(fsys)
+	it.UseETagCache(cache, "bucket")
+
+	contents, err := it.PrefixScan("", "", false, 1000)
+	assert.NoError(t, err)
+
+	if assert.Len(t, contents, 2) {
+		assert.Equal(t, `"etag"`, contents[0].ETag)
+		assert.Equal(t, "", contents[1].ETag)
+	}
+
+	// Listing does not read objects to compute their ETag
+	_, ok := cache.Lookup("bucket/uncached.txt", fi)
+	assert.False(t, ok)
+}
@@ -9,6 +9,8 @@ type ErrorCode struct {
 
 var (
 	AccessDenied                 = ErrorCode{Code: "AccessDenied", StatusCode: 403}
+	AccessForbidden              = ErrorCode{Code: "AccessForbidden", StatusCode: 403}
+	BadRequest                   = ErrorCode{Code: "BadRequest", StatusCode: 400}
 	InvalidAccessKeyId           = ErrorCode{Code: "InvalidAccessKeyId", StatusCode: 403}
 	SignatureDoesNotMatch        = ErrorCode{Code: "SignatureDoesNotMatch", StatusCode: 403}
 	MethodNotAllowed             = ErrorCode{Code: "MethodNotAllowed", StatusCode: 405}
@@ -16,22 +18,57 @@ var (
 	ExpiredToken                 = ErrorCode{Code: "ExpiredToken", StatusCode: 400}
 	InvalidArgument              = ErrorCode{Code: "InvalidArgument", StatusCode: 400}
 	BadDigest                    = ErrorCode{Code: "BadDigest", StatusCode: 400}
+	InvalidDigest                = ErrorCode{Code: "InvalidDigest", StatusCode: 400}
+	IncompleteBody               = ErrorCode{Code: "IncompleteBody", StatusCode: 400}
+	InvalidChunkSizeError        = ErrorCode{Code: "InvalidChunkSizeError", StatusCode: 400}
+	MaxMessageLengthExceeded     = ErrorCode{Code: "MaxMessageLengthExceeded", StatusCode: 400}
 	NoSuchKey                    = ErrorCode{Code: "NoSuchKey", StatusCode: 404}
 	InvalidToken                 = ErrorCode{Code: "InvalidToken", StatusCode: 400}
+	InvalidIdentityToken         = ErrorCode{Code: "InvalidIdentityToken", StatusCode: 400}
 	InvalidObjectState           = ErrorCode{Code: "InvalidObjectState", StatusCode: 403}
 	InvalidRange                 = ErrorCode{Code: "InvalidRange", StatusCode: 416}
+	PreconditionFailed           = ErrorCode{Code: "PreconditionFailed", StatusCode: 412}
 	NoSuchBucket                 = ErrorCode{Code: "NoSuchBucket", StatusCode: 404}
+	InvalidBucketName            = ErrorCode{Code: "InvalidBucketName", StatusCode: 400}
+	BucketAlreadyOwnedByYou      = ErrorCode{Code: "BucketAlreadyOwnedByYou", StatusCode: 409}
+	BucketNotEmpty               = ErrorCode{Code: "BucketNotEmpty", StatusCode: 409}
+	NoSuchBucketPolicy           = ErrorCode{Code: "NoSuchBucketPolicy", StatusCode: 404}
+	MalformedPolicy              = ErrorCode{Code: "MalformedPolicy", StatusCode: 400}
+	NoSuchCORSConfiguration      = ErrorCode{Code: "NoSuchCORSConfiguration", StatusCode: 404}
+	NoSuchWebsiteConfiguration   = ErrorCode{Code: "NoSuchWebsiteConfiguration", StatusCode: 404}
+	NoSuchVersion                = ErrorCode{Code: "NoSuchVersion", StatusCode: 404}
+	NoSuchUpload                 = ErrorCode{Code: "NoSuchUpload", StatusCode: 404}
+	InvalidPart                  = ErrorCode{Code: "InvalidPart", StatusCode: 400}
+	InvalidPartOrder             = ErrorCode{Code: "InvalidPartOrder", StatusCode: 400}
+	EntityTooSmall               = ErrorCode{Code: "EntityTooSmall", StatusCode: 400}
+	EntityTooLarge               = ErrorCode{Code: "EntityTooLarge", StatusCode: 400}
+	MalformedPOSTRequest         = ErrorCode{Code: "MalformedPOSTRequest", StatusCode: 400}
+	InvalidPolicyDocument        = ErrorCode{Code: "InvalidPolicyDocument", StatusCode: 400}
 	InvalidBucketState           = ErrorCode{Code: "InvalidBucketState", StatusCode: 409}
+	InvalidLocationConstraint    = ErrorCode{Code: "InvalidLocationConstraint", StatusCode: 400}
 	InternalError                = ErrorCode{Code: "InternalError", StatusCode: 500}
+	SlowDown                     = ErrorCode{Code: "SlowDown", StatusCode: 503}
 	MalformedXML                 = ErrorCode{Code: "MalformedXML", StatusCode: 400}
 	AuthorizationHeaderMalformed = ErrorCode{Code: "AuthorizationHeaderMalformed", StatusCode: 400}
+	RequestTimeTooSkewed         = ErrorCode{Code: "RequestTimeTooSkewed", StatusCode: 403}
 	InvalidSecurity              = ErrorCode{Code: "InvalidSecurity", StatusCode: 403}
 	AccountProblem               = ErrorCode{Code: "AccountProblem", StatusCode: 403}
+	InvalidExpressionType        = ErrorCode{Code: "InvalidExpressionType", StatusCode: 400}
+	ParseUnexpectedToken         = ErrorCode{Code: "ParseUnexpectedToken", StatusCode: 400}
+	UnsupportedSqlOperation      = ErrorCode{Code: "UnsupportedSqlOperation", StatusCode: 400}
+	InvalidColumnIndex           = ErrorCode{Code: "InvalidColumnIndex", StatusCode: 400}
+	EvaluatorInvalidArguments    = ErrorCode{Code: "EvaluatorInvalidArguments", StatusCode: 400}
+	InvalidRequestParameter      = ErrorCode{Code: "InvalidRequestParameter", StatusCode: 400}
+	InvalidCompressionFormat     = ErrorCode{Code: "InvalidCompressionFormat", StatusCode: 400}
+	CSVParsingError              = ErrorCode{Code: "CSVParsingError", StatusCode: 400}
+	JSONParsingError             = ErrorCode{Code: "JSONParsingError", StatusCode: 400}
 )
 
 type Error struct {
 	ErrorCode
 	Message string `xml:"Message"`
+	// Region is the region that the request should have been sent to, if the request was sent to the wrong region.
+	Region string `xml:"Region,omitempty"`
 }
 
 func (e *Error) Error() string {
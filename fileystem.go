@@ -1,10 +1,20 @@
 package ls3
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"github.com/relvacode/ls3/exception"
 	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
 )
 
+// nullVersionId is the version ID of the current version of an object.
+const nullVersionId = "null"
+
 type BucketFilesystemProvider interface {
 	// ListBuckets lists all available filesystemProvider in the provider.
 	ListBuckets() ([]string, error)
@@ -13,10 +23,47 @@ type BucketFilesystemProvider interface {
 	Open(bucket string) (fs.FS, error)
 }
 
+// WritableBucketFilesystemProvider is a BucketFilesystemProvider that can also provide writable bucket filesystems.
+type WritableBucketFilesystemProvider interface {
+	BucketFilesystemProvider
+
+	// OpenWritable returns a writable filesystem for a given bucket name.
+	// The error MethodNotAllowed is returned if the bucket is not writable.
+	OpenWritable(bucket string) (WritableFS, error)
+}
+
+// errBucketNotWritable is returned by a WritableBucketFilesystemProvider for a bucket that is not writable.
+var errBucketNotWritable = &exception.Error{
+	ErrorCode: exception.MethodNotAllowed,
+	Message:   "The specified bucket is not writable.",
+}
+
+// ManagedBucketFilesystemProvider is a BucketFilesystemProvider that can also create and delete buckets.
+type ManagedBucketFilesystemProvider interface {
+	BucketFilesystemProvider
+
+	// CreateBucket creates a new empty bucket.
+	// The error BucketAlreadyOwnedByYou is returned if the bucket already exists.
+	CreateBucket(bucket string) error
+
+	// DeleteBucket deletes an empty bucket.
+	// The error BucketNotEmpty is returned if the bucket contains any objects.
+	DeleteBucket(bucket string) error
+}
+
+// errBucketsNotManaged is returned by a ManagedBucketFilesystemProvider that cannot create or delete buckets.
+var errBucketsNotManaged = &exception.Error{
+	ErrorCode: exception.MethodNotAllowed,
+	Message:   "Buckets cannot be created or deleted on this server.",
+}
+
 // SingleBucketFilesystem implements BucketFilesystemProvider that
 // always returns the same filesystem for any bucket name provided.
 type SingleBucketFilesystem struct {
 	fs.FS
+
+	// Writable allows objects to be written to the filesystem, if the filesystem is a WritableFS.
+	Writable bool
 }
 
 // ListBuckets always returns the same bucket name.
@@ -29,8 +76,36 @@ func (p *SingleBucketFilesystem) Open(_ string) (fs.FS, error) {
 	return p.FS, nil
 }
 
+func (p *SingleBucketFilesystem) OpenWritable(_ string) (WritableFS, error) {
+	wfs, ok := p.FS.(WritableFS)
+	if !p.Writable || !ok {
+		return nil, errBucketNotWritable
+	}
+
+	return wfs, nil
+}
+
+// OpenBucketConfig returns the filesystem, if it is a WritableFS.
+func (p *SingleBucketFilesystem) OpenBucketConfig(_ string) (WritableFS, error) {
+	wfs, ok := p.FS.(WritableFS)
+	if !ok {
+		return nil, errBucketConfigNotWritable
+	}
+
+	return wfs, nil
+}
+
 type SubdirBucketFilesystem struct {
 	fs.FS
+
+	// SnapshotDir is the directory within each bucket that contains read-only snapshots of the bucket,
+	// such as .zfs/snapshot. If set, each snapshot is provided as a version of the bucket.
+	SnapshotDir string
+
+	// Writable is the list of buckets that objects can be written to.
+	// A bucket name of "*" allows writing to all buckets.
+	// The base filesystem must be a WritableFS that implements fs.SubFS, such as DirFS.
+	Writable []string
 }
 
 // ListBuckets returns all subdirectories of the base filesystem.
@@ -57,9 +132,20 @@ func (p *SubdirBucketFilesystem) ListBuckets() ([]string, error) {
 }
 
 // Open returns a subdirectory of the base filesystem for each bucket.
+// The snapshot directory of the bucket is hidden, so that previous versions are not listed or read as objects.
+func (p *SubdirBucketFilesystem) Open(bucket string) (fs.FS, error) {
+	sub, err := p.openSubdir(bucket)
+	if err != nil || p.SnapshotDir == "" {
+		return sub, err
+	}
+
+	return hiddenDirFS{FS: sub, hidden: path.Clean(p.SnapshotDir)}, nil
+}
+
+// openSubdir returns the subdirectory of the base filesystem for the bucket.
 // The error NoSuchBucket is returned if fs.Stat of the bucket path returns an error.
 // The error InvalidBucketState is returned if fs.Sub returns an error.
-func (p *SubdirBucketFilesystem) Open(bucket string) (fs.FS, error) {
+func (p *SubdirBucketFilesystem) openSubdir(bucket string) (fs.FS, error) {
 	fi, err := fs.Stat(p.FS, bucket)
 	if err != nil || !fi.IsDir() {
 		return nil, &exception.Error{
@@ -78,3 +164,318 @@ func (p *SubdirBucketFilesystem) Open(bucket string) (fs.FS, error) {
 
 	return sub, nil
 }
+
+// OpenWritable returns a writable subdirectory of the base filesystem, if the bucket is writable.
+// Like Open, the snapshot directory of the bucket is hidden, and it cannot be modified.
+func (p *SubdirBucketFilesystem) OpenWritable(bucket string) (WritableFS, error) {
+	var writable bool
+	for _, name := range p.Writable {
+		if name == "*" || name == bucket {
+			writable = true
+			break
+		}
+	}
+
+	if !writable {
+		return nil, errBucketNotWritable
+	}
+
+	sub, err := p.openSubdir(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	wfs, ok := sub.(WritableFS)
+	if !ok {
+		return nil, errBucketNotWritable
+	}
+
+	if p.SnapshotDir == "" {
+		return wfs, nil
+	}
+
+	return hiddenDirWritableFS{
+		hiddenDirFS: hiddenDirFS{FS: wfs, hidden: path.Clean(p.SnapshotDir)},
+		wfs:         wfs,
+	}, nil
+}
+
+// OpenBucketConfig returns a writable subdirectory of the base filesystem for any bucket.
+func (p *SubdirBucketFilesystem) OpenBucketConfig(bucket string) (WritableFS, error) {
+	sub, err := p.openSubdir(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	wfs, ok := sub.(WritableFS)
+	if !ok {
+		return nil, errBucketConfigNotWritable
+	}
+
+	return wfs, nil
+}
+
+// CreateBucket creates a new subdirectory of the base filesystem, if the base filesystem is a WritableFS.
+func (p *SubdirBucketFilesystem) CreateBucket(bucket string) error {
+	wfs, ok := p.FS.(WritableFS)
+	if !ok {
+		return errBucketsNotManaged
+	}
+
+	_, err := fs.Stat(p.FS, bucket)
+	if err == nil {
+		return &exception.Error{
+			ErrorCode: exception.BucketAlreadyOwnedByYou,
+			Message:   "The bucket you tried to create already exists, and you own it.",
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return unwrapFsError(err)
+	}
+
+	return wfs.MkdirAll(bucket, 0755)
+}
+
+// DeleteBucket removes the subdirectory of the bucket, if it contains no objects.
+// Files reserved for use by ls3, such as incomplete multipart uploads, are removed with the bucket.
+func (p *SubdirBucketFilesystem) DeleteBucket(bucket string) error {
+	wfs, ok := p.FS.(WritableFS)
+	if !ok {
+		return errBucketsNotManaged
+	}
+
+	if _, err := p.openSubdir(bucket); err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(p.FS, bucket)
+	if err != nil {
+		return unwrapFsError(err)
+	}
+
+	for _, entry := range entries {
+		if !isReservedName(entry.Name()) {
+			return &exception.Error{
+				ErrorCode: exception.BucketNotEmpty,
+				Message:   "The bucket you tried to delete is not empty.",
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		err = removeAll(wfs, path.Join(bucket, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return wfs.Remove(bucket)
+}
+
+// A BucketVersion is a read-only copy of a bucket filesystem at a point in time.
+type BucketVersion struct {
+	// VersionId is a stable identifier of this version.
+	VersionId string
+	// Name is the name of the snapshot that provides this version.
+	Name string
+	// ModTime is the time the version was created.
+	ModTime time.Time
+	FS      fs.FS
+}
+
+// VersionedBucketFilesystemProvider is a BucketFilesystemProvider that also provides previous versions of each bucket.
+type VersionedBucketFilesystemProvider interface {
+	BucketFilesystemProvider
+
+	// VersioningEnabled returns true if versions are provided for the given bucket.
+	VersioningEnabled(bucket string) bool
+
+	// Versions returns all previous versions of a bucket, newest first.
+	Versions(bucket string) ([]*BucketVersion, error)
+}
+
+// snapshotVersionId returns the version ID of a snapshot with the given name.
+func snapshotVersionId(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:16])
+}
+
+// openBucketVersion returns the filesystem for the given version of a bucket.
+// The version ID "null" is the current version of the bucket.
+// The error NoSuchVersion is returned if the version does not exist.
+func openBucketVersion(provider BucketFilesystemProvider, bucket string, current fs.FS, versionId string) (fs.FS, error) {
+	if versionId == nullVersionId {
+		return current, nil
+	}
+
+	if versioned, ok := provider.(VersionedBucketFilesystemProvider); ok && versioned.VersioningEnabled(bucket) {
+		versions, err := versioned.Versions(bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range versions {
+			if v.VersionId == versionId {
+				return v.FS, nil
+			}
+		}
+	}
+
+	return nil, &exception.Error{
+		ErrorCode: exception.NoSuchVersion,
+		Message:   "The specified version does not exist.",
+	}
+}
+
+// VersioningEnabled returns true if a snapshot directory is configured.
+func (p *SubdirBucketFilesystem) VersioningEnabled(_ string) bool {
+	return p.SnapshotDir != ""
+}
+
+// Versions returns a version for each subdirectory of the snapshot directory of the bucket, newest first.
+// The version ID of each snapshot is derived from the name of the snapshot.
+func (p *SubdirBucketFilesystem) Versions(bucket string) ([]*BucketVersion, error) {
+	if p.SnapshotDir == "" {
+		return nil, nil
+	}
+
+	snapshotDir := path.Join(bucket, p.SnapshotDir)
+
+	entries, err := fs.ReadDir(p.FS, snapshotDir)
+	if err != nil {
+		// A bucket without any snapshots has no previous versions
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   "Unable to list versions of the bucket at this time.",
+		}
+	}
+
+	var versions []*BucketVersion
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		sub, err := fs.Sub(p.FS, path.Join(snapshotDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, &BucketVersion{
+			VersionId: snapshotVersionId(entry.Name()),
+			Name:      entry.Name(),
+			ModTime:   fi.ModTime(),
+			FS:        sub,
+		})
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		if !versions[i].ModTime.Equal(versions[j].ModTime) {
+			return versions[i].ModTime.After(versions[j].ModTime)
+		}
+
+		return versions[i].Name > versions[j].Name
+	})
+
+	return versions, nil
+}
+
+// hiddenDirFS is a filesystem that hides the directory hidden, and everything it contains.
+type hiddenDirFS struct {
+	fs.FS
+	hidden string
+}
+
+// isHidden returns true if name is the hidden directory, or is contained by it.
+func (h hiddenDirFS) isHidden(name string) bool {
+	return name == h.hidden || strings.HasPrefix(name, h.hidden+"/")
+}
+
+func (h hiddenDirFS) Open(name string) (fs.File, error) {
+	if h.isHidden(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, err := h.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hidden directory is removed from the entries of its parent directory
+	if dir, ok := f.(fs.ReadDirFile); ok && name == path.Dir(h.hidden) {
+		return hiddenDirFile{ReadDirFile: dir, hidden: path.Base(h.hidden)}, nil
+	}
+
+	return f, nil
+}
+
+// hiddenDirFile is the parent directory of a hidden directory.
+type hiddenDirFile struct {
+	fs.ReadDirFile
+	hidden string
+}
+
+func (f hiddenDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := f.ReadDirFile.ReadDir(n)
+
+		var visible = entries[:0]
+		for _, entry := range entries {
+			if entry.Name() != f.hidden {
+				visible = append(visible, entry)
+			}
+		}
+
+		// Read again if only the hidden directory was read, as ReadDir(n) must not return an empty list without an error
+		if len(visible) > 0 || err != nil || n <= 0 {
+			return visible, err
+		}
+	}
+}
+
+// hiddenDirWritableFS is a writable filesystem that hides the directory hidden, and refuses to modify anything it contains.
+type hiddenDirWritableFS struct {
+	hiddenDirFS
+	wfs WritableFS
+}
+
+func (h hiddenDirWritableFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	if h.isHidden(dir) {
+		return nil, &fs.PathError{Op: "createtemp", Path: dir, Err: fs.ErrPermission}
+	}
+
+	return h.wfs.CreateTemp(dir, pattern)
+}
+
+func (h hiddenDirWritableFS) Rename(oldpath, newpath string) error {
+	if h.isHidden(oldpath) || h.isHidden(newpath) {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrPermission}
+	}
+
+	return h.wfs.Rename(oldpath, newpath)
+}
+
+func (h hiddenDirWritableFS) Remove(name string) error {
+	if h.isHidden(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+
+	return h.wfs.Remove(name)
+}
+
+func (h hiddenDirWritableFS) MkdirAll(name string, perm fs.FileMode) error {
+	if h.isHidden(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+	}
+
+	return h.wfs.MkdirAll(name, perm)
+}
@@ -7,10 +7,12 @@ require (
 	github.com/gotd/contrib v0.13.0
 	github.com/h2non/filetype v1.1.3
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/prometheus/client_golang v1.14.0
 	github.com/psanford/memfs v0.0.0-20210214183328-a001468d78ef
 	github.com/relvacode/interrupt v0.0.0-20210514162746-a98c3dc2302a
 	github.com/stretchr/testify v1.8.0
 	go.uber.org/zap v1.21.0
+	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a
 )
 
 require (
@@ -20,13 +22,11 @@ require (
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/prometheus/client_golang v1.14.0 // indirect
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.37.0 // indirect
 	github.com/prometheus/procfs v0.8.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.8.0 // indirect
-	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
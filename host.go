@@ -7,6 +7,42 @@ import (
 	"strings"
 )
 
+// validBucketName returns true if name satisfies the S3 bucket naming rules.
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '.' || c == '-':
+			// Must begin and end with a letter or number
+			if i == 0 || i == len(name)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	// Must not be formatted as an IP address
+	if net.ParseIP(name) != nil {
+		return false
+	}
+
+	switch {
+	case strings.Contains(name, ".."),
+		strings.HasPrefix(name, "xn--"),
+		strings.HasPrefix(name, "sthree-"),
+		strings.HasSuffix(name, "-s3alias"),
+		strings.HasSuffix(name, "--ol-s3"):
+		return false
+	}
+
+	return true
+}
+
 func bucketFromPath(r *http.Request) (string, bool, error) {
 	pathComponents := strings.SplitN(strings.TrimLeft(r.URL.Path, "/"), "/", 2)
 	var bucketName = strings.Trim(pathComponents[0], "/")
@@ -25,6 +61,37 @@ func bucketFromPath(r *http.Request) (string, bool, error) {
 	return bucketName, true, nil
 }
 
+// requestHostComponents returns the domain components of the request host, without any port.
+func requestHostComponents(r *http.Request) []string {
+	host, _, _ := net.SplitHostPort(r.Host)
+	if host == "" {
+		host = r.Host
+	}
+
+	return strings.Split(host, ".")
+}
+
+// isDomainRequest returns true if the request host is domain, or a subdomain of domain.
+func isDomainRequest(r *http.Request, domain []string) bool {
+	if len(domain) == 0 {
+		return false
+	}
+
+	hostComponents := requestHostComponents(r)
+	if len(hostComponents) < len(domain) {
+		return false
+	}
+
+	offset := len(hostComponents) - len(domain)
+	for i := range domain {
+		if domain[i] != hostComponents[offset+i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func bucketFromRequest(r *http.Request, domain []string) (string, bool, error) {
 	if len(domain) == 0 {
 		return bucketFromPath(r)
@@ -32,12 +99,7 @@ func bucketFromRequest(r *http.Request, domain []string) (string, bool, error) {
 
 	// Best effort to get the bucket name from the URL host.
 	// Take the lowest domain components of the request host.
-	host, _, _ := net.SplitHostPort(r.Host)
-	if host == "" {
-		host = r.Host
-	}
-
-	hostComponents := strings.Split(host, ".")
+	hostComponents := requestHostComponents(r)
 	if len(hostComponents) < len(domain) {
 		return "", false, &exception.Error{
 			ErrorCode: exception.InvalidRequest,
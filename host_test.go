@@ -145,3 +145,53 @@ func Test_bucketFromRequest(t *testing.T) {
 		assert.True(t, errors.Is(err, &exception.Error{ErrorCode: exception.InvalidRequest}))
 	})
 }
+
+func Test_validBucketName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "bucket", valid: true},
+		{name: "my-bucket.example", valid: true},
+		{name: "123", valid: true},
+		{name: "ab", valid: false},
+		{name: "a23456789012345678901234567890123456789012345678901234567890123", valid: true},
+		{name: "a234567890123456789012345678901234567890123456789012345678901234", valid: false},
+		{name: "Bucket", valid: false},
+		{name: "my_bucket", valid: false},
+		{name: "-bucket", valid: false},
+		{name: "bucket.", valid: false},
+		{name: "my..bucket", valid: false},
+		{name: "192.168.1.1", valid: false},
+		{name: "xn--bucket", valid: false},
+		{name: "sthree-bucket", valid: false},
+		{name: "bucket-s3alias", valid: false},
+		{name: "bucket--ol-s3", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, validBucketName(tt.name))
+		})
+	}
+}
+
+func Test_isDomainRequest(t *testing.T) {
+	tests := []struct {
+		host   string
+		domain []string
+		expect bool
+	}{
+		{host: "website.domain", domain: []string{"website", "domain"}, expect: true},
+		{host: "bucket.website.domain:80", domain: []string{"website", "domain"}, expect: true},
+		{host: "bucket.domain", domain: []string{"website", "domain"}, expect: false},
+		{host: "domain", domain: []string{"website", "domain"}, expect: false},
+		{host: "bucket.domain", domain: nil, expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.expect, isDomainRequest(&http.Request{Host: tt.host}, tt.domain))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package idp
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+const (
+	// CertificateKeyIdPrefix is the prefix of the access key IDs that identify a verified client certificate.
+	// These access key IDs can only be used to look up the identity of a client certificate, never to sign a request.
+	CertificateKeyIdPrefix = "x509:"
+
+	certificateSubjectPrefix = "subject:"
+	certificateSANPrefix     = "san:"
+)
+
+// CertificateSANs returns the subject alternative names of the certificate.
+// DNS names are returned first, followed by email addresses, IP addresses and URIs.
+func CertificateSANs(cert *x509.Certificate) []string {
+	var names = append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+// CertificateKeyIds returns the access key IDs that identify a verified client certificate,
+// in the order they should be looked up. The subject of the certificate is first, followed by each SAN.
+func CertificateKeyIds(cert *x509.Certificate) []string {
+	var keyIds = []string{CertificateKeyIdPrefix + certificateSubjectPrefix + cert.Subject.String()}
+	for _, san := range CertificateSANs(cert) {
+		keyIds = append(keyIds, CertificateKeyIdPrefix+certificateSANPrefix+san)
+	}
+
+	return keyIds
+}
+
+// CertificateIdentity is the identity of a client certificate with a matching subject or SAN.
+type CertificateIdentity struct {
+	Name string
+	// Subject is the distinguished name of the subject of the certificate, such as CN=client,O=Example.
+	Subject string `json:",omitempty"`
+	// SAN are subject alternative names of the certificate, such as a DNS name, email address, IP address or URI.
+	// The identity matches a certificate with any of these names.
+	SAN    OptionalList[string] `json:",omitempty"`
+	Policy []*PolicyStatement
+}
+
+// matches returns true if the identity matches the certificate access key ID, without its prefix.
+func (c *CertificateIdentity) matches(name string) bool {
+	if subject := strings.TrimPrefix(name, certificateSubjectPrefix); subject != name {
+		return c.Subject != "" && c.Subject == subject
+	}
+
+	if san := strings.TrimPrefix(name, certificateSANPrefix); san != name {
+		for _, v := range c.SAN {
+			if v == san {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// CertificateProvider implements Provider for the identities of verified client certificates,
+// using the access key IDs returned by CertificateKeyIds. All other access key IDs are provided by Provider.
+type CertificateProvider struct {
+	Provider
+	// Identities are matched against a certificate in order.
+	Identities []*CertificateIdentity
+}
+
+func (p *CertificateProvider) Get(keyId string) (*Identity, error) {
+	name := strings.TrimPrefix(keyId, CertificateKeyIdPrefix)
+	if name == keyId {
+		if p.Provider == nil {
+			return nil, ErrMissingAccessKeyId
+		}
+
+		return p.Provider.Get(keyId)
+	}
+
+	for _, c := range p.Identities {
+		if c.matches(name) {
+			return &Identity{
+				Name:        c.Name,
+				AccessKeyId: keyId,
+				Policy:      c.Policy,
+			}, nil
+		}
+	}
+
+	return nil, ErrMissingAccessKeyId
+}
@@ -0,0 +1,71 @@
+package idp
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestCertificateKeyIds(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client", Organization: []string{"Example"}},
+		DNSNames:    []string{"client.example.com"},
+		IPAddresses: []net.IP{net.IPv4(10, 0, 0, 1)},
+	}
+
+	assert.Equal(t, []string{
+		"x509:subject:CN=client,O=Example",
+		"x509:san:client.example.com",
+		"x509:san:10.0.0.1",
+	}, CertificateKeyIds(cert))
+}
+
+func TestCertificateProvider_Get(t *testing.T) {
+	provider := &CertificateProvider{
+		Provider: Keyring{TestIdentity.AccessKeyId: TestIdentity},
+		Identities: []*CertificateIdentity{
+			{Name: "by-subject", Subject: "CN=client,O=Example"},
+			{Name: "by-san", SAN: OptionalList[string]{"other.example.com", "client.example.com"}},
+		},
+	}
+
+	t.Run("subject", func(t *testing.T) {
+		identity, err := provider.Get("x509:subject:CN=client,O=Example")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "by-subject", identity.Name)
+		assert.Empty(t, identity.SecretAccessKey)
+	})
+
+	t.Run("san", func(t *testing.T) {
+		identity, err := provider.Get("x509:san:client.example.com")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "by-san", identity.Name)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, err := provider.Get("x509:subject:CN=other")
+		assert.ErrorIs(t, err, ErrMissingAccessKeyId)
+	})
+
+	t.Run("access key id", func(t *testing.T) {
+		identity, err := provider.Get(TestIdentity.AccessKeyId)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, TestIdentity.Name, identity.Name)
+	})
+
+	t.Run("cannot sign", func(t *testing.T) {
+		_, err := GetIdentity(provider, "x509:subject:CN=client,O=Example", "")
+		assert.ErrorIs(t, err, ErrMissingAccessKeyId)
+	})
+}
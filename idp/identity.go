@@ -20,6 +20,8 @@ type Identity struct {
 	AccessKeyId     string
 	SecretAccessKey string
 	Policy          []*PolicyStatement
+	// Session is the temporary session of the identity, or nil if the identity uses long-term credentials.
+	Session *Session `json:"-"`
 }
 
 // PreAuthenticationIdentity is used in logging as the initial identity given to a new request context.
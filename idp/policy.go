@@ -11,10 +11,32 @@ import (
 type Action string
 
 const (
-	GetObject         Action = "s3:GetObject"
-	ListAllMyBuckets  Action = "s3:ListAllMyBuckets"
-	ListBucket        Action = "s3:ListBucket"
-	GetBucketLocation Action = "s3:GetBucketLocation"
+	GetObject                  Action = "s3:GetObject"
+	GetObjectVersion           Action = "s3:GetObjectVersion"
+	GetObjectAttributes        Action = "s3:GetObjectAttributes"
+	GetObjectVersionAttributes Action = "s3:GetObjectVersionAttributes"
+	GetObjectTagging           Action = "s3:GetObjectTagging"
+	GetObjectVersionTagging    Action = "s3:GetObjectVersionTagging"
+	PutObject                  Action = "s3:PutObject"
+	DeleteObject               Action = "s3:DeleteObject"
+	AbortMultipartUpload       Action = "s3:AbortMultipartUpload"
+	ListMultipartUploadParts   Action = "s3:ListMultipartUploadParts"
+	ListAllMyBuckets           Action = "s3:ListAllMyBuckets"
+	CreateBucket               Action = "s3:CreateBucket"
+	DeleteBucket               Action = "s3:DeleteBucket"
+	ListBucket                 Action = "s3:ListBucket"
+	ListBucketVersions         Action = "s3:ListBucketVersions"
+	ListBucketMultipartUploads Action = "s3:ListBucketMultipartUploads"
+	GetBucketLocation          Action = "s3:GetBucketLocation"
+	GetBucketVersioning        Action = "s3:GetBucketVersioning"
+	GetBucketPolicy            Action = "s3:GetBucketPolicy"
+	PutBucketPolicy            Action = "s3:PutBucketPolicy"
+	DeleteBucketPolicy         Action = "s3:DeleteBucketPolicy"
+	GetBucketCORS              Action = "s3:GetBucketCORS"
+	GetBucketWebsite           Action = "s3:GetBucketWebsite"
+	AssumeRole                 Action = "sts:AssumeRole"
+	AssumeRoleWithWebIdentity  Action = "sts:AssumeRoleWithWebIdentity"
+	GetSessionToken            Action = "sts:GetSessionToken"
 )
 
 type Resource string
@@ -385,27 +407,45 @@ func (p *PolicyStatement) AppliesTo(action Action, resource Resource, context Po
 	return MatchesConditions(p.Condition, context)
 }
 
-// EvaluatePolicy returns true if the given concrete action and resource applies to any of the given policies.
-// The default action is to deny.
-func EvaluatePolicy(action Action, resource Resource, policies []*PolicyStatement, context PolicyContextVars) *exception.Error {
-	var success bool
+// PolicyDecision is the outcome of evaluating a set of policies for a concrete action and resource.
+// Decisions are ordered such that the combined decision of more than one set of policies is the greatest decision.
+type PolicyDecision int
+
+const (
+	// ImplicitDeny is the decision when no policy applies.
+	ImplicitDeny PolicyDecision = iota
+	// Allow is the decision when at least one policy allows access, and no policy explicitly denies access.
+	Allow
+	// ExplicitDeny is the decision when any policy explicitly denies access.
+	ExplicitDeny
+)
+
+// DecidePolicy returns the decision of the given policies for the concrete action and resource.
+// An explicit deny from any policy takes precedence over an allow.
+func DecidePolicy(action Action, resource Resource, policies []*PolicyStatement, context PolicyContextVars) PolicyDecision {
+	var decision = ImplicitDeny
 	for _, policy := range policies {
 		// Only interested in explicit denies when at least on policy is successful
-		if success && !policy.Deny {
+		if decision == Allow && !policy.Deny {
 			continue
 		}
 
 		if policy.AppliesTo(action, resource, context) {
 			if policy.Deny {
-				success = false
-				break
+				return ExplicitDeny
 			}
 
-			success = true
+			decision = Allow
 		}
 	}
 
-	if !success {
+	return decision
+}
+
+// EvaluatePolicy returns true if the given concrete action and resource applies to any of the given policies.
+// The default action is to deny.
+func EvaluatePolicy(action Action, resource Resource, policies []*PolicyStatement, context PolicyContextVars) *exception.Error {
+	if DecidePolicy(action, resource, policies, context) != Allow {
 		return &exception.Error{
 			ErrorCode: exception.AccessDenied,
 			Message:   "You do not have permission to access this resource.",
@@ -0,0 +1,35 @@
+package idp
+
+// ResourcePolicyStatement is a PolicyStatement attached to a resource, such as a bucket.
+// Unlike an identity policy, it also names the identities that the statement applies to.
+type ResourcePolicyStatement struct {
+	// Principal is one or more identity names that this policy applies to.
+	// Wildcards may be used, such that "*" applies to every identity, including the public identity.
+	Principal OptionalList[string]
+	PolicyStatement
+}
+
+// AppliesToPrincipal returns true if this policy applies to the identity with the given name.
+func (p *ResourcePolicyStatement) AppliesToPrincipal(name string) bool {
+	for _, rule := range p.Principal {
+		// WildcardMatch never matches an empty name
+		if rule == "*" || WildcardMatch(rule, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecideResourcePolicy returns the decision of the given resource policies for the concrete action and resource,
+// considering only the policies that apply to the principal.
+func DecideResourcePolicy(principal string, action Action, resource Resource, policies []*ResourcePolicyStatement, context PolicyContextVars) PolicyDecision {
+	var applicable []*PolicyStatement
+	for _, policy := range policies {
+		if policy.AppliesToPrincipal(principal) {
+			applicable = append(applicable, &policy.PolicyStatement)
+		}
+	}
+
+	return DecidePolicy(action, resource, applicable, context)
+}
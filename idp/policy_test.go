@@ -295,3 +295,30 @@ func TestMatchesConditions(t *testing.T) {
 		))
 	})
 }
+
+func TestDecideResourcePolicy(t *testing.T) {
+	policies := []*ResourcePolicyStatement{
+		{
+			Principal: []string{"alice", "team-*"},
+			PolicyStatement: PolicyStatement{
+				Action:   []Action{"s3:GetObject"},
+				Resource: []Resource{"bucket/*"},
+			},
+		},
+		{
+			Principal: []string{"*"},
+			PolicyStatement: PolicyStatement{
+				Deny:     true,
+				Action:   []Action{"s3:GetObject"},
+				Resource: []Resource{"bucket/secret"},
+			},
+		},
+	}
+
+	assert.Equal(t, Allow, DecideResourcePolicy("alice", "s3:GetObject", "bucket/object", policies, MapContext{}))
+	assert.Equal(t, Allow, DecideResourcePolicy("team-a", "s3:GetObject", "bucket/object", policies, MapContext{}))
+	assert.Equal(t, ImplicitDeny, DecideResourcePolicy("bob", "s3:GetObject", "bucket/object", policies, MapContext{}))
+	assert.Equal(t, ImplicitDeny, DecideResourcePolicy("alice", "s3:PutObject", "bucket/object", policies, MapContext{}))
+	assert.Equal(t, ExplicitDeny, DecideResourcePolicy("alice", "s3:GetObject", "bucket/secret", policies, MapContext{}))
+	assert.Equal(t, ExplicitDeny, DecideResourcePolicy("bob", "s3:GetObject", "bucket/secret", policies, MapContext{}))
+}
@@ -0,0 +1,392 @@
+package idp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"strings"
+	"time"
+)
+
+const (
+	// SessionAccessKeyIdPrefix is the prefix of the access key ID of every temporary session.
+	SessionAccessKeyIdPrefix = "ASIA"
+
+	// MinSessionDuration is the shortest duration of a temporary session.
+	MinSessionDuration = 15 * time.Minute
+	// DefaultSessionDuration is the duration of a temporary session if no duration is requested.
+	DefaultSessionDuration = time.Hour
+	// MaxSessionDuration is the longest duration of a temporary session.
+	MaxSessionDuration = 12 * time.Hour
+)
+
+var ErrInvalidSessionToken = &exception.Error{
+	ErrorCode: exception.InvalidToken,
+	Message:   "The provided token is malformed or otherwise invalid.",
+}
+
+var ErrExpiredSessionToken = &exception.Error{
+	ErrorCode: exception.ExpiredToken,
+	Message:   "The provided token has expired.",
+}
+
+// Session describes the temporary credentials of an identity.
+// A Session is sealed into the session token given to the client, so it must not contain any secrets.
+type Session struct {
+	AccessKeyId string
+	// Parent is the access key ID of the identity that created the session.
+	// It is empty if the session was created from a web identity token.
+	Parent string `json:",omitempty"`
+	// Role is the name of the assumed role, or empty if the session uses the policy of the parent identity.
+	Role       string `json:",omitempty"`
+	Expiration time.Time
+	// Policy is an optional session policy.
+	// If set, access is only allowed if it is allowed by both the session policy and the policy of the identity.
+	Policy []*PolicyStatement `json:",omitempty"`
+	// WebIdentity is the identity of the web identity token the session was created from, if any.
+	WebIdentity *WebIdentity `json:",omitempty"`
+}
+
+// Credentials are the temporary credentials of a new session.
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// SessionTokenProvider is a Provider that can also return the identity of a temporary session.
+type SessionTokenProvider interface {
+	Provider
+
+	// GetSession returns the identity of the session with the given access key ID and session token.
+	GetSession(keyId string, token string) (*Identity, error)
+}
+
+// GetIdentity returns the identity of keyId from provider.
+// If token is not empty, the identity is a temporary session that must be known to provider.
+// The identity of a client certificate cannot be used to sign a request, so its access key IDs are never found.
+func GetIdentity(provider Provider, keyId string, token string) (*Identity, error) {
+	if strings.HasPrefix(keyId, CertificateKeyIdPrefix) {
+		return nil, ErrMissingAccessKeyId
+	}
+
+	if token == "" {
+		return provider.Get(keyId)
+	}
+
+	sessions, ok := provider.(SessionTokenProvider)
+	if !ok {
+		return nil, ErrInvalidSessionToken
+	}
+
+	return sessions.GetSession(keyId, token)
+}
+
+// SessionIssuer creates temporary sessions.
+type SessionIssuer interface {
+	// NewSession creates a new temporary session for parent that lasts for duration.
+	// If role is not empty, the session assumes that role.
+	// If policy is not nil, it limits the access of the session.
+	NewSession(parent *Identity, role string, policy []*PolicyStatement, duration time.Duration) (*Credentials, error)
+
+	// NewWebIdentitySession creates a new temporary session that assumes role using a web identity token.
+	// If policy is not nil, it limits the access of the session.
+	NewWebIdentitySession(token string, role string, policy []*PolicyStatement, duration time.Duration) (*Credentials, *WebIdentity, error)
+}
+
+// Role is an identity that can be assumed by other identities using a temporary session.
+type Role struct {
+	Name string
+	// Trust is the names of the identities that are allowed to assume the role, where * allows any identity.
+	Trust OptionalList[string]
+	// WebIdentity are the web identity tokens that are allowed to assume the role.
+	WebIdentity []*RoleWebIdentity `json:",omitempty"`
+	Policy      []*PolicyStatement
+}
+
+// RoleWebIdentity allows the web identity tokens of an issuer to assume a role.
+type RoleWebIdentity struct {
+	// Issuer is the iss claim of the tokens.
+	Issuer string
+	// Condition restricts which tokens can assume the role, using the ls3:jwt:<claim> context keys.
+	Condition PolicyConditions `json:",omitempty"`
+}
+
+// Trusts returns true if the role can be assumed by the identity with the given name.
+func (r *Role) Trusts(name string) bool {
+	for _, trusted := range r.Trust {
+		if trusted == "*" || trusted == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TrustsWebIdentity returns true if the role can be assumed with the given web identity.
+func (r *Role) TrustsWebIdentity(identity *WebIdentity) bool {
+	for _, trusted := range r.WebIdentity {
+		if trusted != nil && trusted.Issuer == identity.Issuer && MatchesConditions(trusted.Condition, identity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var ErrNoSuchRole = &exception.Error{
+	ErrorCode: exception.AccessDenied,
+	Message:   "The specified role does not exist, or you are not allowed to assume it.",
+}
+
+// RoleProvider provides roles by name.
+type RoleProvider interface {
+	// GetRole returns the role with the given name.
+	// It should return ErrNoSuchRole if the role does not exist.
+	GetRole(name string) (*Role, error)
+}
+
+// Roles implements RoleProvider for a static set of roles, where the key is the name of the role.
+type Roles map[string]*Role
+
+func (r Roles) GetRole(name string) (*Role, error) {
+	role, ok := r[name]
+	if !ok {
+		return nil, ErrNoSuchRole
+	}
+
+	return role, nil
+}
+
+// SessionProvider implements SessionTokenProvider by sealing each session into its session token using HMAC-SHA256.
+// No session state is kept, so a session is valid until it expires,
+// or until its parent identity or role no longer exist.
+// The secret access key of a session is derived from the session token, and is never stored.
+type SessionProvider struct {
+	Provider
+	// Roles are the roles that can be assumed. If nil, no roles can be assumed.
+	Roles RoleProvider
+	// Issuers are the trusted issuers of web identity tokens.
+	Issuers WebIdentityIssuers
+	// Key is the secret key used to seal session tokens.
+	Key []byte
+
+	// timeNow is a function that returns the current time.
+	// Used for testing, if nil then time.Now is used.
+	timeNow func() time.Time
+}
+
+func (p *SessionProvider) now() time.Time {
+	if p.timeNow == nil {
+		return time.Now()
+	}
+	return p.timeNow()
+}
+
+// mac returns the HMAC-SHA256 of the payload of a session token for the given purpose.
+func (p *SessionProvider) mac(purpose string, payload string) []byte {
+	h := hmac.New(sha256.New, p.Key)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+
+	return h.Sum(nil)
+}
+
+// newSessionAccessKeyId returns a new random access key ID for a temporary session.
+func newSessionAccessKeyId() (string, error) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+	var b = make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+
+	return SessionAccessKeyIdPrefix + string(b), nil
+}
+
+// NewSession implements SessionIssuer.
+// A role can only be assumed if it trusts parent, and temporary sessions cannot create another session.
+func (p *SessionProvider) NewSession(parent *Identity, role string, policy []*PolicyStatement, duration time.Duration) (*Credentials, error) {
+	if parent.Session != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "Temporary credentials cannot be used to create another session.",
+		}
+	}
+
+	if err := checkSessionDuration(duration); err != nil {
+		return nil, err
+	}
+
+	if role != "" {
+		r, err := p.getRole(role)
+		if err != nil {
+			return nil, err
+		}
+
+		if !r.Trusts(parent.Name) {
+			return nil, ErrNoSuchRole
+		}
+	}
+
+	return p.seal(&Session{
+		Parent: parent.AccessKeyId,
+		Role:   role,
+		Policy: policy,
+	}, duration)
+}
+
+// NewWebIdentitySession implements SessionIssuer.
+// The token must be issued by a trusted issuer, and the role must trust the web identity of the token.
+func (p *SessionProvider) NewWebIdentitySession(token string, role string, policy []*PolicyStatement, duration time.Duration) (*Credentials, *WebIdentity, error) {
+	if err := checkSessionDuration(duration); err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := p.Issuers.Verify(token, p.now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := p.getRole(role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !r.TrustsWebIdentity(identity) {
+		return nil, nil, ErrNoSuchRole
+	}
+
+	credentials, err := p.seal(&Session{
+		Role:        role,
+		Policy:      policy,
+		WebIdentity: identity,
+	}, duration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return credentials, identity, nil
+}
+
+// checkSessionDuration returns an error if duration is not a valid session duration.
+func checkSessionDuration(duration time.Duration) error {
+	if duration < MinSessionDuration || duration > MaxSessionDuration {
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "The requested session duration is not valid.",
+		}
+	}
+
+	return nil
+}
+
+// getRole returns the role with the given name.
+func (p *SessionProvider) getRole(name string) (*Role, error) {
+	if p.Roles == nil {
+		return nil, ErrNoSuchRole
+	}
+
+	return p.Roles.GetRole(name)
+}
+
+// seal gives the session a new access key ID and expiration, and returns its credentials.
+func (p *SessionProvider) seal(session *Session, duration time.Duration) (*Credentials, error) {
+	keyId, err := newSessionAccessKeyId()
+	if err != nil {
+		return nil, err
+	}
+
+	session.AccessKeyId = keyId
+	session.Expiration = p.now().Add(duration).UTC().Truncate(time.Second)
+
+	b, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	return &Credentials{
+		AccessKeyId:     keyId,
+		SecretAccessKey: base64.StdEncoding.EncodeToString(p.mac("secret", payload)),
+		SessionToken:    payload + "." + base64.RawURLEncoding.EncodeToString(p.mac("token", payload)),
+		Expiration:      session.Expiration,
+	}, nil
+}
+
+// GetSession implements SessionTokenProvider.
+func (p *SessionProvider) GetSession(keyId string, token string) (*Identity, error) {
+	payload, sealed, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidSessionToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || !hmac.Equal(mac, p.mac("token", payload)) {
+		return nil, ErrInvalidSessionToken
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidSessionToken
+	}
+
+	var session Session
+	err = json.Unmarshal(b, &session)
+	if err != nil || session.AccessKeyId != keyId {
+		return nil, ErrInvalidSessionToken
+	}
+
+	if !p.now().Before(session.Expiration) {
+		return nil, ErrExpiredSessionToken
+	}
+
+	identity := &Identity{
+		AccessKeyId:     keyId,
+		SecretAccessKey: base64.StdEncoding.EncodeToString(p.mac("secret", payload)),
+		Session:         &session,
+	}
+
+	if session.WebIdentity != nil {
+		// The session is only valid while its issuer is trusted
+		if p.Issuers.Get(session.WebIdentity.Issuer) == nil || session.Role == "" {
+			return nil, ErrInvalidSessionToken
+		}
+	} else {
+		// The session is only valid while its parent identity exists
+		parent, err := p.Provider.Get(session.Parent)
+		if errors.Is(err, ErrMissingAccessKeyId) {
+			return nil, ErrInvalidSessionToken
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		identity.Name = parent.Name
+		identity.Policy = parent.Policy
+	}
+
+	if session.Role != "" {
+		role, err := p.getRole(session.Role)
+		if err != nil {
+			return nil, ErrInvalidSessionToken
+		}
+
+		identity.Name = role.Name
+		identity.Policy = role.Policy
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,159 @@
+package idp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testSessionProvider() *SessionProvider {
+	return &SessionProvider{
+		Provider: Keyring{
+			TestIdentity.AccessKeyId: &Identity{
+				Name:            "alice",
+				AccessKeyId:     TestIdentity.AccessKeyId,
+				SecretAccessKey: TestIdentity.SecretAccessKey,
+				Policy:          TestIdentity.Policy,
+			},
+		},
+		Roles: Roles{
+			"reader": &Role{
+				Name:  "reader",
+				Trust: OptionalList[string]{"alice"},
+				Policy: []*PolicyStatement{
+					{Action: []Action{GetObject}, Resource: []Resource{"*"}},
+				},
+			},
+			"admin": &Role{
+				Name:  "admin",
+				Trust: OptionalList[string]{"bob"},
+			},
+		},
+		Key: []byte("session-key"),
+	}
+}
+
+func TestSessionProvider_GetSession(t *testing.T) {
+	p := testSessionProvider()
+	parent, _ := p.Get(TestIdentity.AccessKeyId)
+
+	policy := []*PolicyStatement{
+		{Action: []Action{GetObject}, Resource: []Resource{"bucket/*"}},
+	}
+
+	credentials, err := p.NewSession(parent, "", policy, time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 20, len(credentials.AccessKeyId))
+	assert.Equal(t, SessionAccessKeyIdPrefix, credentials.AccessKeyId[:4])
+
+	t.Run("valid", func(t *testing.T) {
+		identity, err := GetIdentity(p, credentials.AccessKeyId, credentials.SessionToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "alice", identity.Name)
+		assert.Equal(t, credentials.SecretAccessKey, identity.SecretAccessKey)
+		assert.Equal(t, parent.Policy, identity.Policy)
+		if assert.NotNil(t, identity.Session) {
+			assert.Equal(t, TestIdentity.AccessKeyId, identity.Session.Parent)
+			assert.Len(t, identity.Session.Policy, 1)
+		}
+	})
+
+	t.Run("other access key", func(t *testing.T) {
+		_, err := p.GetSession(TestIdentity.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := p.GetSession(credentials.AccessKeyId, "e30"+credentials.SessionToken[3:])
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other := testSessionProvider()
+		other.Key = []byte("other-key")
+
+		_, err := other.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := testSessionProvider()
+		expired.timeNow = func() time.Time {
+			return time.Now().Add(2 * time.Hour)
+		}
+
+		_, err := expired.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrExpiredSessionToken)
+	})
+
+	t.Run("parent removed", func(t *testing.T) {
+		removed := testSessionProvider()
+		removed.Provider = Keyring{}
+
+		_, err := removed.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		_, err := GetIdentity(p.Provider, credentials.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+	})
+}
+
+func TestSessionProvider_NewSession(t *testing.T) {
+	p := testSessionProvider()
+	parent, _ := p.Get(TestIdentity.AccessKeyId)
+
+	t.Run("role", func(t *testing.T) {
+		credentials, err := p.NewSession(parent, "reader", nil, time.Hour)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		identity, err := p.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "reader", identity.Name)
+			assert.Equal(t, "reader", identity.Session.Role)
+			assert.Len(t, identity.Policy, 1)
+		}
+	})
+
+	t.Run("untrusted role", func(t *testing.T) {
+		_, err := p.NewSession(parent, "admin", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrNoSuchRole)
+	})
+
+	t.Run("no such role", func(t *testing.T) {
+		_, err := p.NewSession(parent, "missing", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrNoSuchRole)
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		_, err := p.NewSession(parent, "", nil, time.Minute)
+		assert.Error(t, err)
+
+		_, err = p.NewSession(parent, "", nil, 13*time.Hour)
+		assert.Error(t, err)
+	})
+
+	t.Run("from session", func(t *testing.T) {
+		credentials, err := p.NewSession(parent, "", nil, time.Hour)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		identity, err := p.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = p.NewSession(identity, "", nil, time.Hour)
+		assert.Error(t, err)
+	})
+}
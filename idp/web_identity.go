@@ -0,0 +1,435 @@
+package idp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WebIdentityClaimPrefix is the prefix of the policy context keys of the claims of a web identity token.
+	WebIdentityClaimPrefix = "ls3:jwt:"
+
+	// webIdentityLeeway is the allowed clock skew when checking the validity period of a web identity token.
+	webIdentityLeeway = time.Minute
+	// jwksReloadInterval is the minimum time between reloading the JWKS file of an issuer for an unknown key.
+	jwksReloadInterval = time.Minute
+)
+
+var ErrInvalidWebIdentityToken = &exception.Error{
+	ErrorCode: exception.InvalidIdentityToken,
+	Message:   "The web identity token that was passed could not be validated.",
+}
+
+var ErrExpiredWebIdentityToken = &exception.Error{
+	ErrorCode: exception.ExpiredToken,
+	Message:   "The web identity token that was passed is expired or is not yet valid.",
+}
+
+// WebIdentity is the verified identity of a web identity token.
+type WebIdentity struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	// Claims are the string, number and boolean claims of the token.
+	Claims map[string]string
+}
+
+// Get implements PolicyContextVars for the claims of the token, using the ls3:jwt:<claim> context keys.
+func (w *WebIdentity) Get(k string) (string, bool) {
+	if !strings.HasPrefix(k, WebIdentityClaimPrefix) {
+		return "", false
+	}
+
+	v, ok := w.Claims[strings.TrimPrefix(k, WebIdentityClaimPrefix)]
+	return v, ok
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the RSA or ECDSA public key of the JSON Web Key.
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(v string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("key %q: invalid parameter", k.Kid)
+		}
+
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q: invalid exponent", k.Kid)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("key %q: unsupported curve %q", k.Kid, k.Crv)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("key %q: point is not on curve", k.Kid)
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %q", k.Kid, k.Kty)
+	}
+}
+
+// webKey is a public key of an issuer.
+type webKey struct {
+	Kid string
+	Key crypto.PublicKey
+}
+
+// readJWKS reads the signing keys of a JSON Web Key Set file.
+// Keys that are not signing keys, or have an unsupported type, are ignored.
+func readJWKS(path string) ([]*webKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+
+	err = json.Unmarshal(b, &set)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var keys []*webKey
+	for _, k := range set.Keys {
+		if k == nil || k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, &webKey{Kid: k.Kid, Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no supported signing keys", path)
+	}
+
+	return keys, nil
+}
+
+// WebIdentityIssuer is a trusted issuer of web identity tokens, such as an OpenID Connect provider.
+// The signing keys of the issuer are read from a local JWKS file.
+type WebIdentityIssuer struct {
+	// Issuer is the expected iss claim of each token.
+	Issuer string
+	// Audience is one or more accepted aud claims of each token.
+	Audience OptionalList[string]
+	// JWKS is the path to the JSON Web Key Set file that contains the signing keys of the issuer.
+	JWKS string
+
+	mx       sync.Mutex
+	keys     []*webKey
+	loadedAt time.Time
+}
+
+// Load reads the signing keys of the issuer from its JWKS file.
+func (iss *WebIdentityIssuer) Load() error {
+	if iss.Issuer == "" || len(iss.Audience) == 0 {
+		return errors.New("a web identity issuer must have an Issuer and at least one Audience")
+	}
+
+	keys, err := readJWKS(iss.JWKS)
+	if err != nil {
+		return err
+	}
+
+	iss.mx.Lock()
+	defer iss.mx.Unlock()
+
+	iss.keys = keys
+	iss.loadedAt = time.Now()
+
+	return nil
+}
+
+// signingKeys returns the keys of the issuer that could have signed a token with the given key ID.
+// The JWKS file is read again if no key matches, so that rotated keys are found without a restart.
+func (iss *WebIdentityIssuer) signingKeys(kid string) []crypto.PublicKey {
+	iss.mx.Lock()
+	defer iss.mx.Unlock()
+
+	find := func() []crypto.PublicKey {
+		var found []crypto.PublicKey
+		for _, k := range iss.keys {
+			if kid == "" || k.Kid == kid {
+				found = append(found, k.Key)
+			}
+		}
+
+		return found
+	}
+
+	found := find()
+	if len(found) == 0 && time.Since(iss.loadedAt) > jwksReloadInterval {
+		iss.loadedAt = time.Now()
+		if keys, err := readJWKS(iss.JWKS); err == nil {
+			iss.keys = keys
+			found = find()
+		}
+	}
+
+	return found
+}
+
+// verifySignature verifies the JWS signature of signingInput using any of the given keys.
+func verifySignature(alg string, keys []crypto.PublicKey, signingInput string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	for _, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if alg == "ES256" && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(key, digest[:], r, s) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// decodeTokenPart decodes a base64url encoded part of a JWT as JSON into v.
+func decodeTokenPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// claimString returns a scalar claim as a string.
+func claimString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	default:
+		return "", false
+	}
+}
+
+// claimTime returns a NumericDate claim as a time.
+func claimTime(claims map[string]any, name string) (time.Time, bool) {
+	n, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(seconds), 0), true
+}
+
+// Verify verifies a web identity token issued by this issuer at the time now, and returns its identity.
+// The token must be a JWT signed with RS256 or ES256 that is valid at the time now.
+func (iss *WebIdentityIssuer) Verify(token string, now time.Time) (*WebIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	switch header.Alg {
+	case "RS256", "ES256":
+	default:
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	if !verifySignature(header.Alg, iss.signingKeys(header.Kid), parts[0]+"."+parts[1], signature) {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	var claims map[string]any
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != iss.Issuer {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	expires, ok := claimTime(claims, "exp")
+	if !ok || !now.Before(expires.Add(webIdentityLeeway)) {
+		return nil, ErrExpiredWebIdentityToken
+	}
+
+	if notBefore, ok := claimTime(claims, "nbf"); ok && now.Add(webIdentityLeeway).Before(notBefore) {
+		return nil, ErrExpiredWebIdentityToken
+	}
+
+	identity := &WebIdentity{
+		Issuer: iss.Issuer,
+		Claims: make(map[string]string, len(claims)),
+	}
+
+	identity.Subject, _ = claims["sub"].(string)
+
+	// The audience is either a string or a list of strings
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+
+	for _, aud := range audiences {
+		for _, accepted := range iss.Audience {
+			if aud == accepted {
+				identity.Audience = aud
+			}
+		}
+	}
+
+	if identity.Subject == "" || identity.Audience == "" {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	for name, v := range claims {
+		if s, ok := claimString(v); ok {
+			identity.Claims[name] = s
+		}
+	}
+
+	identity.Claims["aud"] = identity.Audience
+
+	return identity, nil
+}
+
+// WebIdentityIssuers are the trusted issuers of web identity tokens.
+type WebIdentityIssuers []*WebIdentityIssuer
+
+// Get returns the issuer with the given iss claim, or nil if the issuer is not trusted.
+func (issuers WebIdentityIssuers) Get(issuer string) *WebIdentityIssuer {
+	for _, iss := range issuers {
+		if iss.Issuer == issuer {
+			return iss
+		}
+	}
+
+	return nil
+}
+
+// Verify verifies a web identity token from any of the trusted issuers.
+func (issuers WebIdentityIssuers) Verify(token string, now time.Time) (*WebIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	// The issuer is read before the token is verified only to choose which keys to verify it with
+	var claims struct {
+		Iss string `json:"iss"`
+	}
+
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	iss := issuers.Get(claims.Iss)
+	if iss == nil {
+		return nil, ErrInvalidWebIdentityToken
+	}
+
+	return iss.Verify(token, now)
+}
@@ -0,0 +1,244 @@
+package idp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebIdentityIssuer = "https://token.example.com"
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func testEncodeBigInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// testWriteJWKS writes a JWKS file that contains the public keys of testRSAKey and testECKey.
+func testWriteJWKS(t *testing.T) string {
+	b, _ := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   testEncodeBigInt(testRSAKey.N, 0),
+				"e":   testEncodeBigInt(big.NewInt(int64(testRSAKey.E)), 0),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   testEncodeBigInt(testECKey.X, 32),
+				"y":   testEncodeBigInt(testECKey.Y, 32),
+			},
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	_ = os.WriteFile(path, b, 0644)
+
+	return path
+}
+
+// testSignJWT returns a JWT with the given claims, signed with testRSAKey for RS256 or testECKey for ES256.
+func testSignJWT(alg, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, testRSAKey, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, testECKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testWebIdentityClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":        testWebIdentityIssuer,
+		"aud":        "ls3",
+		"sub":        "repo:example/docs:ref:refs/heads/main",
+		"repository": "example/docs",
+		"exp":        now.Add(time.Hour).Unix(),
+		"iat":        now.Unix(),
+	}
+}
+
+func TestWebIdentityIssuer_Verify(t *testing.T) {
+	issuer := &WebIdentityIssuer{
+		Issuer:   testWebIdentityIssuer,
+		Audience: OptionalList[string]{"ls3"},
+		JWKS:     testWriteJWKS(t),
+	}
+
+	if !assert.NoError(t, issuer.Load()) {
+		return
+	}
+
+	now := time.Now()
+
+	t.Run("RS256", func(t *testing.T) {
+		identity, err := issuer.Verify(testSignJWT("RS256", "rsa", testWebIdentityClaims(now)), now)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "repo:example/docs:ref:refs/heads/main", identity.Subject)
+			assert.Equal(t, "ls3", identity.Audience)
+
+			repository, _ := identity.Get("ls3:jwt:repository")
+			assert.Equal(t, "example/docs", repository)
+		}
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		_, err := issuer.Verify(testSignJWT("ES256", "ec", testWebIdentityClaims(now)), now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("audience list", func(t *testing.T) {
+		claims := testWebIdentityClaims(now)
+		claims["aud"] = []string{"other", "ls3"}
+
+		_, err := issuer.Verify(testSignJWT("RS256", "rsa", claims), now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		claims := testWebIdentityClaims(now)
+		claims["aud"] = "other"
+
+		_, err := issuer.Verify(testSignJWT("RS256", "rsa", claims), now)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		claims := testWebIdentityClaims(now)
+		claims["iss"] = "https://other.example.com"
+
+		_, err := issuer.Verify(testSignJWT("RS256", "rsa", claims), now)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := issuer.Verify(testSignJWT("RS256", "rsa", testWebIdentityClaims(now)), now.Add(2*time.Hour))
+		assert.ErrorIs(t, err, ErrExpiredWebIdentityToken)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		_, err := issuer.Verify(testSignJWT("RS256", "ec", testWebIdentityClaims(now)), now)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		claims := testWebIdentityClaims(now)
+		claims["sub"] = "repo:example/other:ref:refs/heads/main"
+
+		// Combine the header and signature of one token with the claims of another
+		token := strings.Split(testSignJWT("RS256", "rsa", testWebIdentityClaims(now)), ".")
+		other := strings.Split(testSignJWT("RS256", "rsa", claims), ".")
+
+		_, err := issuer.Verify(token[0]+"."+other[1]+"."+token[2], now)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		token := strings.Split(testSignJWT("RS256", "rsa", testWebIdentityClaims(now)), ".")
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+		_, err := issuer.Verify(header+"."+token[1]+".", now)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+}
+
+func TestSessionProvider_NewWebIdentitySession(t *testing.T) {
+	issuer := &WebIdentityIssuer{
+		Issuer:   testWebIdentityIssuer,
+		Audience: OptionalList[string]{"ls3"},
+		JWKS:     testWriteJWKS(t),
+	}
+
+	if !assert.NoError(t, issuer.Load()) {
+		return
+	}
+
+	p := testSessionProvider()
+	p.Issuers = WebIdentityIssuers{issuer}
+	p.Roles = Roles{
+		"docs": &Role{
+			Name: "docs",
+			WebIdentity: []*RoleWebIdentity{
+				{
+					Issuer: testWebIdentityIssuer,
+					Condition: PolicyConditions{
+						StringEquals: {"ls3:jwt:repository": {"example/docs"}},
+					},
+				},
+			},
+		},
+	}
+
+	now := time.Now()
+
+	t.Run("trusted", func(t *testing.T) {
+		credentials, webIdentity, err := p.NewWebIdentitySession(testSignJWT("ES256", "ec", testWebIdentityClaims(now)), "docs", nil, time.Hour)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "repo:example/docs:ref:refs/heads/main", webIdentity.Subject)
+
+		identity, err := p.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "docs", identity.Name)
+			assert.Equal(t, "", identity.Session.Parent)
+
+			sub, _ := identity.Session.WebIdentity.Get("ls3:jwt:sub")
+			assert.Equal(t, "repo:example/docs:ref:refs/heads/main", sub)
+		}
+
+		// The session is invalid once the issuer is no longer trusted
+		p.Issuers = nil
+		_, err = p.GetSession(credentials.AccessKeyId, credentials.SessionToken)
+		assert.ErrorIs(t, err, ErrInvalidSessionToken)
+		p.Issuers = WebIdentityIssuers{issuer}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		claims := testWebIdentityClaims(now)
+		claims["repository"] = "example/other"
+
+		_, _, err := p.NewWebIdentitySession(testSignJWT("ES256", "ec", claims), "docs", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrNoSuchRole)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, _, err := p.NewWebIdentitySession("invalid", "docs", nil, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidWebIdentityToken)
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package ls3
+
+import "io/fs"
+
+// fileInode always returns 0 on platforms without inode numbers.
+func fileInode(_ fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ls3
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode returns the inode number of the file described by fi.
+// Returns 0 if the inode number is not available.
+func fileInode(fi fs.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+
+	return 0
+}
@@ -16,10 +16,13 @@ import (
 // errEndOfIteration is a special sentinel error used for walking filesystem paths in ListObjectsV2.
 var errEndOfIteration = errors.New("end iteration")
 
+// listObjectsMaxKeysLimit is the default, and maximum, number of keys returned by a list request.
+const listObjectsMaxKeysLimit = 1000
+
 func listObjectsMaxKeys(r *http.Request) (int, error) {
 	maxKeysQuery := r.URL.Query().Get("max-keys")
 	if maxKeysQuery == "" {
-		return 1000, nil
+		return listObjectsMaxKeysLimit, nil
 	}
 
 	maxKeys, err := strconv.Atoi(maxKeysQuery)
@@ -31,6 +34,10 @@ func listObjectsMaxKeys(r *http.Request) (int, error) {
 		}
 	}
 
+	if maxKeys > listObjectsMaxKeysLimit {
+		return listObjectsMaxKeysLimit, nil
+	}
+
 	return maxKeys, nil
 }
 
@@ -75,8 +82,20 @@ type BucketIterator struct {
 	Continue    string
 
 	seekObject string
+	startAt    string
 	fs         fs.FS
 	prefixes   map[string]struct{}
+
+	etags      *ETagCache
+	etagPrefix string
+}
+
+// UseETagCache sets the ETag of each object returned by PrefixScan
+// using the given cache, where bucket identifies the filesystem of this iterator within the cache.
+// Objects are never read to compute their ETag, so only objects with a cached or stored ETag have an ETag.
+func (it *BucketIterator) UseETagCache(cache *ETagCache, bucket string) {
+	it.etags = cache
+	it.etagPrefix = bucket + "/"
 }
 
 func (it *BucketIterator) CommonPrefixes() (prefixes []CommonPrefixes) {
@@ -103,6 +122,12 @@ func (it *BucketIterator) Seek(after string) {
 	it.seekObject = after
 }
 
+// StartAt discards all objects before key during the next PrefixScan, whether or not key exists.
+// Unlike Seek, the object key itself is not discarded.
+func (it *BucketIterator) StartAt(key string) {
+	it.startAt = key
+}
+
 func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyEncoding bool, maxKeys int) ([]Contents, error) {
 	var (
 		contents     []Contents
@@ -120,6 +145,10 @@ func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyE
 		scanPath = "."
 	}
 
+	if isReservedPath(scanPath) {
+		return nil, nil
+	}
+
 	_ = fs.WalkDir(it.fs, scanPath, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
 
@@ -137,6 +166,14 @@ func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyE
 		var relPath = strings.Trim(strings.TrimPrefix(filePath, scanPath), "/")
 		var objectPath = path.Join(basePath, relPath)
 
+		// Files and directories used by ls3 itself are never listed
+		if filePath != scanPath && isReservedName(d.Name()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		// It an iteration seek is requested,
 		// then ignore all objects until the object path equals the seek object
 		if shouldSkip {
@@ -150,6 +187,11 @@ func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyE
 		if d.IsDir() {
 			// Inner directory pruning, as long as this path is not the root path
 			if filePath != scanPath {
+				// Every object in a directory that sorts before the start key, and does not contain it, is before the start key
+				if dirPrefix := objectPath + "/"; dirPrefix < it.startAt && !strings.HasPrefix(it.startAt, dirPrefix) {
+					return fs.SkipDir
+				}
+
 				// If entry is a directory, and an object prefix is set,
 				// Signal to WalkDir that this directory should be skipped if it doesn't have the prefix
 				if objectPrefix != "" && !strings.HasPrefix(relPath, objectPrefix) {
@@ -167,6 +209,10 @@ func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyE
 			return nil
 		}
 
+		if objectPath < it.startAt {
+			return nil
+		}
+
 		// If a delimiter is provided, check if this relpath contains the delimiter.
 		// If it does then don't add the object as a key, but instead add it to the list of common prefixes.
 		if delimiter != "" {
@@ -193,7 +239,16 @@ func (it *BucketIterator) PrefixScan(prefix string, delimiter string, objectKeyE
 			urlEncodedObjectPath = encodePath(urlEncodedObjectPath)
 		}
 
+		var etag string
+		if it.etags != nil {
+			// Listing an object does not read it, so an object without a cached ETag is listed without an ETag
+			if cached, ok := it.etags.Cached(it.fs, it.etagPrefix+objectPath, filePath, fi); ok {
+				etag = strconv.Quote(cached)
+			}
+		}
+
 		contents = append(contents, Contents{
+			ETag:         etag,
 			LastModified: fi.ModTime().UTC(),
 			Size:         int(fi.Size()),
 			Key:          urlEncodedObjectPath,
@@ -0,0 +1,137 @@
+package ls3
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/security"
+	"go.uber.org/zap"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	lockoutKindClientIP    = "client_ip"
+	lockoutKindAccessKeyId = "access_key_id"
+)
+
+// isAuthenticationFailure returns true if the error is caused by a request signed with an unknown access key id or secret.
+func isAuthenticationFailure(err *exception.Error) bool {
+	return err.ErrorCode == exception.SignatureDoesNotMatch || err.ErrorCode == exception.InvalidAccessKeyId
+}
+
+// lockoutTarget is a key of a request that is tracked by a lockout.
+type lockoutTarget struct {
+	lockout *security.Lockout
+	kind    string
+	key     string
+}
+
+// lockoutTargets returns the client IP of the request, and the access key id it claims to be signed by,
+// along with the lockout that tracks each of them.
+func (s *Server) lockoutTargets(ctx *RequestContext, accessKeyId string) []lockoutTarget {
+	return []lockoutTarget{
+		{s.clientLockout, lockoutKindClientIP, clientLockoutKey(ctx)},
+		{s.keyLockout, lockoutKindAccessKeyId, accessKeyId},
+	}
+}
+
+// checkLockout returns SlowDown if the client IP of the request, or the access key id it claims to be signed by, is locked out.
+func (s *Server) checkLockout(ctx *RequestContext, accessKeyId string) *exception.Error {
+	var now = time.Now()
+
+	for _, check := range s.lockoutTargets(ctx, accessKeyId) {
+		if check.lockout == nil || check.key == "" {
+			continue
+		}
+
+		remaining, locked := check.lockout.Locked(check.key, now)
+		if !locked {
+			continue
+		}
+
+		statLockoutRejections.WithLabelValues(check.kind).Add(1)
+
+		// Round up so that clients do not retry before the lock out ends
+		ctx.Header().Set("Retry-After", strconv.FormatInt(int64((remaining+time.Second-1)/time.Second), 10))
+
+		return &exception.Error{
+			ErrorCode: exception.SlowDown,
+			Message:   "Too many failed authentication attempts. Please reduce your request rate.",
+		}
+	}
+
+	return nil
+}
+
+// recordAuthenticationFailure records a failed signature verification of the request
+// against its client IP and the access key id it claims to be signed by.
+func (s *Server) recordAuthenticationFailure(ctx *RequestContext, accessKeyId string, err *exception.Error) {
+	if !isAuthenticationFailure(err) {
+		return
+	}
+
+	var now = time.Now()
+
+	for _, record := range s.lockoutTargets(ctx, accessKeyId) {
+		if record.lockout == nil || record.key == "" || !record.lockout.Fail(record.key, now) {
+			continue
+		}
+
+		ctx.Warn("Locked out after too many failed signature verifications", zap.String("kind", record.kind), zap.String("key", record.key))
+
+		statLockouts.WithLabelValues(record.kind).Add(1)
+	}
+}
+
+// clientLockoutKey returns the key of the client IP of the request, or an empty string if the client IP is unknown.
+func clientLockoutKey(ctx *RequestContext) string {
+	if ctx.RemoteIP == nil {
+		return ""
+	}
+
+	return ctx.RemoteIP.String()
+}
+
+// lockoutCollector is a prometheus.Collector that counts the keys that are locked out by each kind of lockout
+// at the time that metrics are collected.
+type lockoutCollector struct {
+	desc *prometheus.Desc
+
+	mx       sync.Mutex
+	lockouts map[string]*security.Lockout
+}
+
+// newLockoutCollector creates a new lockoutCollector of the metric desc, which is registered with registry.
+func newLockoutCollector(registry prometheus.Registerer, desc *prometheus.Desc) *lockoutCollector {
+	c := &lockoutCollector{
+		desc:     desc,
+		lockouts: make(map[string]*security.Lockout),
+	}
+
+	registry.MustRegister(c)
+	return c
+}
+
+// track collects the number of locked out keys of lockout as the given kind.
+// It replaces any lockout previously tracked as the same kind.
+func (c *lockoutCollector) track(kind string, lockout *security.Lockout) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.lockouts[kind] = lockout
+}
+
+func (c *lockoutCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lockoutCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var now = time.Now()
+	for kind, lockout := range c.lockouts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(lockout.Count(now)), kind)
+	}
+}
@@ -0,0 +1,103 @@
+package ls3
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/security"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_Lockout(t *testing.T) {
+	// testFailedRequest returns a request with a signature that does not match
+	testFailedRequest := func() *http.Request {
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "", nil, nil)
+		req.URL.Path = "/other/"
+		return req
+	}
+
+	t.Run("client ip", func(t *testing.T) {
+		srv := testServer()
+		srv.clientLockout = security.NewLockout(3, time.Minute, time.Minute)
+		srv.remoteIP = func(r *http.Request) net.IP {
+			return net.IPv4(10, 0, 0, 1)
+		}
+
+		for i := 0; i < 3; i++ {
+			rw := httptest.NewRecorder()
+			srv.ServeHTTP(rw, testFailedRequest())
+			AssertIsResponseError(t, rw, exception.SignatureDoesNotMatch)
+		}
+
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.SlowDown)
+		assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+	})
+
+	t.Run("access key id", func(t *testing.T) {
+		srv := testServer()
+		srv.keyLockout = security.NewLockout(3, time.Minute, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			rw := httptest.NewRecorder()
+			srv.ServeHTTP(rw, testFailedRequest())
+			AssertIsResponseError(t, rw, exception.SignatureDoesNotMatch)
+		}
+
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.SlowDown)
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		srv := testServer()
+		srv.keyLockout = security.NewLockout(3, time.Minute, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			rw := httptest.NewRecorder()
+			srv.ServeHTTP(rw, testFailedRequest())
+			AssertIsResponseError(t, rw, exception.SignatureDoesNotMatch)
+		}
+
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "", nil, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+}
+
+func Test_lockoutCollector(t *testing.T) {
+	var (
+		registry  = prometheus.NewRegistry()
+		desc      = prometheus.NewDesc("locked_out", "", []string{"kind"}, nil)
+		collector = newLockoutCollector(registry, desc)
+		lockout   = security.NewLockout(1, time.Minute, time.Hour)
+	)
+
+	collector.track(lockoutKindClientIP, lockout)
+
+	var gather = func() float64 {
+		families, err := registry.Gather()
+		if !assert.NoError(t, err) || !assert.Len(t, families, 1) || !assert.Len(t, families[0].GetMetric(), 1) {
+			return -1
+		}
+
+		return families[0].GetMetric()[0].GetGauge().GetValue()
+	}
+
+	assert.Equal(t, float64(0), gather())
+
+	// The count is taken when metrics are gathered, so lock outs that end are no longer counted
+	assert.True(t, lockout.Fail("127.0.0.1", time.Now()))
+	assert.Equal(t, float64(1), gather())
+
+	assert.True(t, lockout.Fail("127.0.0.2", time.Now().Add(-2*time.Hour)))
+	assert.Equal(t, float64(1), gather())
+}
@@ -0,0 +1,275 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// reservedNamePrefix is the prefix of file and directory names reserved for use by ls3.
+// Reserved names are never listed or served as objects.
+const reservedNamePrefix = ".ls3"
+
+// metadataSidecarDir is the name of the directory, next to each object, that contains object metadata sidecar files.
+const metadataSidecarDir = ".ls3meta"
+
+// directoryMarkerName is the name of the file, inside each directory created as an object, that marks the directory as an object.
+// Marked directories are not removed when the last object they contain is deleted.
+const directoryMarkerName = ".ls3dir"
+
+// isReservedName returns true if the file or directory name is reserved.
+func isReservedName(name string) bool {
+	return strings.HasPrefix(name, reservedNamePrefix)
+}
+
+// isReservedPath returns true if any component of the path is reserved.
+func isReservedPath(p string) bool {
+	for _, name := range strings.Split(p, "/") {
+		if isReservedName(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObjectMetadata is user defined metadata of an object.
+type ObjectMetadata struct {
+	ContentType        string `json:"Content-Type,omitempty"`
+	CacheControl       string `json:"Cache-Control,omitempty"`
+	ContentDisposition string `json:"Content-Disposition,omitempty"`
+	ContentEncoding    string `json:"Content-Encoding,omitempty"`
+	// Metadata are x-amz-meta-* values, keyed by the name without the x-amz-meta- prefix.
+	Metadata map[string]string `json:"Metadata,omitempty"`
+	// ETag is the ETag of an object that is not the MD5 sum of its content, such as an object created by a multipart upload.
+	// It is only used while the file of the object is unchanged.
+	ETag *etagCacheEntry `json:"ETag,omitempty"`
+}
+
+// metadataSidecarPath returns the path of the metadata sidecar file of the object key.
+// The sidecar of dir/name is dir/.ls3meta/name.json.
+func metadataSidecarPath(key string) string {
+	dir, name := path.Split(key)
+	return path.Join(dir, metadataSidecarDir, name+".json")
+}
+
+// readObjectMetadata reads the metadata sidecar file of the object key.
+// It returns nil if the object has no sidecar file.
+func readObjectMetadata(fsys fs.FS, key string) (*ObjectMetadata, error) {
+	b, err := fs.ReadFile(fsys, metadataSidecarPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, unwrapFsError(err)
+	}
+
+	var meta ObjectMetadata
+	err = json.Unmarshal(b, &meta)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidObjectState,
+			Message:   "The metadata of this object is not valid.",
+		}
+	}
+
+	return &meta, nil
+}
+
+// defaultContentTypes are content types that clients send when the content type of an object is not known.
+// They are not stored, so that the content type of the object is guessed instead.
+var defaultContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+}
+
+// metadataFromHeader returns the object metadata set in the headers of a request that creates an object.
+// It returns nil if the request does not set any metadata.
+func metadataFromHeader(header http.Header) *ObjectMetadata {
+	var contentType = header.Get("Content-Type")
+	if defaultContentTypes[strings.ToLower(contentType)] {
+		contentType = ""
+	}
+
+	var meta = ObjectMetadata{
+		ContentType:        contentType,
+		CacheControl:       header.Get("Cache-Control"),
+		ContentDisposition: header.Get("Content-Disposition"),
+		ContentEncoding:    header.Get("Content-Encoding"),
+	}
+
+	for k, v := range header {
+		if len(v) == 0 || !strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+			continue
+		}
+
+		if meta.Metadata == nil {
+			meta.Metadata = make(map[string]string)
+		}
+
+		meta.Metadata[strings.ToLower(k[len("x-amz-meta-"):])] = v[0]
+	}
+
+	if meta.isEmpty() {
+		return nil
+	}
+
+	return &meta
+}
+
+// isEmpty returns true if the metadata does not store anything.
+func (meta *ObjectMetadata) isEmpty() bool {
+	return meta.ContentType == "" && meta.CacheControl == "" && meta.ContentDisposition == "" &&
+		meta.ContentEncoding == "" && meta.Metadata == nil && meta.ETag == nil
+}
+
+// withoutContentType returns a copy of meta that does not store contentType,
+// which is the content type that is guessed for the object without metadata.
+// It returns nil if the copy does not store anything.
+func withoutContentType(meta *ObjectMetadata, contentType string) *ObjectMetadata {
+	if meta == nil {
+		return nil
+	}
+
+	var copied = *meta
+	if copied.ContentType == contentType {
+		copied.ContentType = ""
+	}
+
+	if copied.isEmpty() {
+		return nil
+	}
+
+	return &copied
+}
+
+// withETag returns a copy of meta that stores etag as the ETag of the file fi.
+// If etag is empty then the copy does not store an ETag.
+func withETag(meta *ObjectMetadata, fi fs.FileInfo, etag string) *ObjectMetadata {
+	if meta == nil && etag == "" {
+		return nil
+	}
+
+	var copied ObjectMetadata
+	if meta != nil {
+		copied = *meta
+	}
+
+	copied.ETag = nil
+	if etag != "" {
+		copied.ETag = newETagEntry(fi, etag)
+	}
+
+	return &copied
+}
+
+// writeObjectMetadata replaces the metadata sidecar file of the object key.
+// If meta is nil then any existing sidecar file is removed.
+func writeObjectMetadata(fsys WritableFS, key string, meta *ObjectMetadata) error {
+	sidecar := metadataSidecarPath(key)
+
+	if meta == nil {
+		err := fsys.Remove(sidecar)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		// Remove the sidecar directory if it is now empty
+		_ = fsys.Remove(path.Dir(sidecar))
+		return nil
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writeAtomic(fsys, sidecar, bytes.NewReader(b), nil)
+	return err
+}
+
+// replaceObject renames the temporary file tempName to the object key, and replaces the metadata sidecar file of the object with meta.
+// If etag is not empty then it is stored as the ETag of the object.
+// The sidecar file is written before the object is replaced, and restored if the object cannot be replaced.
+// It returns the file info of the new object.
+// The temporary file is removed if it cannot be renamed.
+func replaceObject(fsys WritableFS, tempName string, key string, meta *ObjectMetadata, etag string) (_ fs.FileInfo, err error) {
+	defer func() {
+		if err != nil {
+			_ = fsys.Remove(tempName)
+		}
+	}()
+
+	// Renaming the file does not change its size, modification time or inode
+	fi, err := fs.Stat(fsys, tempName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A content type that is the same as the guessed content type does not need a sidecar file
+	if meta != nil && meta.ContentType != "" {
+		f, err := fsys.Open(tempName)
+		if err != nil {
+			return nil, err
+		}
+
+		contentType, _ := guessContentType(f)
+		_ = f.Close()
+
+		meta = withoutContentType(meta, contentType)
+	}
+
+	sidecar := metadataSidecarPath(key)
+	previous, err := fs.ReadFile(fsys, sidecar)
+	hasPrevious := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	err = writeObjectMetadata(fsys, key, withETag(meta, fi, etag))
+	if err != nil {
+		return nil, err
+	}
+
+	err = fsys.Rename(tempName, key)
+	if err != nil {
+		// Restore the sidecar file of the object that was not replaced
+		if hasPrevious {
+			_, _ = writeAtomic(fsys, sidecar, bytes.NewReader(previous), nil)
+		} else {
+			_ = writeObjectMetadata(fsys, key, nil)
+		}
+
+		return nil, err
+	}
+
+	return fi, nil
+}
+
+// setMetadataHeaders sets the HTTP response headers for the metadata of the object.
+func setMetadataHeaders(header http.Header, obj *Object) {
+	header.Set("Content-Type", obj.ContentType)
+
+	if obj.Metadata == nil {
+		return
+	}
+
+	if obj.Metadata.CacheControl != "" {
+		header.Set("Cache-Control", obj.Metadata.CacheControl)
+	}
+	if obj.Metadata.ContentDisposition != "" {
+		header.Set("Content-Disposition", obj.Metadata.ContentDisposition)
+	}
+	if obj.Metadata.ContentEncoding != "" {
+		header.Set("Content-Encoding", obj.Metadata.ContentEncoding)
+	}
+
+	for k, v := range obj.Metadata.Metadata {
+		header.Set("x-amz-meta-"+k, v)
+	}
+}
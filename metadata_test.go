@@ -0,0 +1,96 @@
+package ls3
+
+import (
+	"github.com/psanford/memfs"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_metadataSidecarPath(t *testing.T) {
+	assert.Equal(t, ".ls3meta/index.html.json", metadataSidecarPath("index.html"))
+	assert.Equal(t, "docs/.ls3meta/index.html.json", metadataSidecarPath("docs/index.html"))
+}
+
+func Test_isReservedPath(t *testing.T) {
+	assert.True(t, isReservedPath(".ls3meta/index.html.json"))
+	assert.True(t, isReservedPath("docs/.ls3meta"))
+	assert.False(t, isReservedPath("docs/index.html"))
+	assert.False(t, isReservedPath("docs/.ls"))
+}
+
+func testMetadataFS() *memfs.FS {
+	fsys := memfs.New()
+	_ = fsys.MkdirAll("docs/.ls3meta", 0755)
+	_ = fsys.WriteFile("docs/index.html", []byte("<html></html>"), 0644)
+	_ = fsys.WriteFile("docs/.ls3meta/index.html.json", []byte(`{
+  "Content-Type": "text/html; charset=utf-8",
+  "Cache-Control": "max-age=60",
+  "Content-Disposition": "inline",
+  "Metadata": {
+    "author": "ls3"
+  }
+}`), 0644)
+
+	return fsys
+}
+
+func TestBucketIterator_PrefixScan_Reserved(t *testing.T) {
+	it := NewBucketIterator(testMetadataFS())
+	contents, err := it.PrefixScan("", "", false, 1000)
+	assert.NoError(t, err)
+
+	if assert.Len(t, contents, 1) {
+		assert.Equal(t, "docs/index.html", contents[0].Key)
+	}
+
+	it = NewBucketIterator(testMetadataFS())
+	contents, err = it.PrefixScan("docs/.ls3meta/", "", false, 1000)
+	assert.NoError(t, err)
+	assert.Empty(t, contents)
+}
+
+func TestServer_GetObject_Metadata(t *testing.T) {
+	t.Run("headers", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/docs/index.html", "", nil, nil)
+		testServerFS(testMetadataFS()).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+		assert.Equal(t, "max-age=60", rw.Header().Get("Cache-Control"))
+		assert.Equal(t, "inline", rw.Header().Get("Content-Disposition"))
+		assert.Equal(t, "ls3", rw.Header().Get("x-amz-meta-author"))
+		assert.Equal(t, "<html></html>", rw.Body.String())
+	})
+
+	t.Run("response_override", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/docs/index.html", "response-cache-control=no-cache", nil, nil)
+		testServerFS(testMetadataFS()).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"))
+	})
+
+	t.Run("sidecar_hidden", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/docs/.ls3meta/index.html.json", "", nil, nil)
+		testServerFS(testMetadataFS()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchKey)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		fsys := testMetadataFS()
+		_ = fsys.WriteFile("docs/.ls3meta/index.html.json", []byte("{"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/docs/index.html", "", nil, nil)
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidObjectState)
+	})
+}
@@ -0,0 +1,394 @@
+package ls3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/relvacode/ls3/exception"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// multipartUploadDir is the directory, at the root of each bucket, that contains a staging directory for each multipart upload.
+const multipartUploadDir = ".ls3uploads"
+
+// multipartUploadFile is the name of the file, in the staging directory of a multipart upload, that describes the upload.
+const multipartUploadFile = "upload.json"
+
+// maxPartNumber is the highest part number of a multipart upload.
+const maxPartNumber = 10000
+
+// minPartSize is the minimum size of each part of a multipart upload, except the last part.
+const minPartSize = 5 * 1024 * 1024
+
+var errNoSuchUpload = &exception.Error{
+	ErrorCode: exception.NoSuchUpload,
+	Message:   "The specified multipart upload does not exist.",
+}
+
+// multipartUpload describes a multipart upload that has not yet been completed or aborted.
+type multipartUpload struct {
+	UploadId  string `json:"-"`
+	Key       string
+	Initiated time.Time
+	Initiator string
+	Metadata  *ObjectMetadata `json:",omitempty"`
+}
+
+// multipartPart is an uploaded part of a multipart upload.
+type multipartPart struct {
+	PartNumber   int
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+// newUploadId returns a new random multipart upload ID.
+func newUploadId() string {
+	return uuid.New().String()
+}
+
+// multipartUploadPath returns the path of the staging directory of the multipart upload.
+// It returns false if uploadId is not a valid multipart upload ID.
+func multipartUploadPath(uploadId string) (string, bool) {
+	id, err := uuid.Parse(uploadId)
+	if err != nil || id.String() != uploadId {
+		return "", false
+	}
+
+	return path.Join(multipartUploadDir, uploadId), true
+}
+
+// multipartPartName returns the name of the file of an uploaded part.
+// The ETag of the part is part of the name, so that a part is never replaced by another with the same number while it is being read.
+func multipartPartName(partNumber int, etag string) string {
+	return fmt.Sprintf("%05d.%s", partNumber, etag)
+}
+
+// isPartETag returns true if etag is the unquoted ETag of an uploaded part, which is a hex encoded MD5 sum.
+func isPartETag(etag string) bool {
+	_, err := hex.DecodeString(etag)
+	return err == nil && len(etag) == hex.EncodedLen(md5.Size)
+}
+
+// parseMultipartPartName returns the part number and ETag of a file created by multipartPartName.
+func parseMultipartPartName(name string) (int, string, bool) {
+	number, etag, ok := strings.Cut(name, ".")
+	if !ok || len(number) != 5 || len(etag) != hex.EncodedLen(md5.Size) {
+		return 0, "", false
+	}
+
+	partNumber, err := strconv.Atoi(number)
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return 0, "", false
+	}
+
+	return partNumber, etag, true
+}
+
+// parsePartNumber parses the partNumber of a request for an uploaded part.
+func parsePartNumber(v string) (int, error) {
+	partNumber, err := strconv.Atoi(v)
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return 0, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive.", maxPartNumber),
+		}
+	}
+
+	return partNumber, nil
+}
+
+// readMultipartUpload reads the multipart upload uploadId.
+// If key is not empty, the upload must be an upload of that object key.
+// The error NoSuchUpload is returned if the upload does not exist.
+func readMultipartUpload(fsys fs.FS, uploadId string, key string) (*multipartUpload, error) {
+	dir, ok := multipartUploadPath(uploadId)
+	if !ok {
+		return nil, errNoSuchUpload
+	}
+
+	b, err := fs.ReadFile(fsys, path.Join(dir, multipartUploadFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errNoSuchUpload
+	}
+	if err != nil {
+		return nil, unwrapFsError(err)
+	}
+
+	var upload multipartUpload
+	err = json.Unmarshal(b, &upload)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   "The specified multipart upload is not valid.",
+		}
+	}
+
+	if key != "" && upload.Key != key {
+		return nil, errNoSuchUpload
+	}
+
+	upload.UploadId = uploadId
+	return &upload, nil
+}
+
+// createMultipartUpload creates the staging directory of a new multipart upload.
+func createMultipartUpload(wfs WritableFS, upload *multipartUpload) error {
+	dir, ok := multipartUploadPath(upload.UploadId)
+	if !ok {
+		return errNoSuchUpload
+	}
+
+	b, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	_, err = writeAtomic(wfs, path.Join(dir, multipartUploadFile), bytes.NewReader(b), nil)
+	return err
+}
+
+// listMultipartUploads returns all multipart uploads of the bucket, ordered by key and then upload ID.
+func listMultipartUploads(fsys fs.FS) ([]*multipartUpload, error) {
+	entries, err := fs.ReadDir(fsys, multipartUploadDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, unwrapFsError(err)
+	}
+
+	var uploads []*multipartUpload
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Ignore uploads that are not valid, or were removed while listing
+		upload, err := readMultipartUpload(fsys, entry.Name(), "")
+		if err != nil {
+			continue
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+
+		return uploads[i].UploadId < uploads[j].UploadId
+	})
+
+	return uploads, nil
+}
+
+// listMultipartParts returns the uploaded parts of the multipart upload, ordered by part number.
+// If a part has been uploaded more than once, only the most recent upload of that part is returned.
+func listMultipartParts(fsys fs.FS, uploadId string) ([]*multipartPart, error) {
+	dir, ok := multipartUploadPath(uploadId)
+	if !ok {
+		return nil, errNoSuchUpload
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, unwrapFsError(err)
+	}
+
+	var parts = make(map[int]*multipartPart)
+	for _, entry := range entries {
+		partNumber, etag, ok := parseMultipartPartName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if existing, ok := parts[partNumber]; ok && existing.LastModified.After(fi.ModTime()) {
+			continue
+		}
+
+		parts[partNumber] = &multipartPart{
+			PartNumber:   partNumber,
+			ETag:         etag,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime().UTC(),
+		}
+	}
+
+	var sorted = make([]*multipartPart, 0, len(parts))
+	for _, part := range parts {
+		sorted = append(sorted, part)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PartNumber < sorted[j].PartNumber
+	})
+
+	return sorted, nil
+}
+
+// writeMultipartPart writes part partNumber of the multipart upload from r, and returns the ETag of the part.
+// If expectMD5 is not nil, the part is only written if the MD5 sum of r matches.
+// Any previous upload of the same part is replaced.
+func writeMultipartPart(wfs WritableFS, uploadId string, partNumber int, r io.Reader, expectMD5 []byte) (string, int64, error) {
+	dir, ok := multipartUploadPath(uploadId)
+	if !ok {
+		return "", 0, errNoSuchUpload
+	}
+
+	h := md5.New()
+
+	tempName, n, err := writeTemp(wfs, dir, io.TeeReader(r, h), func() error {
+		if expectMD5 != nil && !bytes.Equal(h.Sum(nil), expectMD5) {
+			return &exception.Error{
+				ErrorCode: exception.BadDigest,
+				Message:   "The Content-MD5 you specified did not match what we received.",
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", n, err
+	}
+
+	etag := hex.EncodeToString(h.Sum(nil))
+
+	err = wfs.Rename(tempName, path.Join(dir, multipartPartName(partNumber, etag)))
+	if err != nil {
+		_ = wfs.Remove(tempName)
+		return "", n, err
+	}
+
+	// Remove previous uploads of this part
+	entries, err := fs.ReadDir(wfs, dir)
+	if err != nil {
+		return "", n, err
+	}
+
+	for _, entry := range entries {
+		if otherNumber, otherETag, ok := parseMultipartPartName(entry.Name()); ok && otherNumber == partNumber && otherETag != etag {
+			_ = wfs.Remove(path.Join(dir, entry.Name()))
+		}
+	}
+
+	return etag, n, nil
+}
+
+// removeMultipartUpload removes the staging directory of the multipart upload, and all of its uploaded parts.
+func removeMultipartUpload(wfs WritableFS, uploadId string) error {
+	dir, ok := multipartUploadPath(uploadId)
+	if !ok {
+		return errNoSuchUpload
+	}
+
+	entries, err := fs.ReadDir(wfs, dir)
+	if err != nil {
+		return err
+	}
+
+	// Remove the upload description last, so that the upload is still visible if any part cannot be removed
+	for _, entry := range entries {
+		if entry.Name() == multipartUploadFile {
+			continue
+		}
+
+		err = wfs.Remove(path.Join(dir, entry.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	err = wfs.Remove(path.Join(dir, multipartUploadFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	err = wfs.Remove(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Remove the multipart upload directory once there are no more uploads
+	_ = wfs.Remove(multipartUploadDir)
+	return nil
+}
+
+// multipartETag returns the ETag of an object created from the parts with the given ETags.
+// The ETag is the MD5 sum of the MD5 sums of each part, followed by the number of parts.
+func multipartETag(etags []string) (string, error) {
+	h := md5.New()
+	for _, etag := range etags {
+		sum, err := hex.DecodeString(etag)
+		if err != nil {
+			return "", err
+		}
+
+		h.Write(sum)
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(etags)), nil
+}
+
+// partsReader reads the contents of each file in turn.
+// Only one file is open at a time.
+type partsReader struct {
+	fsys  fs.FS
+	names []string
+	f     fs.File
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.f == nil {
+			if len(r.names) == 0 {
+				return 0, io.EOF
+			}
+
+			f, err := r.fsys.Open(r.names[0])
+			if err != nil {
+				return 0, err
+			}
+
+			r.f = f
+			r.names = r.names[1:]
+		}
+
+		n, err := r.f.Read(p)
+		if err == io.EOF {
+			_ = r.f.Close()
+			r.f = nil
+
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+
+	return r.f.Close()
+}
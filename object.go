@@ -1,16 +1,20 @@
 package ls3
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/gotd/contrib/http_range"
 	"github.com/h2non/filetype"
 	"github.com/relvacode/ls3/exception"
 	"io"
 	"io/fs"
+	"mime/multipart"
 	"net/http"
 	"net/textproto"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,18 +23,66 @@ import (
 type Object struct {
 	io.ReadCloser
 
-	Size         int64
-	Range        *http_range.Range
+	Size  int64
+	Range *http_range.Range
+	// Ranges is set instead of Range when more than one range of the object is requested.
+	// Each range is sorted and does not overlap with another range.
+	Ranges       []http_range.Range
 	LastModified time.Time
 	// ContentType contains the MIME type of the object data.
 	// It is the best guess based on the filetype library.
 	// This is always set, if unknown the MIME type becomes application/octet-stream
 	ContentType string
 	// ETag represents the ETag field of the Object.
-	// It is always empty.
+	// It is the unquoted hex encoded MD5 sum of the object data.
+	// It may be empty until LoadETag is called.
 	ETag string
+	// Tags are the tags of the object, read from the user.s3.tag.* extended attributes of the file.
+	Tags map[string]string
+	// Metadata is the user defined metadata of the object, read from the object metadata sidecar file.
+	// It is nil if the object has no metadata.
+	Metadata *ObjectMetadata
+
+	// etag computes the ETag of the object if it was not cached when the object was opened.
+	etag func() (string, error)
+	// checksum computes the SHA-256 checksum of the object, or returns its cached checksum.
+	checksum func() (string, error)
 }
 
+// LoadETag sets the ETag of the object, reading the entire object if the ETag is not cached.
+// Access to the object must be checked first, so that the object is only read on behalf of an allowed request.
+func (obj *Object) LoadETag() error {
+	if obj.ETag != "" || obj.etag == nil {
+		return nil
+	}
+
+	etag, err := obj.etag()
+	if err != nil {
+		return unwrapFsError(err)
+	}
+
+	obj.ETag = etag
+	return nil
+}
+
+// ChecksumSHA256 returns the base64 encoded SHA-256 checksum of the object, reading the entire object if it is not cached.
+// Like LoadETag, access to the object must be checked first.
+func (obj *Object) ChecksumSHA256() (string, error) {
+	if obj.checksum == nil {
+		return "", nil
+	}
+
+	checksum, err := obj.checksum()
+	if err != nil {
+		return "", unwrapFsError(err)
+	}
+
+	return checksum, nil
+}
+
+// existingObjectTagContextKey is the prefix of the policy context key that contains the value of an object tag.
+const existingObjectTagContextKey = "s3:ExistingObjectTag/"
+
 // Get implements PolicyContextVars for this Object
 func (obj *Object) Get(k string) (string, bool) {
 	switch k {
@@ -41,6 +93,11 @@ func (obj *Object) Get(k string) (string, bool) {
 	case "ls3:ObjectLastModified":
 		return obj.LastModified.Format(time.RFC3339), true
 	default:
+		if strings.HasPrefix(k, existingObjectTagContextKey) {
+			v, ok := obj.Tags[strings.TrimPrefix(k, existingObjectTagContextKey)]
+			return v, ok
+		}
+
 		return "", false
 	}
 }
@@ -60,6 +117,18 @@ func urlPathObjectKey(urlPath string) (string, error) {
 	return urlPath, nil
 }
 
+// cleanObjectKey returns the object key of a key given in a request body or form, such as in DeleteObjects.
+// It returns false if the key is not already clean, such as a key that contains "." or ".." segments,
+// so that the key used to check access is always the key of the object that is accessed.
+func cleanObjectKey(key string) (string, bool) {
+	cleanKey, err := urlPathObjectKey("/" + key)
+	if err != nil || cleanKey != strings.TrimSuffix(key, "/") {
+		return "", false
+	}
+
+	return cleanKey, true
+}
+
 func unwrapFsError(err error) *exception.Error {
 	if errors.Is(err, os.ErrNotExist) {
 		return &exception.Error{
@@ -81,80 +150,99 @@ func unwrapFsError(err error) *exception.Error {
 	}
 }
 
+// etagMatches returns true if any entity tag in the comma separated list of entity tags matches etag.
+// Entity tags may be quoted and may be weak. The wildcard entity tag "*" matches any etag.
+func etagMatches(list string, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		tag = strings.TrimPrefix(tag, "W/")
+		if strings.Trim(tag, "\"") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseConditionalTime parses the value of a conditional HTTP date header.
+func parseConditionalTime(header, value string) (time.Time, error) {
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   fmt.Sprintf("Invalid value for %s.", header),
+		}
+	}
+
+	return t, nil
+}
+
 // checkConditionalRequest checks conditional parameters present in HTTP headers
 // And returns a status code for that condition.
 // Returns [0, nil] if there are no limiting conditions.
+//
+// Conditions are evaluated in the order defined by RFC 7232 section 6:
+// If-Unmodified-Since is ignored when If-Match is present,
+// and If-Modified-Since is ignored when If-None-Match is present.
 func checkConditionalRequest(header http.Header, obj *Object) (int, error) {
-	var (
-		ifMatch     *bool
-		ifNoneMatch *bool
-	)
+	// HTTP dates have a resolution of one second
+	lastModified := obj.LastModified.Truncate(time.Second)
 
 	if _, reqIfMatch := header[textproto.CanonicalMIMEHeaderKey("If-Match")]; reqIfMatch {
-		var eval = header.Get("If-Match") == obj.ETag
-		ifMatch = &eval
-	}
-
-	if _, reqIfNoneMatch := header[textproto.CanonicalMIMEHeaderKey("If-None-Match")]; reqIfNoneMatch {
-		var eval = header.Get("If-None-Match") != obj.ETag
-		ifNoneMatch = &eval
-	}
-
-	// Check if the object has not been modified expires the requested date.
-	if reqModifiedSince := header.Get("If-Modified-Since"); reqModifiedSince != "" {
-		ifModifiedSinceTime, err := time.Parse(http.TimeFormat, reqModifiedSince)
+		if !etagMatches(header.Get("If-Match"), obj.ETag) {
+			return http.StatusPreconditionFailed, nil
+		}
+	} else if reqUnmodifiedSince := header.Get("If-Unmodified-Since"); reqUnmodifiedSince != "" {
+		ifUnmodifiedSince, err := parseConditionalTime("If-Unmodified-Since", reqUnmodifiedSince)
 		if err != nil {
-			return 0, &exception.Error{
-				ErrorCode: exception.InvalidArgument,
-				Message:   "Invalid value for If-Modified-Since.",
-			}
+			return 0, err
 		}
 
-		ifModifiedSince := ifModifiedSinceTime.After(obj.LastModified)
-
-		if ifMatch != nil {
-			if *ifMatch && !ifModifiedSince {
-				return 0, nil
-			}
+		if lastModified.After(ifUnmodifiedSince) {
+			return http.StatusPreconditionFailed, nil
 		}
+	}
 
-		if !ifModifiedSince {
+	if _, reqIfNoneMatch := header[textproto.CanonicalMIMEHeaderKey("If-None-Match")]; reqIfNoneMatch {
+		if etagMatches(header.Get("If-None-Match"), obj.ETag) {
 			return http.StatusNotModified, nil
 		}
-	}
-
-	// Check if the object has not been modified expires the requested date.
-	if reqUnmodifiedSince := header.Get("If-Unmodified-Since"); reqUnmodifiedSince != "" {
-		ifUnmodifiedSinceTime, err := time.Parse(http.TimeFormat, reqUnmodifiedSince)
+	} else if reqModifiedSince := header.Get("If-Modified-Since"); reqModifiedSince != "" {
+		ifModifiedSince, err := parseConditionalTime("If-Modified-Since", reqModifiedSince)
 		if err != nil {
-			return 0, &exception.Error{
-				ErrorCode: exception.InvalidArgument,
-				Message:   "Invalid value for If-Unmodified-Since.",
-			}
+			return 0, err
 		}
 
-		ifUnmodifiedSince := obj.LastModified.Before(ifUnmodifiedSinceTime)
-
-		if ifNoneMatch != nil {
-			if !*ifNoneMatch && ifUnmodifiedSince {
-				return http.StatusNotModified, nil
-			}
+		if !lastModified.After(ifModifiedSince) {
+			return http.StatusNotModified, nil
 		}
+	}
 
-		if !ifUnmodifiedSince {
-			return http.StatusPreconditionFailed, nil
-		}
+	return 0, nil
+}
+
+// checkIfRange returns true if the Range of the request should be applied given the value of If-Range.
+// An entity tag must strongly match the object ETag, and a date must exactly match the last modified time of the object.
+func checkIfRange(ifRange string, obj *Object) bool {
+	if strings.HasPrefix(ifRange, "W/") {
+		// Weak entity tags never match
+		return false
 	}
 
-	if ifMatch != nil && !*ifMatch {
-		return http.StatusPreconditionFailed, nil
+	if strings.HasPrefix(ifRange, "\"") {
+		return len(ifRange) > 1 && strings.HasSuffix(ifRange, "\"") && ifRange[1:len(ifRange)-1] == obj.ETag
 	}
 
-	if ifNoneMatch != nil && !*ifNoneMatch {
-		return http.StatusNotModified, nil
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
 	}
 
-	return 0, nil
+	return obj.LastModified.Truncate(time.Second).Equal(t)
 }
 
 type closeProxy struct {
@@ -166,24 +254,61 @@ func (cp *closeProxy) Close() error {
 	return cp.closer()
 }
 
+// coalesceRanges sorts ranges by their starting position,
+// and merges any ranges that overlap or are adjacent to each other.
+func coalesceRanges(ranges []http_range.Range) []http_range.Range {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	var coalesced = ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &coalesced[len(coalesced)-1]
+		lastEnd := last.Start + last.Length
+
+		if r.Start > lastEnd {
+			coalesced = append(coalesced, r)
+			continue
+		}
+
+		if end := r.Start + r.Length; end > lastEnd {
+			last.Length = end - last.Start
+		}
+	}
+
+	return coalesced
+}
+
+// maxRanges is the maximum number of ranges, after overlapping ranges are merged, that are served from one request.
+const maxRanges = 100
+
+// limitRange limits the object to the ranges requested in the Range header.
+// If the request contains If-Range that does not match the object,
+// or more than maxRanges ranges, then the full object is used instead.
+// It must be called after conditional headers are evaluated.
 func limitRange(r *http.Request, obj *Object) error {
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader == "" {
 		return nil
 	}
 
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && !checkIfRange(ifRange, obj) {
+		return nil
+	}
+
 	ranges, err := http_range.ParseRange(rangeHeader, obj.Size)
-	// Only one range request is supported
-	if err != nil || len(ranges) != 1 {
+	if err != nil || len(ranges) == 0 {
 		return &exception.Error{
 			ErrorCode: exception.InvalidRange,
 			Message:   "The requested range is not valid for the request. Try another range.",
 		}
 	}
 
-	obj.Range = &ranges[0]
+	ranges = coalesceRanges(ranges)
+	if len(ranges) > maxRanges {
+		return nil
+	}
 
-	// Seek object to target start range
 	seek, ok := obj.ReadCloser.(io.Seeker)
 	if !ok {
 		return &exception.Error{
@@ -192,6 +317,15 @@ func limitRange(r *http.Request, obj *Object) error {
 		}
 	}
 
+	// Multiple ranges are read on demand when the response is sent
+	if len(ranges) > 1 {
+		obj.Ranges = ranges
+		return nil
+	}
+
+	obj.Range = &ranges[0]
+
+	// Seek object to target start range
 	_, err = seek.Seek(obj.Range.Start, 0)
 	if err != nil {
 		return &exception.Error{
@@ -210,6 +344,84 @@ func limitRange(r *http.Request, obj *Object) error {
 	return nil
 }
 
+// rangeReader reads a single range from an io.ReadSeeker.
+// The underlying reader is seeked to the start of the range on the first call to Read.
+type rangeReader struct {
+	rs     io.ReadSeeker
+	rng    http_range.Range
+	limits io.Reader
+}
+
+func (rr *rangeReader) Read(b []byte) (int, error) {
+	if rr.limits == nil {
+		_, err := rr.rs.Seek(rr.rng.Start, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+
+		rr.limits = io.LimitReader(rr.rs, rr.rng.Length)
+	}
+
+	return rr.limits.Read(b)
+}
+
+// multipartByteRanges returns a multipart/byteranges response body containing each range of the object,
+// along with the total length of the body and the boundary used to separate each part.
+// contentType is used as the Content-Type of each part.
+func multipartByteRanges(obj *Object, contentType string) (io.Reader, int64, string) {
+	var (
+		framing bytes.Buffer
+		readers []io.Reader
+		length  int64
+		rs      = obj.ReadCloser.(io.ReadSeeker)
+		w       = multipart.NewWriter(&framing)
+	)
+
+	// The multipart writer only writes the framing between each part.
+	// After each part header is written, the range of the object is read directly from the object.
+	for _, rng := range obj.Ranges {
+		_, _ = w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  []string{contentType},
+			"Content-Range": []string{rng.ContentRange(obj.Size)},
+		})
+
+		readers = append(readers, bytes.NewReader(append([]byte(nil), framing.Bytes()...)), &rangeReader{
+			rs:  rs,
+			rng: rng,
+		})
+
+		length += int64(framing.Len()) + rng.Length
+		framing.Reset()
+	}
+
+	_ = w.Close()
+
+	readers = append(readers, bytes.NewReader(framing.Bytes()))
+	length += int64(framing.Len())
+
+	return io.MultiReader(readers...), length, w.Boundary()
+}
+
+// rangeResponse sets the range headers of a response for the object once limitRange has been applied,
+// and returns the status code, body and content length of the response.
+// The Content-Type header must already be set, as it is used as the content type of each part of a response with multiple ranges.
+func rangeResponse(header http.Header, obj *Object) (int, io.Reader, int64) {
+	header.Set("Accept-Ranges", "bytes")
+
+	switch {
+	case obj.Range != nil:
+		header.Set("Content-Range", obj.Range.ContentRange(obj.Size))
+		return http.StatusPartialContent, obj, obj.Range.Length
+	case len(obj.Ranges) > 1:
+		// Each part of a multipart response takes the content type of the object
+		body, length, boundary := multipartByteRanges(obj, header.Get("Content-Type"))
+		header.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		return http.StatusPartialContent, body, length
+	default:
+		return http.StatusOK, obj, obj.Size
+	}
+}
+
 // seekOrRefresh moves the current read pointer to the start of the file.
 // If f does not implement io.Seeker then the file is closed, and re-opened from the given filesystem.
 func seekOrRefresh(f fs.File, from fs.FS, name string) (fs.File, error) {
@@ -242,7 +454,19 @@ func guessContentType(r io.Reader) (string, bool) {
 }
 
 func stat(ctx *RequestContext, key string) (*Object, error) {
-	f, err := ctx.Filesystem.Open(key)
+	return statFS(ctx.Filesystem, ctx.etags, ctx.objectCacheKey(key), key)
+}
+
+// statFS opens the object key in fsys.
+// etagPath is the path of the object in the ETag cache.
+// The object is not read to compute its ETag, which is only set if it is cached until LoadETag is called.
+func statFS(fsys fs.FS, etags *ETagCache, etagPath string, key string) (*Object, error) {
+	// Files used by ls3 itself are never objects
+	if isReservedPath(key) {
+		return nil, unwrapFsError(os.ErrNotExist)
+	}
+
+	f, err := fsys.Open(key)
 	if err != nil {
 		return nil, unwrapFsError(err)
 	}
@@ -258,13 +482,33 @@ func stat(ctx *RequestContext, key string) (*Object, error) {
 		return nil, unwrapFsError(os.ErrNotExist)
 	}
 
-	contentType, mustRefresh := guessContentType(f)
+	etag, _ := etags.Lookup(etagPath, fi)
 
-	if mustRefresh {
-		// If file needs refreshing after guessing the content type then do so
-		f, err = seekOrRefresh(f, ctx.Filesystem, key)
-		if err != nil {
-			return nil, unwrapFsError(err)
+	tags, err := readObjectTags(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, unwrapFsError(err)
+	}
+
+	meta, err := readObjectMetadata(fsys, key)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	var contentType string
+	if meta != nil && meta.ContentType != "" {
+		contentType = meta.ContentType
+	} else {
+		var mustRefresh bool
+		contentType, mustRefresh = guessContentType(f)
+
+		if mustRefresh {
+			// If file needs refreshing after guessing the content type then do so
+			f, err = seekOrRefresh(f, fsys, key)
+			if err != nil {
+				return nil, unwrapFsError(err)
+			}
 		}
 	}
 
@@ -273,11 +517,15 @@ func stat(ctx *RequestContext, key string) (*Object, error) {
 		ReadCloser:   f,
 		LastModified: fi.ModTime().UTC(),
 		ContentType:  contentType,
-	}
-
-	err = limitRange(ctx.Request, obj)
-	if err != nil {
-		return nil, err
+		ETag:         etag,
+		Tags:         tags,
+		Metadata:     meta,
+		etag: func() (string, error) {
+			return etags.Get(fsys, etagPath, key, fi)
+		},
+		checksum: func() (string, error) {
+			return etags.Checksum(fsys, etagPath, key, fi)
+		},
 	}
 
 	return obj, nil
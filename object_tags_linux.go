@@ -0,0 +1,111 @@
+//go:build linux
+
+package ls3
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// objectTagXattrPrefix is the prefix of extended attributes that contain object tags.
+// The remainder of the attribute name is the tag key, and the attribute value is the tag value.
+const objectTagXattrPrefix = "user.s3.tag."
+
+// readObjectTags reads object tags from the extended attributes of f.
+// It returns no tags if f is not an operating system file, or the filesystem does not support extended attributes.
+func readObjectTags(f fs.File) (map[string]string, error) {
+	sc, ok := f.(syscall.Conn)
+	if !ok {
+		return nil, nil
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tags    map[string]string
+		readErr error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		tags, readErr = readXattrTags(int(fd))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, readErr
+}
+
+// xattrBuffer reads an extended attribute value using read, growing the buffer to the size of the value.
+func xattrBuffer(read func(dest []byte) (int, error)) ([]byte, error) {
+	sz, err := read(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		buf := make([]byte, sz)
+		sz, err = read(buf)
+
+		// The value changed size since it was last read
+		if errors.Is(err, unix.ERANGE) {
+			sz, err = read(nil)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[:sz], nil
+	}
+}
+
+func readXattrTags(fd int) (map[string]string, error) {
+	names, err := xattrBuffer(func(dest []byte) (int, error) {
+		return unix.Flistxattr(fd, dest)
+	})
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tags map[string]string
+
+	// Names are a list of NUL terminated strings
+	for _, name := range strings.Split(string(names), "\x00") {
+		if !strings.HasPrefix(name, objectTagXattrPrefix) || len(name) == len(objectTagXattrPrefix) {
+			continue
+		}
+
+		value, err := xattrBuffer(func(dest []byte) (int, error) {
+			return unix.Fgetxattr(fd, name, dest)
+		})
+		if errors.Is(err, unix.ENODATA) {
+			// Removed since the list of names was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+
+		tags[strings.TrimPrefix(name, objectTagXattrPrefix)] = string(value)
+	}
+
+	return tags, nil
+}
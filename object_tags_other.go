@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ls3
+
+import "io/fs"
+
+// readObjectTags returns no tags on platforms where tags are not supported.
+func readObjectTags(_ fs.File) (map[string]string, error) {
+	return nil, nil
+}
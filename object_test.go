@@ -1,113 +1,209 @@
 package ls3
 
 import (
+	"errors"
+	"fmt"
+	"github.com/gotd/contrib/http_range"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
 
 func Test_checkConditionalRequest(t *testing.T) {
 	obj := &Object{
-		ETag:         "",
+		ETag:         "etag",
 		LastModified: time.Date(2022, 01, 01, 0, 0, 0, 0, time.UTC),
 	}
 
-	t.Run("unconditional", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{}, obj)
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
-	})
-
-	t.Run("If-Match true", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Match": []string{""},
-		}, obj)
-
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
-	})
+	const (
+		before  = "Fri, 31 Dec 2021 00:00:00 GMT"
+		exactly = "Sat, 01 Jan 2022 00:00:00 GMT"
+		after   = "Sun, 02 Jan 2022 00:00:00 GMT"
+	)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		expect int
+	}{
+		{"unconditional", http.Header{}, 0},
+
+		{"If-Match true", http.Header{"If-Match": {`"etag"`}}, 0},
+		{"If-Match true unquoted", http.Header{"If-Match": {"etag"}}, 0},
+		{"If-Match true list", http.Header{"If-Match": {`"other", "etag"`}}, 0},
+		{"If-Match true wildcard", http.Header{"If-Match": {"*"}}, 0},
+		{"If-Match false", http.Header{"If-Match": {`"other"`}}, http.StatusPreconditionFailed},
+
+		{"If-None-Match true", http.Header{"If-None-Match": {`"other"`}}, 0},
+		{"If-None-Match false", http.Header{"If-None-Match": {`"etag"`}}, http.StatusNotModified},
+		{"If-None-Match false weak", http.Header{"If-None-Match": {`W/"etag"`}}, http.StatusNotModified},
+		{"If-None-Match false wildcard", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+
+		{"If-Modified-Since true", http.Header{"If-Modified-Since": {before}}, 0},
+		{"If-Modified-Since false exactly", http.Header{"If-Modified-Since": {exactly}}, http.StatusNotModified},
+		{"If-Modified-Since false", http.Header{"If-Modified-Since": {after}}, http.StatusNotModified},
+
+		{"If-Unmodified-Since true", http.Header{"If-Unmodified-Since": {after}}, 0},
+		{"If-Unmodified-Since true exactly", http.Header{"If-Unmodified-Since": {exactly}}, 0},
+		{"If-Unmodified-Since false", http.Header{"If-Unmodified-Since": {before}}, http.StatusPreconditionFailed},
+
+		// If-Match takes precedence over If-Unmodified-Since
+		{"If-Match true If-Unmodified-Since false", http.Header{"If-Match": {`"etag"`}, "If-Unmodified-Since": {before}}, 0},
+		{"If-Match false If-Unmodified-Since true", http.Header{"If-Match": {`"other"`}, "If-Unmodified-Since": {after}}, http.StatusPreconditionFailed},
+
+		// If-None-Match takes precedence over If-Modified-Since
+		{"If-None-Match false If-Modified-Since true", http.Header{"If-None-Match": {`"etag"`}, "If-Modified-Since": {before}}, http.StatusNotModified},
+		{"If-None-Match true If-Modified-Since false", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {after}}, 0},
+
+		// Preconditions that fail take precedence over cache validation
+		{"If-Match false If-None-Match false", http.Header{"If-Match": {`"other"`}, "If-None-Match": {`"etag"`}}, http.StatusPreconditionFailed},
+		{"If-Unmodified-Since false If-Modified-Since false", http.Header{"If-Unmodified-Since": {before}, "If-Modified-Since": {after}}, http.StatusPreconditionFailed},
+		{"If-Match true If-None-Match false", http.Header{"If-Match": {`"etag"`}, "If-None-Match": {`"etag"`}}, http.StatusNotModified},
+		{"If-Unmodified-Since true If-Modified-Since false", http.Header{"If-Unmodified-Since": {after}, "If-Modified-Since": {after}}, http.StatusNotModified},
+		{"If-Match true If-Modified-Since false", http.Header{"If-Match": {`"etag"`}, "If-Modified-Since": {after}}, http.StatusNotModified},
+		{"If-Match true If-Modified-Since true", http.Header{"If-Match": {`"etag"`}, "If-Modified-Since": {before}}, 0},
+	}
 
-	t.Run("If-Match false", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Match": []string{"some other etag"},
-		}, obj)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := checkConditionalRequest(test.header, obj)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expect, code)
+		})
+	}
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusPreconditionFailed, code)
+	t.Run("invalid_date", func(t *testing.T) {
+		_, err := checkConditionalRequest(http.Header{"If-Modified-Since": {"yesterday"}}, obj)
+		assert.True(t, errors.Is(err, &exception.Error{ErrorCode: exception.InvalidArgument}))
 	})
+}
 
-	t.Run("If-None-Match true", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-None-Match": []string{"some other etag"},
-		}, obj)
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
 
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
-	})
+func (nopSeekCloser) Close() error {
+	return nil
+}
 
-	t.Run("If-None-Match false", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-None-Match": []string{""},
-		}, obj)
+func Test_limitRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifRange string
+		ranged  bool
+	}{
+		{"unconditional", "", true},
+		{"If-Range etag true", `"etag"`, true},
+		{"If-Range etag false", `"other"`, false},
+		{"If-Range etag weak", `W/"etag"`, false},
+		{"If-Range date true", "Sat, 01 Jan 2022 00:00:00 GMT", true},
+		{"If-Range date false", "Sun, 02 Jan 2022 00:00:00 GMT", false},
+		{"If-Range invalid", "invalid", false},
+	}
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusNotModified, code)
-	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &Object{
+				ReadCloser:   nopSeekCloser{strings.NewReader("0123456789")},
+				Size:         10,
+				ETag:         "etag",
+				LastModified: time.Date(2022, 01, 01, 0, 0, 0, 0, time.UTC),
+			}
+
+			r := &http.Request{Header: http.Header{"Range": {"bytes=2-4"}}}
+			if test.ifRange != "" {
+				r.Header.Set("If-Range", test.ifRange)
+			}
+
+			assert.NoError(t, limitRange(r, obj))
+
+			b, _ := io.ReadAll(obj)
+			if test.ranged {
+				assert.NotNil(t, obj.Range)
+				assert.Equal(t, "234", string(b))
+			} else {
+				assert.Nil(t, obj.Range)
+				assert.Equal(t, "0123456789", string(b))
+			}
+		})
+	}
+}
 
-	t.Run("If-Modified-Since true", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Modified-Since": []string{"Sat, 02 Jan 2022 00:00:00 GMT"},
-		}, obj)
+func Test_limitRange_maxRanges(t *testing.T) {
+	var newObject = func() *Object {
+		return &Object{
+			ReadCloser: nopSeekCloser{strings.NewReader(strings.Repeat("0123456789", 100))},
+			Size:       1000,
+		}
+	}
 
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
-	})
+	var header []string
+	for i := 0; i <= maxRanges; i++ {
+		header = append(header, fmt.Sprintf("%d-%d", i*2, i*2))
+	}
 
-	t.Run("If-Modified-Since false", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Modified-Since": []string{"Thu, 31 Dec 2021 00:00:00 GMT"},
-		}, obj)
+	obj := newObject()
+	assert.NoError(t, limitRange(&http.Request{Header: http.Header{"Range": {"bytes=" + strings.Join(header, ",")}}}, obj))
+	assert.Nil(t, obj.Range)
+	assert.Nil(t, obj.Ranges)
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusNotModified, code)
-	})
+	// Overlapping ranges are merged before they are counted
+	header = header[:0]
+	for i := 0; i <= maxRanges; i++ {
+		header = append(header, fmt.Sprintf("%d-%d", i, i))
+	}
 
-	t.Run("If-Unmodified-Since true", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Unmodified-Since": []string{"Sat, 02 Jan 2022 00:00:00 GMT"},
-		}, obj)
+	obj = newObject()
+	assert.NoError(t, limitRange(&http.Request{Header: http.Header{"Range": {"bytes=" + strings.Join(header, ",")}}}, obj))
+	if assert.NotNil(t, obj.Range) {
+		assert.Equal(t, int64(maxRanges+1), obj.Range.Length)
+	}
+}
 
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
+func Test_coalesceRanges(t *testing.T) {
+	ranges := coalesceRanges([]http_range.Range{
+		{Start: 20, Length: 5},
+		{Start: 0, Length: 10},
+		{Start: 5, Length: 10},
+		{Start: 15, Length: 5},
+		{Start: 40, Length: 1},
 	})
 
-	t.Run("If-Unmodified-Since false", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Unmodified-Since": []string{"Thu, 31 Dec 2021 00:00:00 GMT"},
-		}, obj)
-
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusPreconditionFailed, code)
-	})
+	assert.Equal(t, []http_range.Range{
+		{Start: 0, Length: 25},
+		{Start: 40, Length: 1},
+	}, ranges)
+}
 
-	t.Run("Consideration-1", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-Match":          []string{""},
-			"If-Modified-Since": []string{"Sat, 02 Jan 2022 00:00:00 GMT"},
-		}, obj)
+func TestObject_Get_ExistingObjectTag(t *testing.T) {
+	policy := []*idp.PolicyStatement{
+		{
+			Action:   []idp.Action{idp.GetObject},
+			Resource: []idp.Resource{"*"},
+			Condition: idp.PolicyConditions{
+				idp.StringEquals: {
+					"s3:ExistingObjectTag/classification": {"public"},
+				},
+			},
+		},
+	}
 
-		assert.NoError(t, err)
-		assert.Equal(t, 0, code)
-	})
+	public := &Object{Tags: map[string]string{"classification": "public"}}
+	v, ok := public.Get("s3:ExistingObjectTag/classification")
+	assert.True(t, ok)
+	assert.Equal(t, "public", v)
+	assert.Nil(t, idp.EvaluatePolicy(idp.GetObject, "bucket/public.txt", policy, public))
 
-	t.Run("Consideration-2", func(t *testing.T) {
-		code, err := checkConditionalRequest(http.Header{
-			"If-None-Match":     []string{""},
-			"If-Modified-Since": []string{"Sat, 02 Jan 2022 00:00:00 GMT"},
-		}, obj)
+	private := &Object{Tags: map[string]string{"classification": "private"}}
+	assert.NotNil(t, idp.EvaluatePolicy(idp.GetObject, "bucket/private.txt", policy, private))
 
-		assert.NoError(t, err)
-		assert.Equal(t, http.StatusNotModified, code)
-	})
+	untagged := &Object{}
+	_, ok = untagged.Get("s3:ExistingObjectTag/classification")
+	assert.False(t, ok)
+	assert.NotNil(t, idp.EvaluatePolicy(idp.GetObject, "bucket/untagged.txt", policy, untagged))
 }
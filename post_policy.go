@@ -0,0 +1,182 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"strings"
+	"time"
+)
+
+const (
+	postPolicyEquals             = "eq"
+	postPolicyStartsWith         = "starts-with"
+	postPolicyContentLengthRange = "content-length-range"
+)
+
+// PostPolicyCondition is a condition of a POST policy document.
+type PostPolicyCondition struct {
+	Operator string
+	// Field is the lower case name of the form field, without the leading $.
+	Field string
+	Value string
+	// Min and Max are the bounds of a content-length-range condition.
+	Min int64
+	Max int64
+}
+
+func (c *PostPolicyCondition) String() string {
+	if c.Operator == postPolicyContentLengthRange {
+		return fmt.Sprintf("[%q, %d, %d]", c.Operator, c.Min, c.Max)
+	}
+
+	return fmt.Sprintf("[%q, %q, %q]", c.Operator, "$"+c.Field, c.Value)
+}
+
+// Matches returns true if the value of the field of the condition matches the condition.
+func (c *PostPolicyCondition) Matches(value string) bool {
+	switch c.Operator {
+	case postPolicyEquals:
+		return value == c.Value
+	case postPolicyStartsWith:
+		return strings.HasPrefix(value, c.Value)
+	default:
+		return true
+	}
+}
+
+// UnmarshalJSON reads a condition that is either an exact match object such as {"acl": "public-read"},
+// or a list such as ["starts-with", "$key", "user/"] or ["content-length-range", 0, 1024].
+func (c *PostPolicyCondition) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		var match map[string]string
+		if err := json.Unmarshal(b, &match); err != nil || len(match) != 1 {
+			return fmt.Errorf("invalid condition %s", b)
+		}
+
+		for k, v := range match {
+			c.Operator = postPolicyEquals
+			c.Field = strings.ToLower(strings.TrimPrefix(k, "$"))
+			c.Value = v
+		}
+
+		return nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(b, &list); err != nil || len(list) != 3 {
+		return fmt.Errorf("invalid condition %s", b)
+	}
+
+	if err := json.Unmarshal(list[0], &c.Operator); err != nil {
+		return fmt.Errorf("invalid condition %s", b)
+	}
+
+	c.Operator = strings.ToLower(c.Operator)
+
+	switch c.Operator {
+	case postPolicyEquals, postPolicyStartsWith:
+		var field string
+		if json.Unmarshal(list[1], &field) != nil || !strings.HasPrefix(field, "$") || json.Unmarshal(list[2], &c.Value) != nil {
+			return fmt.Errorf("invalid condition %s", b)
+		}
+
+		c.Field = strings.ToLower(field[1:])
+	case postPolicyContentLengthRange:
+		if json.Unmarshal(list[1], &c.Min) != nil || json.Unmarshal(list[2], &c.Max) != nil || c.Min < 0 || c.Max < c.Min {
+			return fmt.Errorf("invalid condition %s", b)
+		}
+	default:
+		return fmt.Errorf("unsupported condition operator %q", c.Operator)
+	}
+
+	return nil
+}
+
+// PostPolicy is the policy document of a browser based upload using POST Object.
+type PostPolicy struct {
+	Expiration time.Time              `json:"expiration"`
+	Conditions []*PostPolicyCondition `json:"conditions"`
+}
+
+// ParsePostPolicy parses a JSON POST policy document.
+func ParsePostPolicy(b []byte) (*PostPolicy, error) {
+	var policy PostPolicy
+	err := json.Unmarshal(b, &policy)
+	if err != nil || policy.Expiration.IsZero() {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidPolicyDocument,
+			Message:   "Invalid Policy: Invalid JSON.",
+		}
+	}
+
+	for _, c := range policy.Conditions {
+		if c == nil {
+			return nil, &exception.Error{
+				ErrorCode: exception.InvalidPolicyDocument,
+				Message:   "Invalid Policy: Invalid JSON.",
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+// isPostPolicyExemptField returns true if the form field does not need to be covered by a condition of the policy.
+func isPostPolicyExemptField(name string) bool {
+	switch name {
+	case "policy", "x-amz-signature", "file":
+		return true
+	default:
+		return strings.HasPrefix(name, "x-ignore-")
+	}
+}
+
+// Check returns an error if the form fields of a request to upload to bucket do not satisfy the policy.
+// Every form field must be covered by a condition of the policy.
+func (p *PostPolicy) Check(bucket string, fields map[string]string) *exception.Error {
+	var covered = make(map[string]bool)
+
+	for _, c := range p.Conditions {
+		if c.Operator == postPolicyContentLengthRange {
+			continue
+		}
+
+		covered[c.Field] = true
+
+		value := fields[c.Field]
+		if c.Field == "bucket" {
+			value = bucket
+		}
+
+		if !c.Matches(value) {
+			return &exception.Error{
+				ErrorCode: exception.AccessDenied,
+				Message:   "Invalid according to Policy: Policy Condition failed: " + c.String(),
+			}
+		}
+	}
+
+	for name := range fields {
+		if !covered[name] && !isPostPolicyExemptField(name) {
+			return &exception.Error{
+				ErrorCode: exception.AccessDenied,
+				Message:   "Invalid according to Policy: Extra input fields: " + name,
+			}
+		}
+	}
+
+	return nil
+}
+
+// ContentLengthRange returns the bounds of the content-length-range condition of the policy, if any.
+func (p *PostPolicy) ContentLengthRange() (minLength int64, maxLength int64, ok bool) {
+	for _, c := range p.Conditions {
+		if c.Operator == postPolicyContentLengthRange {
+			return c.Min, c.Max, true
+		}
+	}
+
+	return 0, 0, false
+}
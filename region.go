@@ -0,0 +1,122 @@
+package ls3
+
+import (
+	"encoding/json"
+	"github.com/relvacode/ls3/exception"
+	"go.uber.org/zap"
+	"io/fs"
+	"regexp"
+)
+
+// DefaultRegion is the region of the server if no region is configured.
+const DefaultRegion = "us-east-1"
+
+// bucketRegionFile is the name of the file, at the root of each bucket, that contains the region of the bucket.
+const bucketRegionFile = reservedNamePrefix + "region.json"
+
+// BucketRegion is the region of a bucket that is located in a region other than the region of the server.
+type BucketRegion struct {
+	Region string
+}
+
+var regionNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validRegionName returns true if name is a valid name of a region, such as eu-west-1.
+func validRegionName(name string) bool {
+	return len(name) <= 32 && regionNamePattern.MatchString(name)
+}
+
+// readBucketRegion reads the region of the bucket filesystem.
+// It returns an empty string if the bucket has no region of its own.
+func readBucketRegion(fsys fs.FS) (string, error) {
+	b, err := readBucketConfig(fsys, bucketRegionFile)
+	if err != nil || b == nil {
+		return "", err
+	}
+
+	var config BucketRegion
+	err = json.Unmarshal(b, &config)
+	if err != nil || !validRegionName(config.Region) {
+		return "", &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   "The region of this bucket is not valid.",
+		}
+	}
+
+	return config.Region, nil
+}
+
+// bucketRegion returns the region of the bucket in the request.
+// Requests without a bucket, and buckets without a region of their own, are in the region of the server.
+func (s *Server) bucketRegion(ctx *RequestContext) string {
+	if ctx.Filesystem == nil {
+		return s.region
+	}
+
+	region, err := readBucketRegion(ctx.Filesystem)
+	if err != nil {
+		ctx.Warn("Unable to read the region of the bucket", zap.Error(err))
+	}
+	if region == "" {
+		return s.region
+	}
+
+	return region
+}
+
+// checkRequestScope returns an error if the request is signed for a service other than the service of the request,
+// or a region other than the region of the bucket.
+func (s *Server) checkRequestScope(ctx *RequestContext) *exception.Error {
+	signed, ok := signedCredential(ctx.Request)
+	if !ok {
+		return nil
+	}
+
+	// The region of a bucket that does not exist yet is checked by CreateBucket, as it depends on the location constraint
+	if ctx.Filesystem == nil && isCreateBucketRequest(ctx.Request) {
+		return checkSignedService(ctx, signed)
+	}
+
+	return s.checkSignedScope(ctx, signed)
+}
+
+// checkSignedScope returns an error if the credential scope signed is not for the service of the request,
+// or the region of the bucket in the request.
+func (s *Server) checkSignedScope(ctx *RequestContext, signed Credential) *exception.Error {
+	if err := checkSignedService(ctx, signed); err != nil {
+		return err
+	}
+
+	return s.checkSignedRegion(ctx, signed.Region)
+}
+
+// checkSignedService returns an error if the credential scope signed is not for the service of the request.
+// Only requests to the security token service may be signed for the sts service.
+func checkSignedService(ctx *RequestContext, signed Credential) *exception.Error {
+	var services = s3Services
+	if isSecurityTokenServiceRequest(ctx) {
+		services = stsServices
+	}
+
+	if !services[signed.Service] {
+		return &exception.Error{
+			ErrorCode: exception.AuthorizationHeaderMalformed,
+			Message:   "The authorization header is malformed; the service '" + signed.Service + "' is wrong for this request.",
+		}
+	}
+
+	return nil
+}
+
+// checkSignedRegion returns an error if signed is not the region of the bucket in the request.
+func (s *Server) checkSignedRegion(ctx *RequestContext, signed string) *exception.Error {
+	if expected := s.bucketRegion(ctx); signed != expected {
+		return &exception.Error{
+			ErrorCode: exception.AuthorizationHeaderMalformed,
+			Message:   "The authorization header is malformed; the region '" + signed + "' is wrong; expecting '" + expected + "'",
+			Region:    expected,
+		}
+	}
+
+	return nil
+}
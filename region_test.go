@@ -0,0 +1,121 @@
+package ls3
+
+import (
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testRegionServer is like testWritableServer, but the bucket is located in the given region.
+func testRegionServer(t *testing.T, region string) *Server {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, bucketRegionFile), []byte(`{"Region": "`+region+`"}`), 0644)
+
+	return testWritableServer(dir)
+}
+
+func TestServer_BucketRegion(t *testing.T) {
+	t.Run("head bucket", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{Region: "eu-west-1"}, http.MethodHead, "/bucket", "", nil, nil)
+		testRegionServer(t, "eu-west-1").ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "eu-west-1", rw.Header().Get("x-amz-bucket-region"))
+	})
+
+	t.Run("get bucket location", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{Region: "eu-west-1"}, http.MethodGet, "/bucket", "location=", nil, nil)
+		testRegionServer(t, "eu-west-1").ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">eu-west-1</LocationConstraint>`, rw.Body.String())
+	})
+
+	t.Run("wrong region", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket", "location=", nil, nil)
+		testRegionServer(t, "eu-west-1").ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.AuthorizationHeaderMalformed)
+		assert.Equal(t, "eu-west-1", rw.Header().Get("x-amz-bucket-region"))
+		assert.Contains(t, rw.Body.String(), "<Region>eu-west-1</Region>")
+	})
+
+	t.Run("server region", func(t *testing.T) {
+		srv := testServer()
+		srv.region = "eu-central-1"
+
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/", "", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.AuthorizationHeaderMalformed)
+
+		rw = httptest.NewRecorder()
+		srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{Region: "eu-central-1"}, http.MethodHead, "/bucket", "", nil, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "eu-central-1", rw.Header().Get("x-amz-bucket-region"))
+	})
+}
+
+// testServiceSignedRequest returns a request signed like testSignedRequest, but with a credential scope for service.
+func testServiceSignedRequest(service, method, path string, headers http.Header, payload []byte) *http.Request {
+	req := testSignedRequest(SignAWSV4{}, method, path, "", headers, payload)
+
+	var (
+		at, _            = ParseAmzTime(req.Header.Get(xAmzDate))
+		canonicalRequest = awsV4CanonicalRequest(req, []byte(req.Header.Get(xAmzContextSha256)), []string{"host", "x-amz-content-sha256", "x-amz-date"})
+		signature        = sumHmacSha256(SignAWSV4{}.computeSigningKey(at, DefaultRegion, service, idp.TestIdentity), SignAWSV4{}.computeStringToSign(at, DefaultRegion, service, canonicalRequest))
+	)
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s/%s/%s/aws4_request,SignedHeaders=host;x-amz-content-sha256;x-amz-date,Signature=%x",
+		awsSignatureVersionV4,
+		idp.TestIdentity.AccessKeyId,
+		at.Format(amzDateFormat),
+		DefaultRegion,
+		service,
+		signature,
+	))
+
+	return req
+}
+
+func TestServer_SignedService(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+	srv := testSessionServer(dir)
+
+	t.Run("s3", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testServiceSignedRequest("s3", http.MethodGet, "/bucket/object.txt", nil, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+	})
+
+	t.Run("sts for s3", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testServiceSignedRequest("sts", http.MethodGet, "/bucket/object.txt", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.AuthorizationHeaderMalformed)
+	})
+
+	t.Run("sts", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, testServiceSignedRequest("sts", http.MethodPost, "/", http.Header{
+			"Content-Type": []string{"application/x-www-form-urlencoded"},
+		}, []byte("Action=GetSessionToken&Version=2011-06-15")))
+
+		assert.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	})
+}
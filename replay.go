@@ -0,0 +1,68 @@
+package ls3
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultReplayCacheSize is the default maximum number of signatures held by a ReplayCache.
+const DefaultReplayCacheSize = 100000
+
+// NewReplayCache creates a new ReplayCache that holds up to size signatures.
+// If size is less than 1 then DefaultReplayCacheSize is used.
+func NewReplayCache(size int) *ReplayCache {
+	if size < 1 {
+		size = DefaultReplayCacheSize
+	}
+
+	return &ReplayCache{
+		size:    size,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// ReplayCache remembers the signatures of recently verified requests, so that an exact replay of a request is rejected.
+// Each signature is remembered until the request would be rejected for being outside the allowed clock skew.
+// If the cache is full then the oldest signature is forgotten.
+type ReplayCache struct {
+	size int
+
+	mx      sync.Mutex
+	entries map[string]time.Time
+	// order is the signatures in the order they were first seen.
+	order []string
+}
+
+// evictOldest forgets the oldest signature.
+func (c *ReplayCache) evictOldest() {
+	delete(c.entries, c.order[0])
+	c.order = c.order[1:]
+}
+
+// Seen records the signature until expires, and returns true if the signature has already been seen and has not expired.
+func (c *ReplayCache) Seen(signature string, expires time.Time, now time.Time) bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	// Forget signatures that have expired
+	for len(c.order) > 0 {
+		if e, ok := c.entries[c.order[0]]; ok && e.After(now) {
+			break
+		}
+
+		c.evictOldest()
+	}
+
+	if e, ok := c.entries[signature]; ok && e.After(now) {
+		return true
+	}
+
+	for len(c.entries) >= c.size {
+		c.evictOldest()
+	}
+
+	c.entries[signature] = expires
+	c.order = append(c.order, signature)
+
+	return false
+}
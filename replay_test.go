@@ -0,0 +1,27 @@
+package ls3
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReplayCache_Seen(t *testing.T) {
+	var (
+		now     = time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+		expires = now.Add(time.Minute)
+		cache   = NewReplayCache(2)
+	)
+
+	assert.False(t, cache.Seen("a", expires, now))
+	assert.True(t, cache.Seen("a", expires, now))
+
+	// The signature is forgotten once it expires
+	assert.False(t, cache.Seen("a", expires.Add(time.Minute), expires))
+
+	// The oldest signature is forgotten once the cache is full
+	assert.False(t, cache.Seen("b", expires.Add(time.Minute), expires))
+	assert.False(t, cache.Seen("c", expires.Add(time.Minute), expires))
+	assert.False(t, cache.Seen("a", expires.Add(time.Minute), expires))
+	assert.True(t, cache.Seen("c", expires.Add(time.Minute), expires))
+}
@@ -0,0 +1,102 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// eventHeaderTypeString is the event stream header value type for a UTF-8 string.
+const eventHeaderTypeString = 7
+
+type eventHeader struct {
+	Name  string
+	Value string
+}
+
+// EventStreamWriter writes messages using the AWS event stream binary framing.
+//
+// Each message is encoded as
+//
+//	[total length][headers length][prelude crc][headers][payload][message crc]
+//
+// where each length and CRC is a big-endian uint32.
+type EventStreamWriter struct {
+	w io.Writer
+}
+
+func NewEventStreamWriter(w io.Writer) *EventStreamWriter {
+	return &EventStreamWriter{w: w}
+}
+
+func (e *EventStreamWriter) writeMessage(headers []eventHeader, payload []byte) error {
+	var hdr bytes.Buffer
+	for _, h := range headers {
+		hdr.WriteByte(byte(len(h.Name)))
+		hdr.WriteString(h.Name)
+		hdr.WriteByte(eventHeaderTypeString)
+		_ = binary.Write(&hdr, binary.BigEndian, uint16(len(h.Value)))
+		hdr.WriteString(h.Value)
+	}
+
+	var (
+		msg         bytes.Buffer
+		totalLength = 4 + 4 + 4 + hdr.Len() + len(payload) + 4
+	)
+
+	_ = binary.Write(&msg, binary.BigEndian, uint32(totalLength))
+	_ = binary.Write(&msg, binary.BigEndian, uint32(hdr.Len()))
+	_ = binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+
+	msg.Write(hdr.Bytes())
+	msg.Write(payload)
+
+	_ = binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+
+	_, err := msg.WriteTo(e.w)
+	return err
+}
+
+// Records writes a Records event containing the given payload.
+func (e *EventStreamWriter) Records(payload []byte) error {
+	return e.writeMessage([]eventHeader{
+		{Name: ":event-type", Value: "Records"},
+		{Name: ":content-type", Value: "application/octet-stream"},
+		{Name: ":message-type", Value: "event"},
+	}, payload)
+}
+
+// Stats writes a Stats event.
+func (e *EventStreamWriter) Stats(stats *Stats) error {
+	payload := fmt.Sprintf(
+		"<Stats><BytesScanned>%d</BytesScanned><BytesProcessed>%d</BytesProcessed><BytesReturned>%d</BytesReturned></Stats>",
+		stats.BytesScanned,
+		stats.BytesProcessed,
+		stats.BytesReturned,
+	)
+
+	return e.writeMessage([]eventHeader{
+		{Name: ":event-type", Value: "Stats"},
+		{Name: ":content-type", Value: "text/xml"},
+		{Name: ":message-type", Value: "event"},
+	}, []byte(payload))
+}
+
+// End writes an End event, which must be the last event of a successful response.
+func (e *EventStreamWriter) End() error {
+	return e.writeMessage([]eventHeader{
+		{Name: ":event-type", Value: "End"},
+		{Name: ":message-type", Value: "event"},
+	}, nil)
+}
+
+// Error writes an error message, which ends the response.
+func (e *EventStreamWriter) Error(code, message string) error {
+	return e.writeMessage([]eventHeader{
+		{Name: ":error-code", Value: code},
+		{Name: ":error-message", Value: message},
+		{Name: ":message-type", Value: "error"},
+	}, nil)
+}
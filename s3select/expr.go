@@ -0,0 +1,507 @@
+package s3select
+
+import (
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"math"
+	"strings"
+)
+
+type expr interface {
+	eval(rec Record) (Value, error)
+}
+
+func errInvalidArguments(format string, args ...any) error {
+	return &exception.Error{
+		ErrorCode: exception.EvaluatorInvalidArguments,
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
+
+type literal struct {
+	value Value
+}
+
+func (e *literal) eval(_ Record) (Value, error) {
+	return e.value, nil
+}
+
+type columnRef struct {
+	path []pathElement
+}
+
+func (e *columnRef) eval(rec Record) (Value, error) {
+	return rec.Get(e.path), nil
+}
+
+// name returns the name used for this column in JSON output.
+func (e *columnRef) name() string {
+	for i := len(e.path) - 1; i >= 0; i-- {
+		if e.path[i].index < 0 {
+			return e.path[i].name
+		}
+	}
+
+	return ""
+}
+
+type notExpr struct {
+	e expr
+}
+
+func (e *notExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	b, ok := toBool(v)
+	if !ok {
+		return nil, errInvalidArguments("NOT requires a boolean argument.")
+	}
+
+	return !b, nil
+}
+
+type negateExpr struct {
+	e expr
+}
+
+func (e *negateExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	n, ok := toNumber(v)
+	if !ok {
+		return nil, errInvalidArguments("Unary minus requires a numeric argument.")
+	}
+
+	return -n, nil
+}
+
+// logicalExpr implements AND and OR using three valued logic, where nil is unknown.
+type logicalExpr struct {
+	and         bool
+	left, right expr
+}
+
+func (e *logicalExpr) eval(rec Record) (Value, error) {
+	evalBool := func(x expr) (*bool, error) {
+		v, err := x.eval(rec)
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		b, ok := toBool(v)
+		if !ok {
+			return nil, errInvalidArguments("Logical operators require boolean arguments.")
+		}
+
+		return &b, nil
+	}
+
+	left, err := evalBool(e.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit
+	if left != nil && *left != e.and {
+		return *left, nil
+	}
+
+	right, err := evalBool(e.right)
+	if err != nil {
+		return nil, err
+	}
+
+	if right != nil && *right != e.and {
+		return *right, nil
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	return e.and, nil
+}
+
+type comparisonExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *comparisonExpr) eval(rec Record) (Value, error) {
+	left, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := compareValues(left, right)
+	if !ok {
+		return nil, nil
+	}
+
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	default:
+		return nil, errInvalidArguments("Unknown comparison operator %s.", e.op)
+	}
+}
+
+type arithmeticExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *arithmeticExpr) eval(rec Record) (Value, error) {
+	left, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if e.op == "||" {
+		return toString(left) + toString(right), nil
+	}
+
+	x, okX := toNumber(left)
+	y, okY := toNumber(right)
+	if !okX || !okY {
+		return nil, errInvalidArguments("Operator %s requires numeric arguments.", e.op)
+	}
+
+	switch e.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return nil, errInvalidArguments("Division by zero.")
+		}
+		return x / y, nil
+	case "%":
+		if y == 0 {
+			return nil, errInvalidArguments("Division by zero.")
+		}
+		return math.Mod(x, y), nil
+	default:
+		return nil, errInvalidArguments("Unknown arithmetic operator %s.", e.op)
+	}
+}
+
+type likeExpr struct {
+	e, pattern, escape expr
+	not                bool
+}
+
+func (e *likeExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	pattern, err := e.pattern.eval(rec)
+	if err != nil || pattern == nil {
+		return nil, err
+	}
+
+	var escape rune
+	if e.escape != nil {
+		escapeValue, err := e.escape.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+
+		escapeRunes := []rune(toString(escapeValue))
+		if len(escapeRunes) != 1 {
+			return nil, errInvalidArguments("The LIKE escape must be a single character.")
+		}
+
+		escape = escapeRunes[0]
+	}
+
+	return likeMatch([]rune(toString(pattern)), []rune(toString(v)), escape) != e.not, nil
+}
+
+type isNullExpr struct {
+	e   expr
+	not bool
+}
+
+func (e *isNullExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return (v == nil) != e.not, nil
+}
+
+type betweenExpr struct {
+	e, low, high expr
+	not          bool
+}
+
+func (e *betweenExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	low, err := e.low.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	high, err := e.high.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	c1, ok1 := compareValues(v, low)
+	c2, ok2 := compareValues(v, high)
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+
+	return (c1 >= 0 && c2 <= 0) != e.not, nil
+}
+
+type inExpr struct {
+	e    expr
+	list []expr
+	not  bool
+}
+
+func (e *inExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	for _, item := range e.list {
+		x, err := item.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+
+		if c, ok := compareValues(v, x); ok && c == 0 {
+			return !e.not, nil
+		}
+	}
+
+	return e.not, nil
+}
+
+type castExpr struct {
+	e   expr
+	typ string
+}
+
+func (e *castExpr) eval(rec Record) (Value, error) {
+	v, err := e.e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	switch e.typ {
+	case "INT", "INTEGER":
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, errInvalidArguments("Unable to cast %q to %s.", toString(v), e.typ)
+		}
+		return math.Trunc(n), nil
+	case "FLOAT", "DECIMAL", "NUMERIC", "DOUBLE", "REAL":
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, errInvalidArguments("Unable to cast %q to %s.", toString(v), e.typ)
+		}
+		return n, nil
+	case "STRING", "VARCHAR", "CHAR":
+		return toString(v), nil
+	case "BOOL", "BOOLEAN":
+		b, ok := toBool(v)
+		if !ok {
+			return nil, errInvalidArguments("Unable to cast %q to %s.", toString(v), e.typ)
+		}
+		return b, nil
+	default:
+		return nil, errInvalidArguments("Unsupported CAST type %s.", e.typ)
+	}
+}
+
+type funcExpr struct {
+	name string
+	args []expr
+}
+
+func (e *funcExpr) eval(rec Record) (Value, error) {
+	var args = make([]Value, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "COALESCE":
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if c, ok := compareValues(args[0], args[1]); ok && c == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	}
+
+	if args[0] == nil {
+		return nil, nil
+	}
+
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(toString(args[0])), nil
+	case "UPPER":
+		return strings.ToUpper(toString(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(toString(args[0])), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return float64(len([]rune(toString(args[0])))), nil
+	case "SUBSTRING":
+		var (
+			s     = []rune(toString(args[0]))
+			start = 1.0
+			end   = float64(len(s) + 1)
+			ok    bool
+		)
+
+		if start, ok = toNumber(args[1]); !ok {
+			return nil, errInvalidArguments("SUBSTRING requires a numeric start position.")
+		}
+
+		if len(args) > 2 {
+			length, ok := toNumber(args[2])
+			if !ok || length < 0 {
+				return nil, errInvalidArguments("SUBSTRING requires a non-negative numeric length.")
+			}
+			end = start + length
+		}
+
+		// Positions are one based, and may start before the beginning of the string
+		first := int(math.Max(start, 1)) - 1
+		last := int(math.Min(end, float64(len(s)+1))) - 1
+
+		if first >= last {
+			return "", nil
+		}
+
+		return string(s[first:last]), nil
+	default:
+		return nil, errInvalidArguments("Unknown function %s.", e.name)
+	}
+}
+
+// aggregateExpr accumulates a value for every record passed to accumulate.
+// The result of eval is the aggregate value of all records accumulated so far.
+type aggregateExpr struct {
+	fn  string
+	arg expr // nil for COUNT(*)
+
+	count int64
+	sum   float64
+	best  Value
+}
+
+func (e *aggregateExpr) accumulate(rec Record) error {
+	if e.arg == nil {
+		e.count++
+		return nil
+	}
+
+	v, err := e.arg.eval(rec)
+	if err != nil || v == nil {
+		return err
+	}
+
+	switch e.fn {
+	case "SUM", "AVG":
+		n, ok := toNumber(v)
+		if !ok {
+			return errInvalidArguments("%s requires numeric arguments.", e.fn)
+		}
+		e.sum += n
+	case "MIN", "MAX":
+		// Compare numerically when possible
+		if n, ok := toNumber(v); ok {
+			v = n
+		}
+
+		if e.best == nil {
+			e.best = v
+			break
+		}
+
+		c, ok := compareValues(v, e.best)
+		if ok && ((e.fn == "MIN" && c < 0) || (e.fn == "MAX" && c > 0)) {
+			e.best = v
+		}
+	}
+
+	e.count++
+	return nil
+}
+
+func (e *aggregateExpr) eval(_ Record) (Value, error) {
+	switch e.fn {
+	case "COUNT":
+		return float64(e.count), nil
+	case "SUM":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return e.sum, nil
+	case "AVG":
+		if e.count == 0 {
+			return nil, nil
+		}
+		return e.sum / float64(e.count), nil
+	default:
+		return e.best, nil
+	}
+}
@@ -0,0 +1,140 @@
+package s3select
+
+import (
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// is returns true if the token is the given symbol, or the given keyword (case-insensitive).
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokenSymbol:
+		return t.value == s
+	case tokenIdent:
+		return strings.EqualFold(t.value, s)
+	default:
+		return false
+	}
+}
+
+func errUnexpectedToken(t token) error {
+	var desc = t.value
+	if t.kind == tokenEOF {
+		desc = "end of expression"
+	}
+
+	return &exception.Error{
+		ErrorCode: exception.ParseUnexpectedToken,
+		Message:   fmt.Sprintf("Unexpected token %q at position %d of the SQL expression.", desc, t.pos),
+	}
+}
+
+// symbols are the recognised symbols, longest first.
+var symbols = []string{
+	"<=", ">=", "<>", "!=", "||",
+	"(", ")", ",", ".", "*", "+", "-", "/", "%", "=", "<", ">", "[", "]",
+}
+
+func lex(expr string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(expr)
+		i      int
+	)
+
+scan:
+	for i < len(runes) {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '\'' || r == '"':
+			// String literal or quoted identifier, where the quote is escaped by repeating it.
+			var (
+				b     strings.Builder
+				start = i
+			)
+
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, &exception.Error{
+						ErrorCode: exception.ParseUnexpectedToken,
+						Message:   fmt.Sprintf("Unterminated quoted value at position %d of the SQL expression.", start),
+					}
+				}
+
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						b.WriteRune(r)
+						i++
+						continue
+					}
+
+					i++
+					break
+				}
+
+				b.WriteRune(runes[i])
+			}
+
+			kind := tokenString
+			if r == '"' {
+				kind = tokenQuotedIdent
+			}
+
+			tokens = append(tokens, token{kind: kind, value: b.String(), pos: start})
+			continue
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[start:i]), pos: start})
+			continue
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, value: string(runes[start:i]), pos: start})
+			continue
+		}
+
+		for _, sym := range symbols {
+			if strings.HasPrefix(string(runes[i:]), sym) {
+				tokens = append(tokens, token{kind: tokenSymbol, value: sym, pos: i})
+				i += len([]rune(sym))
+				continue scan
+			}
+		}
+
+		return nil, errUnexpectedToken(token{kind: tokenSymbol, value: string(r), pos: i})
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
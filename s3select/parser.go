@@ -0,0 +1,638 @@
+package s3select
+
+import (
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"strconv"
+	"strings"
+)
+
+type projection struct {
+	e    expr
+	name string
+}
+
+// Query is a parsed S3 Select SQL expression.
+type Query struct {
+	// projections is nil for SELECT *
+	projections []projection
+	alias       string
+	where       expr
+	limit       int64
+	aggregates  []*aggregateExpr
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+
+	aggregates []*aggregateExpr
+	// columns is the number of column references outside an aggregate function in the projection list.
+	columns int
+	// inAggregate is set while parsing the argument of an aggregate function.
+	inAggregate bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given symbol or keyword.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return errUnexpectedToken(p.peek())
+	}
+	return nil
+}
+
+// reserved are keywords that cannot be used as an unquoted alias.
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AND": true, "OR": true, "NOT": true,
+	"LIKE": true, "IS": true, "NULL": true, "BETWEEN": true, "IN": true, "AS": true, "ESCAPE": true,
+}
+
+func isReserved(t token) bool {
+	return t.kind == tokenIdent && reserved[strings.ToUpper(t.value)]
+}
+
+// ParseQuery parses an S3 Select SQL expression.
+func ParseQuery(sql string) (*Query, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	q := &Query{limit: -1}
+
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if !p.accept("*") {
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			var proj = projection{e: e}
+			if p.accept("AS") {
+				t := p.next()
+				if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+					return nil, errUnexpectedToken(t)
+				}
+				proj.name = t.value
+			}
+
+			q.projections = append(q.projections, proj)
+
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	// Record which projections contain aggregates before parsing the WHERE clause
+	q.aggregates = p.aggregates
+	projectionColumns := p.columns
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+
+	if t := p.next(); !t.is("S3Object") {
+		return nil, errUnexpectedToken(t)
+	}
+
+	// Only the root of the object may be selected from
+	if p.accept("[") {
+		if err := p.expect("*"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+
+	hasAs := p.accept("AS")
+	if t := p.peek(); (t.kind == tokenIdent && !isReserved(t)) || t.kind == tokenQuotedIdent {
+		q.alias = p.next().value
+	} else if hasAs {
+		return nil, errUnexpectedToken(t)
+	}
+
+	if p.accept("WHERE") {
+		p.aggregates = nil
+		q.where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(p.aggregates) > 0 {
+			return nil, &exception.Error{
+				ErrorCode: exception.UnsupportedSqlOperation,
+				Message:   "Aggregate functions are not supported in the WHERE clause.",
+			}
+		}
+	}
+
+	if p.accept("LIMIT") {
+		t := p.next()
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if t.kind != tokenNumber || err != nil || n < 0 {
+			return nil, errUnexpectedToken(t)
+		}
+		q.limit = n
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errUnexpectedToken(t)
+	}
+
+	if len(q.aggregates) > 0 && projectionColumns > 0 {
+		return nil, &exception.Error{
+			ErrorCode: exception.UnsupportedSqlOperation,
+			Message:   "Aggregate and non-aggregate projections cannot be mixed.",
+		}
+	}
+
+	q.resolveAlias()
+	return q, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{and: false, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e: e}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &comparisonExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	if p.accept("IS") {
+		not := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{e: left, not: not}, nil
+	}
+
+	not := p.accept("NOT")
+
+	switch {
+	case p.accept("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+
+		like := &likeExpr{e: left, pattern: pattern, not: not}
+		if p.accept("ESCAPE") {
+			like.escape, err = p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return like, nil
+	case p.accept("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{e: left, low: low, high: high, not: not}, nil
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		in := &inExpr{e: left, not: not}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+
+			if !p.accept(",") {
+				break
+			}
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return in, nil
+	}
+
+	if not {
+		return nil, errUnexpectedToken(p.peek())
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var op string
+		switch {
+		case p.accept("+"):
+			op = "+"
+		case p.accept("-"):
+			op = "-"
+		case p.accept("||"):
+			op = "||"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var op string
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		case p.accept("%"):
+			op = "%"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.accept("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{e: e}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// functionArity is the minimum and maximum number of arguments of each scalar function.
+var functionArity = map[string][2]int{
+	"LOWER":            {1, 1},
+	"UPPER":            {1, 1},
+	"TRIM":             {1, 1},
+	"CHAR_LENGTH":      {1, 1},
+	"CHARACTER_LENGTH": {1, 1},
+	"SUBSTRING":        {2, 3},
+	"COALESCE":         {1, 1 << 16},
+	"NULLIF":           {2, 2},
+}
+
+var aggregateFunctions = map[string]bool{
+	"COUNT": true,
+	"SUM":   true,
+	"AVG":   true,
+	"MIN":   true,
+	"MAX":   true,
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, errUnexpectedToken(t)
+		}
+		return &literal{value: n}, nil
+	case tokenString:
+		return &literal{value: t.value}, nil
+	case tokenQuotedIdent:
+		return p.parseColumnRef(pathElement{name: t.value, quoted: true, index: -1})
+	case tokenSymbol:
+		if t.value == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+		return nil, errUnexpectedToken(t)
+	case tokenIdent:
+	default:
+		return nil, errUnexpectedToken(t)
+	}
+
+	name := strings.ToUpper(t.value)
+
+	switch name {
+	case "TRUE":
+		return &literal{value: true}, nil
+	case "FALSE":
+		return &literal{value: false}, nil
+	case "NULL", "MISSING":
+		return &literal{value: nil}, nil
+	}
+
+	if isReserved(t) {
+		return nil, errUnexpectedToken(t)
+	}
+
+	if !p.peek().is("(") {
+		return p.parseColumnRef(pathElement{name: t.value, index: -1})
+	}
+
+	p.next()
+
+	switch {
+	case name == "CAST":
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AS"); err != nil {
+			return nil, err
+		}
+		typ := p.next()
+		if typ.kind != tokenIdent {
+			return nil, errUnexpectedToken(typ)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &castExpr{e: e, typ: strings.ToUpper(typ.value)}, nil
+	case aggregateFunctions[name]:
+		if p.inAggregate {
+			return nil, &exception.Error{
+				ErrorCode: exception.UnsupportedSqlOperation,
+				Message:   "Aggregate functions cannot be nested.",
+			}
+		}
+
+		agg := &aggregateExpr{fn: name}
+		if name == "COUNT" && p.accept("*") {
+			// COUNT(*) counts all records
+		} else {
+			p.inAggregate = true
+			arg, err := p.parseExpr()
+			p.inAggregate = false
+			if err != nil {
+				return nil, err
+			}
+			agg.arg = arg
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		p.aggregates = append(p.aggregates, agg)
+		return agg, nil
+	}
+
+	arity, ok := functionArity[name]
+	if !ok {
+		return nil, &exception.Error{
+			ErrorCode: exception.UnsupportedSqlOperation,
+			Message:   fmt.Sprintf("The function %s is not supported.", t.value),
+		}
+	}
+
+	var args []expr
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			// SUBSTRING(s FROM start FOR length)
+			if name == "SUBSTRING" && (p.accept("FROM") || p.accept("FOR")) {
+				continue
+			}
+
+			if !p.accept(",") {
+				break
+			}
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < arity[0] || len(args) > arity[1] {
+		return nil, &exception.Error{
+			ErrorCode: exception.EvaluatorInvalidArguments,
+			Message:   fmt.Sprintf("Incorrect number of arguments for %s.", t.value),
+		}
+	}
+
+	return &funcExpr{name: name, args: args}, nil
+}
+
+// parseColumnRef parses the remainder of a column reference starting with first.
+func (p *parser) parseColumnRef(first pathElement) (expr, error) {
+	ref := &columnRef{path: []pathElement{first}}
+
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			switch t.kind {
+			case tokenIdent:
+				ref.path = append(ref.path, pathElement{name: t.value, index: -1})
+			case tokenQuotedIdent:
+				ref.path = append(ref.path, pathElement{name: t.value, quoted: true, index: -1})
+			default:
+				return nil, errUnexpectedToken(t)
+			}
+		case p.accept("["):
+			t := p.next()
+			n, err := strconv.Atoi(t.value)
+			if t.kind != tokenNumber || err != nil || n < 0 {
+				return nil, errUnexpectedToken(t)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			ref.path = append(ref.path, pathElement{index: n})
+		default:
+			if !p.inAggregate {
+				p.columns++
+			}
+			return ref, nil
+		}
+	}
+}
+
+// resolveAlias removes the table alias from the start of all column references.
+func (q *Query) resolveAlias() {
+	var visit func(e expr)
+	visit = func(e expr) {
+		switch x := e.(type) {
+		case *columnRef:
+			if len(x.path) > 1 && !x.path[0].quoted && x.path[0].index < 0 &&
+				((q.alias != "" && strings.EqualFold(x.path[0].name, q.alias)) || strings.EqualFold(x.path[0].name, "S3Object")) {
+				x.path = x.path[1:]
+			}
+		case *notExpr:
+			visit(x.e)
+		case *negateExpr:
+			visit(x.e)
+		case *logicalExpr:
+			visit(x.left)
+			visit(x.right)
+		case *comparisonExpr:
+			visit(x.left)
+			visit(x.right)
+		case *arithmeticExpr:
+			visit(x.left)
+			visit(x.right)
+		case *likeExpr:
+			visit(x.e)
+			visit(x.pattern)
+			if x.escape != nil {
+				visit(x.escape)
+			}
+		case *isNullExpr:
+			visit(x.e)
+		case *betweenExpr:
+			visit(x.e)
+			visit(x.low)
+			visit(x.high)
+		case *inExpr:
+			visit(x.e)
+			for _, item := range x.list {
+				visit(item)
+			}
+		case *castExpr:
+			visit(x.e)
+		case *funcExpr:
+			for _, arg := range x.args {
+				visit(arg)
+			}
+		case *aggregateExpr:
+			if x.arg != nil {
+				visit(x.arg)
+			}
+		}
+	}
+
+	for _, proj := range q.projections {
+		visit(proj.e)
+	}
+
+	if q.where != nil {
+		visit(q.where)
+	}
+}
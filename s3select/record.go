@@ -0,0 +1,152 @@
+package s3select
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathElement is a single element of a column reference.
+// It is either a field name, or an index into an array if index is not negative.
+type pathElement struct {
+	name   string
+	quoted bool
+	index  int
+}
+
+// matchName returns true if the path element name matches the given field name.
+// Quoted names are case-sensitive, otherwise names are compared case-insensitively.
+func (p pathElement) matchName(name string) bool {
+	if p.quoted {
+		return p.name == name
+	}
+
+	return strings.EqualFold(p.name, name)
+}
+
+// positionalColumn returns the zero based column index of a positional column reference such as _1.
+func (p pathElement) positionalColumn() (int, bool) {
+	if p.quoted || len(p.name) < 2 || p.name[0] != '_' {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(p.name[1:])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+
+	return n - 1, true
+}
+
+// A Record is a single row of input data.
+type Record interface {
+	// Get returns the value at the given path of the record.
+	// It returns nil if the path does not exist.
+	Get(path []pathElement) Value
+	// Columns returns the name and value of each top level column of the record, in order.
+	Columns() ([]string, []Value)
+}
+
+type csvRecord struct {
+	header []string
+	fields []string
+}
+
+func (r *csvRecord) Get(path []pathElement) Value {
+	if len(path) != 1 {
+		return nil
+	}
+
+	if i, ok := path[0].positionalColumn(); ok {
+		if i < len(r.fields) {
+			return r.fields[i]
+		}
+		return nil
+	}
+
+	for i, name := range r.header {
+		if path[0].matchName(name) && i < len(r.fields) {
+			return r.fields[i]
+		}
+	}
+
+	return nil
+}
+
+func (r *csvRecord) Columns() ([]string, []Value) {
+	var (
+		names  = make([]string, len(r.fields))
+		values = make([]Value, len(r.fields))
+	)
+
+	for i, field := range r.fields {
+		if i < len(r.header) {
+			names[i] = r.header[i]
+		} else {
+			names[i] = "_" + strconv.Itoa(i+1)
+		}
+
+		values[i] = field
+	}
+
+	return names, values
+}
+
+type jsonRecord struct {
+	value map[string]any
+}
+
+func (r *jsonRecord) Get(path []pathElement) Value {
+	var current Value = r.value
+
+	for _, p := range path {
+		switch v := current.(type) {
+		case map[string]any:
+			if p.index >= 0 {
+				return nil
+			}
+
+			next, ok := v[p.name]
+			if !ok && !p.quoted {
+				for k, kv := range v {
+					if p.matchName(k) {
+						next, ok = kv, true
+						break
+					}
+				}
+			}
+
+			if !ok {
+				return nil
+			}
+
+			current = next
+		case []any:
+			if p.index < 0 || p.index >= len(v) {
+				return nil
+			}
+
+			current = v[p.index]
+		default:
+			return nil
+		}
+	}
+
+	return current
+}
+
+func (r *jsonRecord) Columns() ([]string, []Value) {
+	var names = make([]string, 0, len(r.value))
+	for k := range r.value {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	var values = make([]Value, len(names))
+	for i, k := range names {
+		values[i] = r.value[k]
+	}
+
+	return names, values
+}
@@ -0,0 +1,550 @@
+// Package s3select implements a subset of the Amazon S3 Select SQL language over CSV and JSON objects.
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// recordsPayloadSize is the size of the output buffer at which a Records event is sent.
+const recordsPayloadSize = 64 * 1024
+
+type CSVInput struct {
+	FileHeaderInfo       string
+	Comments             string
+	QuoteEscapeCharacter string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+}
+
+type JSONInput struct {
+	Type string
+}
+
+type InputSerialization struct {
+	CompressionType string
+	CSV             *CSVInput
+	JSON            *JSONInput
+}
+
+type CSVOutput struct {
+	QuoteFields          string
+	QuoteEscapeCharacter string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+}
+
+type JSONOutput struct {
+	RecordDelimiter string
+}
+
+type OutputSerialization struct {
+	CSV  *CSVOutput
+	JSON *JSONOutput
+}
+
+// Request is the body of a SelectObjectContent request.
+type Request struct {
+	XMLName             xml.Name `xml:"SelectObjectContentRequest"`
+	Expression          string
+	ExpressionType      string
+	InputSerialization  InputSerialization
+	OutputSerialization OutputSerialization
+}
+
+// Stats are the statistics of a completed select request.
+type Stats struct {
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+func errInvalidParameter(message string) error {
+	return &exception.Error{
+		ErrorCode: exception.InvalidRequestParameter,
+		Message:   message,
+	}
+}
+
+// singleRune returns the single rune of s, or def if s is empty.
+func singleRune(s string, def rune, name string) (rune, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	r, n := utf8.DecodeRuneInString(s)
+	if n != len(s) {
+		return 0, errInvalidParameter(fmt.Sprintf("%s must be a single character.", name))
+	}
+
+	return r, nil
+}
+
+// Validate checks that the request is supported.
+func (req *Request) Validate() error {
+	if req.ExpressionType != "SQL" {
+		return &exception.Error{
+			ErrorCode: exception.InvalidExpressionType,
+			Message:   "The ExpressionType is invalid. Only SQL expressions are supported.",
+		}
+	}
+
+	switch req.InputSerialization.CompressionType {
+	case "", "NONE", "GZIP", "BZIP2":
+	default:
+		return &exception.Error{
+			ErrorCode: exception.InvalidCompressionFormat,
+			Message:   "The file is not in a supported compression format. Only GZIP and BZIP2 are supported.",
+		}
+	}
+
+	if (req.InputSerialization.CSV == nil) == (req.InputSerialization.JSON == nil) {
+		return errInvalidParameter("Exactly one of CSV or JSON must be specified in InputSerialization.")
+	}
+
+	if in := req.InputSerialization.CSV; in != nil {
+		switch strings.ToUpper(in.FileHeaderInfo) {
+		case "", "NONE", "USE", "IGNORE":
+		default:
+			return errInvalidParameter("Invalid FileHeaderInfo.")
+		}
+
+		switch in.RecordDelimiter {
+		case "", "\n", "\r\n":
+		default:
+			return errInvalidParameter("Only newline record delimiters are supported for CSV input.")
+		}
+
+		if in.QuoteCharacter != "" && in.QuoteCharacter != "\"" {
+			return errInvalidParameter("Only the double quote character is supported as a CSV QuoteCharacter.")
+		}
+	}
+
+	if in := req.InputSerialization.JSON; in != nil {
+		switch strings.ToUpper(in.Type) {
+		case "", "DOCUMENT", "LINES":
+		default:
+			return errInvalidParameter("Invalid JSON Type.")
+		}
+	}
+
+	if (req.OutputSerialization.CSV == nil) == (req.OutputSerialization.JSON == nil) {
+		return errInvalidParameter("Exactly one of CSV or JSON must be specified in OutputSerialization.")
+	}
+
+	if out := req.OutputSerialization.CSV; out != nil {
+		switch strings.ToUpper(out.QuoteFields) {
+		case "", "ASNEEDED", "ALWAYS":
+		default:
+			return errInvalidParameter("Invalid QuoteFields.")
+		}
+	}
+
+	return nil
+}
+
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// recordReader returns successive records from the input. It returns io.EOF at the end of the input.
+type recordReader func() (Record, error)
+
+func newCSVRecordReader(r io.Reader, in *CSVInput) (recordReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = false
+
+	var err error
+	cr.Comma, err = singleRune(in.FieldDelimiter, ',', "FieldDelimiter")
+	if err != nil {
+		return nil, err
+	}
+
+	cr.Comment, err = singleRune(in.Comments, 0, "Comments")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		header     []string
+		headerInfo = strings.ToUpper(in.FileHeaderInfo)
+		first      = true
+	)
+
+	return func() (Record, error) {
+		for {
+			fields, err := cr.Read()
+			if err == io.EOF {
+				return nil, err
+			}
+			if err != nil {
+				return nil, &exception.Error{
+					ErrorCode: exception.CSVParsingError,
+					Message:   err.Error(),
+				}
+			}
+
+			if first {
+				first = false
+
+				switch headerInfo {
+				case "USE":
+					header = fields
+					continue
+				case "IGNORE":
+					continue
+				}
+			}
+
+			return &csvRecord{header: header, fields: fields}, nil
+		}
+	}, nil
+}
+
+func newJSONRecordReader(r io.Reader) recordReader {
+	dec := json.NewDecoder(r)
+
+	return func() (Record, error) {
+		var v any
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, &exception.Error{
+				ErrorCode: exception.JSONParsingError,
+				Message:   err.Error(),
+			}
+		}
+
+		obj, ok := v.(map[string]any)
+		if !ok {
+			obj = map[string]any{"_1": v}
+		}
+
+		return &jsonRecord{value: obj}, nil
+	}
+}
+
+// recordWriter writes the output of a single record to a buffer.
+type recordWriter func(b *bytes.Buffer, names []string, values []Value)
+
+func newCSVRecordWriter(out *CSVOutput) (recordWriter, error) {
+	fieldDelimiter, err := singleRune(out.FieldDelimiter, ',', "FieldDelimiter")
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := singleRune(out.QuoteCharacter, '"', "QuoteCharacter")
+	if err != nil {
+		return nil, err
+	}
+
+	quoteEscape, err := singleRune(out.QuoteEscapeCharacter, quote, "QuoteEscapeCharacter")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		recordDelimiter = out.RecordDelimiter
+		alwaysQuote     = strings.ToUpper(out.QuoteFields) == "ALWAYS"
+	)
+
+	if recordDelimiter == "" {
+		recordDelimiter = "\n"
+	}
+
+	return func(b *bytes.Buffer, _ []string, values []Value) {
+		for i, v := range values {
+			if i > 0 {
+				b.WriteRune(fieldDelimiter)
+			}
+
+			s := toString(v)
+			if !alwaysQuote && !strings.ContainsRune(s, fieldDelimiter) && !strings.ContainsRune(s, quote) &&
+				!strings.ContainsAny(s, "\r\n") {
+				b.WriteString(s)
+				continue
+			}
+
+			b.WriteRune(quote)
+			for _, r := range s {
+				if r == quote {
+					b.WriteRune(quoteEscape)
+				}
+				b.WriteRune(r)
+			}
+			b.WriteRune(quote)
+		}
+
+		b.WriteString(recordDelimiter)
+	}, nil
+}
+
+func newJSONRecordWriter(out *JSONOutput) recordWriter {
+	var recordDelimiter = out.RecordDelimiter
+	if recordDelimiter == "" {
+		recordDelimiter = "\n"
+	}
+
+	return func(b *bytes.Buffer, names []string, values []Value) {
+		// Keys are written in projection order
+		b.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			k, _ := json.Marshal(name)
+			v, err := json.Marshal(values[i])
+			if err != nil {
+				v = []byte("null")
+			}
+
+			b.Write(k)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+		b.WriteString(recordDelimiter)
+	}
+}
+
+// decompress wraps r with a decompressing reader for the given compression type.
+func decompress(r io.Reader, compressionType string) (io.Reader, error) {
+	switch compressionType {
+	case "GZIP":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, &exception.Error{
+				ErrorCode: exception.InvalidCompressionFormat,
+				Message:   "The object is not GZIP compressed.",
+			}
+		}
+		return gz, nil
+	case "BZIP2":
+		return bzip2.NewReader(r), nil
+	default:
+		return r, nil
+	}
+}
+
+// project evaluates the projections of the query for the given record,
+// returning the names and values of each output column.
+func (q *Query) project(rec Record) ([]string, []Value, error) {
+	if q.projections == nil {
+		names, values := rec.Columns()
+		return names, values, nil
+	}
+
+	var (
+		names  = make([]string, len(q.projections))
+		values = make([]Value, len(q.projections))
+	)
+
+	for i, proj := range q.projections {
+		v, err := proj.e.eval(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		values[i] = v
+
+		switch {
+		case proj.name != "":
+			names[i] = proj.name
+		default:
+			if ref, ok := proj.e.(*columnRef); ok && ref.name() != "" {
+				names[i] = ref.name()
+			} else {
+				names[i] = fmt.Sprintf("_%d", i+1)
+			}
+		}
+	}
+
+	return names, values, nil
+}
+
+// matches returns true if the record satisfies the WHERE clause of the query.
+func (q *Query) matches(rec Record) (bool, error) {
+	if q.where == nil {
+		return true, nil
+	}
+
+	v, err := q.where.eval(rec)
+	if err != nil || v == nil {
+		return false, err
+	}
+
+	b, ok := toBool(v)
+	if !ok {
+		return false, errInvalidArguments("The WHERE clause must evaluate to a boolean.")
+	}
+
+	return b, nil
+}
+
+// Run executes the query in the request over the object data in r,
+// writing the results to w as an event stream.
+// An error is returned without writing to w if the request is invalid,
+// errors that occur after results have been written are sent as an error event.
+func Run(req *Request, r io.Reader, w io.Writer) error {
+	err := req.Validate()
+	if err != nil {
+		return err
+	}
+
+	q, err := ParseQuery(req.Expression)
+	if err != nil {
+		return err
+	}
+
+	var write recordWriter
+	if req.OutputSerialization.CSV != nil {
+		write, err = newCSVRecordWriter(req.OutputSerialization.CSV)
+		if err != nil {
+			return err
+		}
+	} else {
+		write = newJSONRecordWriter(req.OutputSerialization.JSON)
+	}
+
+	var (
+		scanned = &countingReader{r: bufio.NewReader(r)}
+		stats   Stats
+	)
+
+	decompressed, err := decompress(scanned, req.InputSerialization.CompressionType)
+	if err != nil {
+		return err
+	}
+
+	var processed = &countingReader{r: decompressed}
+
+	var read recordReader
+	if req.InputSerialization.CSV != nil {
+		read, err = newCSVRecordReader(processed, req.InputSerialization.CSV)
+		if err != nil {
+			return err
+		}
+	} else {
+		read = newJSONRecordReader(processed)
+	}
+
+	var (
+		events = NewEventStreamWriter(w)
+		buf    bytes.Buffer
+		n      int64
+	)
+
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+
+		stats.BytesReturned += int64(buf.Len())
+		err := events.Records(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	runErr := func() error {
+		for len(q.aggregates) > 0 || q.limit < 0 || n < q.limit {
+			rec, err := read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			ok, err := q.matches(rec)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			n++
+
+			if len(q.aggregates) > 0 {
+				for _, agg := range q.aggregates {
+					if err := agg.accumulate(rec); err != nil {
+						return err
+					}
+				}
+
+				continue
+			}
+
+			names, values, err := q.project(rec)
+			if err != nil {
+				return err
+			}
+
+			write(&buf, names, values)
+
+			if buf.Len() >= recordsPayloadSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		// An aggregate query always returns a single record
+		if len(q.aggregates) > 0 && q.limit != 0 {
+			names, values, err := q.project(nil)
+			if err != nil {
+				return err
+			}
+
+			write(&buf, names, values)
+		}
+
+		return flush()
+	}()
+
+	if runErr != nil {
+		var known *exception.Error
+		if !errors.As(runErr, &known) {
+			known = &exception.Error{
+				ErrorCode: exception.InternalError,
+				Message:   runErr.Error(),
+			}
+		}
+
+		return events.Error(known.Code, known.Message)
+	}
+
+	stats.BytesScanned = scanned.n
+	stats.BytesProcessed = processed.n
+
+	if err := events.Stats(&stats); err != nil {
+		return err
+	}
+
+	return events.End()
+}
@@ -0,0 +1,184 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+type testEvent struct {
+	headers map[string]string
+	payload []byte
+}
+
+// readEvents decodes all messages of an event stream.
+func readEvents(t *testing.T, b []byte) []testEvent {
+	var events []testEvent
+
+	for len(b) > 0 {
+		if !assert.GreaterOrEqual(t, len(b), 16) {
+			return events
+		}
+
+		totalLength := int(binary.BigEndian.Uint32(b[0:4]))
+		headersLength := int(binary.BigEndian.Uint32(b[4:8]))
+
+		assert.Equal(t, crc32.ChecksumIEEE(b[0:8]), binary.BigEndian.Uint32(b[8:12]), "prelude crc")
+		assert.Equal(t, crc32.ChecksumIEEE(b[0:totalLength-4]), binary.BigEndian.Uint32(b[totalLength-4:totalLength]), "message crc")
+
+		var (
+			event = testEvent{headers: make(map[string]string)}
+			hdr   = b[12 : 12+headersLength]
+		)
+
+		for len(hdr) > 0 {
+			nameLength := int(hdr[0])
+			name := string(hdr[1 : 1+nameLength])
+			assert.Equal(t, byte(eventHeaderTypeString), hdr[1+nameLength])
+			valueLength := int(binary.BigEndian.Uint16(hdr[2+nameLength : 4+nameLength]))
+			event.headers[name] = string(hdr[4+nameLength : 4+nameLength+valueLength])
+			hdr = hdr[4+nameLength+valueLength:]
+		}
+
+		event.payload = b[12+headersLength : totalLength-4]
+		events = append(events, event)
+		b = b[totalLength:]
+	}
+
+	return events
+}
+
+// runRecords runs the request and returns the concatenated payload of all Records events.
+func runRecords(t *testing.T, req *Request, input string) string {
+	var out bytes.Buffer
+	err := Run(req, strings.NewReader(input), &out)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	var (
+		records strings.Builder
+		events  = readEvents(t, out.Bytes())
+	)
+
+	if !assert.GreaterOrEqual(t, len(events), 2) {
+		return ""
+	}
+
+	for _, event := range events[:len(events)-2] {
+		assert.Equal(t, "Records", event.headers[":event-type"])
+		records.Write(event.payload)
+	}
+
+	assert.Equal(t, "Stats", events[len(events)-2].headers[":event-type"])
+	assert.Equal(t, "End", events[len(events)-1].headers[":event-type"])
+
+	return records.String()
+}
+
+const testCSV = `name,age,city
+Alice,34,London
+Bob,27,Paris
+Carol,45,London
+Dave,,Berlin
+`
+
+func csvRequest(expression string) *Request {
+	return &Request{
+		Expression:     expression,
+		ExpressionType: "SQL",
+		InputSerialization: InputSerialization{
+			CSV: &CSVInput{FileHeaderInfo: "USE"},
+		},
+		OutputSerialization: OutputSerialization{
+			CSV: &CSVOutput{},
+		},
+	}
+}
+
+func TestRun_CSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expect     string
+	}{
+		{"star", "SELECT * FROM S3Object", "Alice,34,London\nBob,27,Paris\nCarol,45,London\nDave,,Berlin\n"},
+		{"projection", "SELECT s.name FROM S3Object s WHERE s.city = 'London'", "Alice\nCarol\n"},
+		{"positional", "SELECT _1, _3 FROM S3Object LIMIT 1", "Alice,London\n"},
+		{"cast", "SELECT name FROM S3Object WHERE age <> '' AND CAST(age AS INT) > 30", "Alice\nCarol\n"},
+		{"like", "SELECT name FROM S3Object WHERE name LIKE '_a%'", "Carol\nDave\n"},
+		{"in", "SELECT name FROM S3Object WHERE city IN ('Paris', 'Berlin')", "Bob\nDave\n"},
+		{"between", "SELECT name FROM S3Object WHERE age <> '' AND CAST(age AS INT) BETWEEN 27 AND 34", "Alice\nBob\n"},
+		{"functions", "SELECT UPPER(name), SUBSTRING(city FROM 1 FOR 3) FROM S3Object LIMIT 2", "ALICE,Lon\nBOB,Par\n"},
+		{"count", "SELECT COUNT(*) FROM S3Object", "4\n"},
+		{"aggregates", "SELECT MIN(CAST(age AS INT)), MAX(CAST(age AS INT)), AVG(CAST(age AS INT)) FROM S3Object WHERE age <> ''", "27,45,35.333333333333336\n"},
+		{"limit_zero", "SELECT * FROM S3Object LIMIT 0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, runRecords(t, csvRequest(tt.expression), testCSV))
+		})
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	req := &Request{
+		Expression:     "SELECT s.name, s.address.city AS city FROM S3Object[*] s WHERE s.tags[0] = 'a'",
+		ExpressionType: "SQL",
+		InputSerialization: InputSerialization{
+			JSON: &JSONInput{Type: "LINES"},
+		},
+		OutputSerialization: OutputSerialization{
+			JSON: &JSONOutput{},
+		},
+	}
+
+	input := `{"name": "Alice", "address": {"city": "London"}, "tags": ["a", "b"]}
+{"name": "Bob", "address": {"city": "Paris"}, "tags": ["b"]}
+`
+
+	assert.Equal(t, "{\"name\":\"Alice\",\"city\":\"London\"}\n", runRecords(t, req, input))
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *Request
+		expect exception.ErrorCode
+	}{
+		{"expression_type", &Request{ExpressionType: "XPATH"}, exception.InvalidExpressionType},
+		{"syntax", csvRequest("SELECT FROM S3Object"), exception.ParseUnexpectedToken},
+		{"mixed_aggregate", csvRequest("SELECT name, COUNT(*) FROM S3Object"), exception.UnsupportedSqlOperation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Run(tt.req, strings.NewReader(testCSV), &out)
+
+			var e *exception.Error
+			if assert.True(t, errors.As(err, &e)) {
+				assert.Equal(t, tt.expect.Code, e.Code)
+			}
+			assert.Zero(t, out.Len())
+		})
+	}
+
+	t.Run("runtime", func(t *testing.T) {
+		var out bytes.Buffer
+		err := Run(csvRequest("SELECT name FROM S3Object WHERE name / 2 = 1"), strings.NewReader(testCSV), &out)
+		assert.NoError(t, err)
+
+		events := readEvents(t, out.Bytes())
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "error", events[0].headers[":message-type"])
+			assert.Equal(t, exception.EvaluatorInvalidArguments.Code, events[0].headers[":error-code"])
+		}
+	})
+}
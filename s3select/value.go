@@ -0,0 +1,159 @@
+package s3select
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Value is the result of evaluating an expression.
+// It is one of nil (NULL or MISSING), bool, float64, string, []any or map[string]any.
+type Value = any
+
+// toNumber converts the value into a number.
+// Strings are parsed as numbers, which allows comparisons between CSV fields and numeric literals.
+func toNumber(v Value) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toBool converts the value into a boolean.
+func toBool(v Value) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+// toString converts a scalar value into its string representation.
+func toString(v Value) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return formatNumber(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// formatNumber formats a number without an exponent or trailing zeros where possible.
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// compareValues compares a and b, returning -1, 0 or 1.
+// Returns false if the values cannot be compared.
+// If either value is a number then both values are compared as numbers.
+func compareValues(a, b Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	_, aIsNumber := a.(float64)
+	_, bIsNumber := b.(float64)
+
+	if aIsNumber || bIsNumber {
+		x, okA := toNumber(a)
+		y, okB := toNumber(b)
+		if !okA || !okB {
+			return 0, false
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	_, aIsBool := a.(bool)
+	_, bIsBool := b.(bool)
+
+	if aIsBool || bIsBool {
+		x, okA := toBool(a)
+		y, okB := toBool(b)
+		if !okA || !okB {
+			return 0, false
+		}
+
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+// likeMatch returns true if s matches the SQL LIKE pattern,
+// where % matches any sequence of characters and _ matches any single character.
+// A character following escape is matched literally.
+func likeMatch(pattern, s []rune, escape rune) bool {
+	for len(pattern) > 0 {
+		switch p := pattern[0]; {
+		case escape != 0 && p == escape && len(pattern) > 1:
+			if len(s) == 0 || s[0] != pattern[1] {
+				return false
+			}
+			pattern, s = pattern[2:], s[1:]
+		case p == '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if likeMatch(pattern, s[i:], escape) {
+					return true
+				}
+			}
+			return false
+		case p == '_':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		default:
+			if len(s) == 0 || s[0] != p {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+
+	return len(s) == 0
+}
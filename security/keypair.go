@@ -0,0 +1,53 @@
+package security
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// LoadKeyPair loads a TLS certificate and private key from a pair of PEM encoded files.
+func LoadKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	kp := &KeyPair{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err := kp.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return kp, nil
+}
+
+// KeyPair is a TLS certificate and private key that can be reloaded from its files while it is in use.
+type KeyPair struct {
+	certFile string
+	keyFile  string
+
+	mx   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// Reload reads the certificate and private key from their files again.
+// If either cannot be loaded then the current certificate continues to be used.
+func (kp *KeyPair) Reload() error {
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		return err
+	}
+
+	kp.mx.Lock()
+	kp.cert = &cert
+	kp.mx.Unlock()
+
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (kp *KeyPair) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	kp.mx.RLock()
+	defer kp.mx.RUnlock()
+
+	return kp.cert, nil
+}
@@ -0,0 +1,158 @@
+package security
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultLockoutSize is the default maximum number of keys tracked by a Lockout.
+const DefaultLockoutSize = 100000
+
+// NewLockout creates a new Lockout that locks out a key for duration once it has failed threshold times.
+// One failure of a key is forgotten every decay.
+func NewLockout(threshold int, decay time.Duration, duration time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		decay:     decay,
+		duration:  duration,
+		size:      DefaultLockoutSize,
+		entries:   make(map[string]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+type lockoutEntry struct {
+	key      string
+	failures int
+	// updated is the time that failures was last decayed.
+	updated time.Time
+	// lockedUntil is the time that the key is locked out until.
+	lockedUntil time.Time
+}
+
+// decay forgets the failures of the entry that have decayed by now.
+func (e *lockoutEntry) decay(decay time.Duration, now time.Time) {
+	if decay <= 0 {
+		return
+	}
+
+	forgotten := int(now.Sub(e.updated) / decay)
+	if forgotten >= e.failures {
+		e.failures = 0
+		e.updated = now
+		return
+	}
+
+	e.failures -= forgotten
+	e.updated = e.updated.Add(time.Duration(forgotten) * decay)
+}
+
+// Lockout temporarily locks out keys, such as client IPs or access key ids, with too many recent failures.
+// If the maximum number of keys are tracked then the key that least recently failed is forgotten to track a new key,
+// unless that key is still locked out.
+type Lockout struct {
+	threshold int
+	decay     time.Duration
+	duration  time.Duration
+	size      int
+
+	mx      sync.Mutex
+	entries map[string]*list.Element
+	// recent orders entries from the most to the least recently failed.
+	recent *list.List
+}
+
+// evict forgets the key that least recently failed if it is not locked out,
+// and returns true if there is then room to track a new key.
+func (l *Lockout) evict(now time.Time) bool {
+	if len(l.entries) < l.size {
+		return true
+	}
+
+	oldest := l.recent.Back()
+	if oldest == nil {
+		return false
+	}
+
+	e := oldest.Value.(*lockoutEntry)
+	if e.lockedUntil.After(now) {
+		return false
+	}
+
+	l.recent.Remove(oldest)
+	delete(l.entries, e.key)
+
+	return true
+}
+
+// Locked returns true and the remaining time of the lock out if key is locked out.
+func (l *Lockout) Locked(key string, now time.Time) (time.Duration, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return 0, false
+	}
+
+	e := elem.Value.(*lockoutEntry)
+	if !e.lockedUntil.After(now) {
+		return 0, false
+	}
+
+	return e.lockedUntil.Sub(now), true
+}
+
+// Fail records a failure of key, and returns true if the failure caused key to be locked out.
+func (l *Lockout) Fail(key string, now time.Time) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		if !l.evict(now) {
+			return false
+		}
+
+		elem = l.recent.PushFront(&lockoutEntry{key: key, updated: now})
+		l.entries[key] = elem
+	}
+
+	e := elem.Value.(*lockoutEntry)
+
+	// Failures while locked out do not extend the lock out
+	if e.lockedUntil.After(now) {
+		return false
+	}
+
+	l.recent.MoveToFront(elem)
+
+	e.decay(l.decay, now)
+	e.failures++
+
+	if e.failures < l.threshold {
+		return false
+	}
+
+	e.failures = 0
+	e.updated = now
+	e.lockedUntil = now.Add(l.duration)
+
+	return true
+}
+
+// Count returns the number of keys that are locked out.
+func (l *Lockout) Count(now time.Time) int {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	var n int
+	for _, elem := range l.entries {
+		if elem.Value.(*lockoutEntry).lockedUntil.After(now) {
+			n++
+		}
+	}
+
+	return n
+}
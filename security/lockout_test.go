@@ -0,0 +1,63 @@
+package security
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	var (
+		lockout = NewLockout(3, time.Minute, 10*time.Minute)
+		now     = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	assert.False(t, lockout.Fail("key", now))
+	assert.False(t, lockout.Fail("key", now))
+
+	// One failure is forgotten after the decay
+	assert.False(t, lockout.Fail("key", now.Add(time.Minute)))
+	_, locked := lockout.Locked("key", now.Add(time.Minute))
+	assert.False(t, locked)
+
+	assert.True(t, lockout.Fail("key", now.Add(time.Minute)))
+	remaining, locked := lockout.Locked("key", now.Add(2*time.Minute))
+	assert.True(t, locked)
+	assert.Equal(t, 9*time.Minute, remaining)
+	assert.Equal(t, 1, lockout.Count(now.Add(2*time.Minute)))
+
+	_, locked = lockout.Locked("other", now.Add(2*time.Minute))
+	assert.False(t, locked)
+
+	// The lock out ends after its duration
+	_, locked = lockout.Locked("key", now.Add(11*time.Minute))
+	assert.False(t, locked)
+	assert.Equal(t, 0, lockout.Count(now.Add(11*time.Minute)))
+}
+
+func TestLockout_Size(t *testing.T) {
+	var (
+		lockout = NewLockout(2, time.Minute, 10*time.Minute)
+		now     = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	lockout.size = 2
+
+	assert.False(t, lockout.Fail("a", now))
+	assert.False(t, lockout.Fail("b", now))
+	assert.True(t, lockout.Fail("a", now))
+
+	// The least recently failed key that is not locked out is forgotten
+	assert.False(t, lockout.Fail("c", now))
+	assert.False(t, lockout.Fail("b", now))
+
+	// Failures of new keys are not tracked while the least recently failed key is locked out
+	assert.False(t, lockout.Fail("a", now))
+	assert.False(t, lockout.Fail("d", now))
+	assert.False(t, lockout.Fail("d", now))
+	_, locked := lockout.Locked("d", now)
+	assert.False(t, locked)
+
+	_, locked = lockout.Locked("a", now)
+	assert.True(t, locked)
+}
@@ -1,6 +1,9 @@
 package security
 
-import "net/http"
+import (
+	"crypto/x509"
+	"net/http"
+)
 
 // ClientTLS returns true if the given request is being served over secure transport.
 type ClientTLS func(r *http.Request) bool
@@ -15,3 +18,13 @@ func DirectClientTLS(r *http.Request) bool {
 func ForwardedClientTLS(r *http.Request) bool {
 	return r.Header.Get("X-Forwarded-Proto") == "https"
 }
+
+// VerifiedClientCertificate returns the client certificate of the HTTP request,
+// if the TLS connection verified it against the trusted client certificate authorities.
+func VerifiedClientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	return r.TLS.VerifiedChains[0][0], true
+}
@@ -1,6 +1,7 @@
 package ls3
 
 import (
+	"errors"
 	"github.com/google/uuid"
 	"github.com/relvacode/ls3/exception"
 	"github.com/relvacode/ls3/idp"
@@ -21,6 +22,23 @@ type ServerOptions struct {
 	GlobalPolicy []*idp.PolicyStatement
 	ClientIP     security.ClientIP
 	ClientTLS    security.ClientTLS
+	// ClientLockout temporarily blocks client IPs with too many failed signature verifications.
+	// If nil, failures are not tracked by client IP.
+	ClientLockout *security.Lockout
+	// KeyLockout temporarily blocks access key ids with too many failed signature verifications.
+	// If nil, failures are not tracked by access key id.
+	KeyLockout *security.Lockout
+	// WebsiteDomain serves each bucket with a website configuration as a static website,
+	// using host style addressing on this domain.
+	WebsiteDomain string
+	// ManageBuckets allows buckets to be created and deleted, if the Filesystem is a ManagedBucketFilesystemProvider.
+	ManageBuckets bool
+	// Region is the region of the server, and of each bucket without a region of its own.
+	// If empty, DefaultRegion is used.
+	Region string
+	// ETagCache caches computed object ETags.
+	// If nil, a new in-memory cache of DefaultETagCacheSize is used.
+	ETagCache *ETagCache
 }
 
 func NewServer(opts *ServerOptions) *Server {
@@ -28,15 +46,39 @@ func NewServer(opts *ServerOptions) *Server {
 	if len(opts.Domain) > 0 {
 		domainComponents = strings.Split(opts.Domain, ".")
 	}
+	var websiteDomainComponents []string
+	if len(opts.WebsiteDomain) > 0 {
+		websiteDomainComponents = strings.Split(opts.WebsiteDomain, ".")
+	}
+	etags := opts.ETagCache
+	if etags == nil {
+		etags = NewETagCache(DefaultETagCacheSize)
+	}
+	region := opts.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	if opts.ClientLockout != nil {
+		statLockedOut.track(lockoutKindClientIP, opts.ClientLockout)
+	}
+	if opts.KeyLockout != nil {
+		statLockedOut.track(lockoutKindAccessKeyId, opts.KeyLockout)
+	}
 	return &Server{
 		log:                opts.Log,
 		signer:             opts.Signer,
 		identities:         opts.Identity,
 		filesystemProvider: opts.Filesystem,
 		domain:             domainComponents,
+		websiteDomain:      websiteDomainComponents,
 		globalPolicy:       opts.GlobalPolicy,
 		remoteIP:           opts.ClientIP,
 		remoteTLS:          opts.ClientTLS,
+		clientLockout:      opts.ClientLockout,
+		keyLockout:         opts.KeyLockout,
+		manageBuckets:      opts.ManageBuckets,
+		region:             region,
+		etags:              etags,
 		uidGen:             uuid.New,
 	}
 }
@@ -47,24 +89,74 @@ type Server struct {
 	identities         idp.Provider
 	filesystemProvider BucketFilesystemProvider
 	domain             []string
+	websiteDomain      []string
 	globalPolicy       []*idp.PolicyStatement
 	remoteIP           security.ClientIP
 	remoteTLS          security.ClientTLS
+	clientLockout      *security.Lockout
+	keyLockout         *security.Lockout
+	manageBuckets      bool
+	region             string
+	etags              *ETagCache
 	// uidGen describes the function that generates request UUID
 	uidGen func() uuid.UUID
 }
 
+// isSecurityTokenServiceRequest returns true if the request is for the security token service.
+func isSecurityTokenServiceRequest(ctx *RequestContext) bool {
+	return ctx.Bucket == "" && ctx.Request.Method == http.MethodPost && ctx.Request.URL.Path == "/"
+}
+
+// isCreateBucketRequest returns true if the request is for CreateBucket.
+func isCreateBucketRequest(r *http.Request) bool {
+	return r.Method == http.MethodPut && r.URL.Path == "/" && r.URL.RawQuery == ""
+}
+
+// objectQueryParameters are the query parameters that can be sent with a request for an object
+// without making it a request for a sub-resource of the object, such as ?tagging.
+var objectQueryParameters = map[string]bool{
+	"versionId": true,
+	"x-id":      true,
+	// Signature Version 2
+	amzAccessKeyIdV2: true,
+	amzSignatureV2:   true,
+	amzExpiresV2:     true,
+}
+
+// isObjectRequest returns true if the request is for an object itself, and not for a sub-resource of the object.
+func isObjectRequest(r *http.Request) bool {
+	for k := range r.URL.Query() {
+		// Signature Version 4 and its pre-signed parameters
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-") {
+			continue
+		}
+
+		if !objectQueryParameters[k] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (s *Server) getMethodForRequestContext(ctx *RequestContext) (Method, bool) {
 	// Non-bucket methods
 	if ctx.Bucket == "" {
 		switch {
 		case ctx.Request.Method == http.MethodGet && ctx.Request.URL.Path == "/":
 			return s.ListBuckets, true
+		case isSecurityTokenServiceRequest(ctx):
+			return s.SecurityTokenService, true
 		default:
 			return nil, false
 		}
 	}
 
+	// A bucket is created before it exists
+	if isCreateBucketRequest(ctx.Request) {
+		return s.CreateBucket, true
+	}
+
 	if ctx.Filesystem == nil {
 		return nil, false
 	}
@@ -83,6 +175,42 @@ func (s *Server) getMethodForRequestContext(ctx *RequestContext) (Method, bool)
 			return s.GetBucketLocation, true
 		}
 
+		if _, ok := ctx.Request.URL.Query()["versioning"]; ok && ctx.Request.URL.Path == "/" {
+			return s.GetBucketVersioning, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["policy"]; ok && ctx.Request.URL.Path == "/" {
+			return s.GetBucketPolicy, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["cors"]; ok && ctx.Request.URL.Path == "/" {
+			return s.GetBucketCors, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["website"]; ok && ctx.Request.URL.Path == "/" {
+			return s.GetBucketWebsite, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["versions"]; ok && ctx.Request.URL.Path == "/" {
+			return s.ListObjectVersions, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["attributes"]; ok && ctx.Request.URL.Path != "/" {
+			return s.GetObjectAttributes, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["tagging"]; ok && ctx.Request.URL.Path != "/" {
+			return s.GetObjectTagging, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["uploads"]; ok && ctx.Request.URL.Path == "/" {
+			return s.ListMultipartUploads, true
+		}
+
+		if _, ok := ctx.Request.URL.Query()["uploadId"]; ok && ctx.Request.URL.Path != "/" {
+			return s.ListParts, true
+		}
+
 		if ctx.Request.URL.Path == "/" {
 			switch ctx.Request.URL.Query().Get("list-type") {
 			case "2":
@@ -93,11 +221,109 @@ func (s *Server) getMethodForRequestContext(ctx *RequestContext) (Method, bool)
 		}
 
 		return s.GetObject, true
+
+	case http.MethodPut:
+		if ctx.Request.URL.Path == "/" {
+			if _, ok := ctx.Request.URL.Query()["policy"]; ok {
+				return s.PutBucketPolicy, true
+			}
+
+			break
+		}
+
+		_, isCopy := ctx.Request.Header["X-Amz-Copy-Source"]
+
+		if _, ok := ctx.Request.URL.Query()["uploadId"]; ok {
+			if isCopy {
+				return s.UploadPartCopy, true
+			}
+
+			return s.UploadPart, true
+		}
+
+		// Sub-resources of an object, such as ?tagging, must never replace the object
+		if !isObjectRequest(ctx.Request) {
+			break
+		}
+
+		if isCopy {
+			return s.CopyObject, true
+		}
+
+		return s.PutObject, true
+
+	case http.MethodDelete:
+		if ctx.Request.URL.Path == "/" {
+			if _, ok := ctx.Request.URL.Query()["policy"]; ok {
+				return s.DeleteBucketPolicy, true
+			}
+
+			if ctx.Request.URL.RawQuery == "" {
+				return s.DeleteBucket, true
+			}
+
+			break
+		}
+
+		if _, ok := ctx.Request.URL.Query()["uploadId"]; ok {
+			return s.AbortMultipartUpload, true
+		}
+
+		// Sub-resources of an object, such as ?tagging, must never delete the object
+		if !isObjectRequest(ctx.Request) {
+			break
+		}
+
+		return s.DeleteObject, true
+
+	case http.MethodPost:
+		var query = ctx.Request.URL.Query()
+
+		if ctx.Request.URL.Path == "/" && isPostObjectRequest(ctx.Request) {
+			return s.PostObject, true
+		}
+
+		if _, ok := query["delete"]; ok && ctx.Request.URL.Path == "/" {
+			return s.DeleteObjects, true
+		}
+
+		if _, ok := query["uploads"]; ok && ctx.Request.URL.Path != "/" {
+			return s.CreateMultipartUpload, true
+		}
+
+		if _, ok := query["uploadId"]; ok && ctx.Request.URL.Path != "/" {
+			return s.CompleteMultipartUpload, true
+		}
+
+		if _, ok := query["select"]; ok && query.Get("select-type") == "2" && ctx.Request.URL.Path != "/" {
+			return s.SelectObjectContent, true
+		}
 	}
 
 	return nil, false
 }
 
+// certificateIdentity returns the identity of the verified client certificate of the request.
+// It returns nil if the request has no verified client certificate, or the certificate has no identity.
+func (s *Server) certificateIdentity(r *http.Request) (*idp.Identity, error) {
+	cert, ok := security.VerifiedClientCertificate(r)
+	if !ok {
+		return nil, nil
+	}
+
+	for _, keyId := range idp.CertificateKeyIds(cert) {
+		identity, err := s.identities.Get(keyId)
+		if err == nil {
+			return identity, nil
+		}
+		if !errors.Is(err, idp.ErrMissingAccessKeyId) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
 func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	var (
 		requestId        = s.uidGen()
@@ -125,16 +351,50 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		Secure:       clientTLSEnabled,
 		Identity:     idp.PreAuthenticationIdentity,
 		globalPolicy: s.globalPolicy,
+		etags:        s.etags,
+		bucketPolicy: s.readBucketPolicy,
 		rw:           rw,
 	}
 
+	// Website requests are always anonymous
+	if isDomainRequest(r, s.websiteDomain) {
+		s.serveWebsite(ctx)
+		return
+	}
+
+	// CORS preflight requests are never signed
+	if r.Method == http.MethodOptions {
+		if err := s.OptionsObject(ctx); err != nil {
+			ctx.SendKnownError(err)
+		}
+		return
+	}
+
+	// Requests from a locked out client IP or access key id are rejected before they are verified
+	accessKeyId := requestAccessKeyId(r)
+	if err := s.checkLockout(ctx, accessKeyId); err != nil {
+		ctx.SendKnownError(err)
+		return
+	}
+
 	// Verify the request
 	signatureIdentity, err := s.signer.Verify(r, s.identities)
 	if err != nil {
-		ctx.SendKnownError(exception.ErrorFrom(err))
+		verifyErr := exception.ErrorFrom(err)
+		s.recordAuthenticationFailure(ctx, accessKeyId, verifyErr)
+		ctx.SendKnownError(verifyErr)
 		return
 	}
 
+	// Requests that are not signed may be authenticated by a verified client certificate
+	if signatureIdentity == nil {
+		signatureIdentity, err = s.certificateIdentity(r)
+		if err != nil {
+			ctx.SendKnownError(exception.ErrorFrom(err))
+			return
+		}
+	}
+
 	// Identity not present in the request.
 	// Ask the identity provider to provide for IdentityUnauthenticatedPublic
 	if signatureIdentity == nil {
@@ -159,10 +419,40 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	if ok {
 		ctx.Filesystem, err = s.filesystemProvider.Open(ctx.Bucket)
-		if err != nil {
+
+		// A request to create a bucket is expected to be for a bucket that does not exist
+		if err != nil && !isCreateBucketRequest(r) {
 			ctx.SendKnownError(exception.ErrorFrom(err))
 			return
 		}
+
+		// Responses to cross-origin requests include the headers of the matching CORS rule of the bucket
+		if origin := r.Header.Get("Origin"); origin != "" && ctx.Filesystem != nil {
+			if config, err := readBucketCors(ctx.Filesystem); err != nil {
+				ctx.Warn("Unable to read the CORS configuration of the bucket", zap.Error(err))
+			} else if rule := config.Match(origin, r.Method, nil); rule != nil {
+				setCorsHeaders(rw.Header(), rule, origin)
+			}
+		}
+	}
+
+	// Signed requests must be signed for the service of the request, and the region of the bucket
+	if err := s.checkRequestScope(ctx); err != nil {
+		ctx.SendKnownError(err)
+		return
+	}
+
+	if ok {
+		// Requests for a specific version of an object use the filesystem of that version
+		if versionId := r.URL.Query().Get("versionId"); versionId != "" && r.URL.Path != "/" {
+			ctx.Filesystem, err = openBucketVersion(s.filesystemProvider, ctx.Bucket, ctx.Filesystem, versionId)
+			if err != nil {
+				ctx.SendKnownError(exception.ErrorFrom(err))
+				return
+			}
+
+			ctx.VersionId = versionId
+		}
 	}
 
 	requestMethod, ok := s.getMethodForRequestContext(ctx)
@@ -176,6 +466,8 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	requestErr := requestMethod(ctx)
 	if requestErr != nil {
+		// Signatures are also verified by some methods, such as the policy of a POST Object request
+		s.recordAuthenticationFailure(ctx, accessKeyId, requestErr)
 		ctx.SendKnownError(requestErr)
 		return
 	}
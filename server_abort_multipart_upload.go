@@ -0,0 +1,36 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) AbortMultipartUpload(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.AbortMultipartUpload, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	upload, err := readMultipartUpload(wfs, ctx.Request.URL.Query().Get("uploadId"), key)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	err = removeMultipartUpload(wfs, upload.UploadId)
+	if err != nil {
+		return unwrapFsError(err)
+	}
+
+	ctx.SendPlain(http.StatusNoContent)
+	return nil
+}
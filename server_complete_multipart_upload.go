@@ -0,0 +1,157 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"go.uber.org/zap"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxCompleteMultipartUploadRequestSize is the maximum size of a CompleteMultipartUpload request body.
+const maxCompleteMultipartUploadRequestSize = 2 * 1024 * 1024
+
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type CompleteMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Part    []CompletedPart
+}
+
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+func (s *Server) CompleteMultipartUpload(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	var req CompleteMultipartUpload
+	if err := ctx.ReadXML(&req, maxCompleteMultipartUploadRequestSize); err != nil {
+		return err
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	upload, err := readMultipartUpload(wfs, ctx.Request.URL.Query().Get("uploadId"), key)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if len(req.Part) == 0 {
+		return &exception.Error{
+			ErrorCode: exception.MalformedXML,
+			Message:   "You must specify at least one part.",
+		}
+	}
+
+	var (
+		dir, _ = multipartUploadPath(upload.UploadId)
+		names  = make([]string, 0, len(req.Part))
+		etags  = make([]string, 0, len(req.Part))
+	)
+
+	for i := 1; i < len(req.Part); i++ {
+		if req.Part[i].PartNumber <= req.Part[i-1].PartNumber {
+			return &exception.Error{
+				ErrorCode: exception.InvalidPartOrder,
+				Message:   "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
+			}
+		}
+	}
+
+	for i, part := range req.Part {
+		// Clients may send the ETag with or without quotes
+		etag := strings.Trim(part.ETag, `"`)
+		if !isPartETag(etag) {
+			return &exception.Error{
+				ErrorCode: exception.InvalidPart,
+				Message:   "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.",
+			}
+		}
+
+		name := path.Join(dir, multipartPartName(part.PartNumber, etag))
+
+		fi, err := fs.Stat(wfs, name)
+		if err != nil || part.PartNumber < 1 || part.PartNumber > maxPartNumber {
+			return &exception.Error{
+				ErrorCode: exception.InvalidPart,
+				Message:   "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.",
+			}
+		}
+
+		if fi.Size() < minPartSize && i < len(req.Part)-1 {
+			return &exception.Error{
+				ErrorCode: exception.EntityTooSmall,
+				Message:   "Your proposed upload is smaller than the minimum allowed object size.",
+			}
+		}
+
+		names = append(names, name)
+		etags = append(etags, etag)
+	}
+
+	etag, err := multipartETag(etags)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	parts := &partsReader{
+		fsys:  wfs,
+		names: names,
+	}
+
+	tempName, _, err := writeTemp(wfs, path.Dir(key), parts, nil)
+	_ = parts.Close()
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	// The ETag of the object is not the MD5 sum of its content, so it is stored with the metadata of the object
+	fi, err := replaceObject(wfs, tempName, key, upload.Metadata, etag)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.etags.Store(ctx.Bucket+"/"+key, fi, etag)
+
+	err = removeMultipartUpload(wfs, upload.UploadId)
+	if err != nil {
+		ctx.Logger.Warn("Unable to remove completed multipart upload", zap.Error(err))
+	}
+
+	var scheme = "http"
+	if ctx.Secure {
+		scheme = "https"
+	}
+
+	requestPath, _, _ := strings.Cut(ctx.Request.RequestURI, "?")
+
+	ctx.SendXML(http.StatusOK, &CompleteMultipartUploadResult{
+		Location: scheme + "://" + ctx.Request.Host + requestPath,
+		Bucket:   ctx.Bucket,
+		Key:      key,
+		ETag:     strconv.Quote(etag),
+	})
+	return nil
+}
@@ -0,0 +1,222 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// copySourceConditionalHeaders maps each x-amz-copy-source-if-* header to the equivalent conditional request header.
+var copySourceConditionalHeaders = map[string]string{
+	"x-amz-copy-source-if-match":            "If-Match",
+	"x-amz-copy-source-if-none-match":       "If-None-Match",
+	"x-amz-copy-source-if-modified-since":   "If-Modified-Since",
+	"x-amz-copy-source-if-unmodified-since": "If-Unmodified-Since",
+}
+
+// copySource is the source object of a copy request.
+type copySource struct {
+	*Object
+	Bucket    string
+	Key       string
+	VersionId string
+}
+
+type CopyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	ETag         string
+	LastModified time.Time
+}
+
+// parseCopySource parses the value of the x-amz-copy-source header, in the form of /bucket/key?versionId=version.
+// The bucket and key are URL encoded, and the leading slash and version ID are optional.
+func parseCopySource(value string) (bucket, key, versionId string, err error) {
+	errInvalidCopySource := &exception.Error{
+		ErrorCode: exception.InvalidArgument,
+		Message:   "Copy Source must mention the source bucket and key: sourcebucket/sourcekey.",
+	}
+
+	encodedPath, rawQuery, _ := strings.Cut(value, "?")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", "", errInvalidCopySource
+	}
+
+	decodedPath, err := url.PathUnescape(strings.TrimPrefix(encodedPath, "/"))
+	if err != nil {
+		return "", "", "", errInvalidCopySource
+	}
+
+	bucket, key, ok := strings.Cut(decodedPath, "/")
+	if !ok || bucket == "" || key == "" {
+		return "", "", "", errInvalidCopySource
+	}
+
+	key, err = urlPathObjectKey("/" + key)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return bucket, key, query.Get("versionId"), nil
+}
+
+// openCopySource opens the source object of a copy request, given by the x-amz-copy-source header.
+// The source object may be in any bucket of the server, and must satisfy any x-amz-copy-source-if-* conditions.
+func (s *Server) openCopySource(ctx *RequestContext) (*copySource, *exception.Error) {
+	bucket, key, versionId, err := parseCopySource(ctx.Request.Header.Get("x-amz-copy-source"))
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	fsys, err := s.filesystemProvider.Open(bucket)
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	action := idp.GetObject
+	if versionId != "" {
+		action = idp.GetObjectVersion
+
+		fsys, err = openBucketVersion(s.filesystemProvider, bucket, fsys, versionId)
+		if err != nil {
+			return nil, exception.ErrorFrom(err)
+		}
+	}
+
+	obj, statErr := statFS(fsys, ctx.etags, versionCacheKey(bucket, versionId)+"/"+key, key)
+	var objCtx idp.PolicyContextVars = idp.NullContext{}
+	if obj != nil {
+		objCtx = obj
+	}
+
+	if err := ctx.CheckAccess(action, idp.Resource(bucket+"/"+key), objCtx); err != nil {
+		if obj != nil {
+			_ = obj.Close()
+		}
+		return nil, err
+	}
+
+	if statErr != nil {
+		// The request must have ListBucket access to see the real error behind accessing the object
+		if err := ctx.CheckAccess(idp.ListBucket, idp.Resource(bucket), objCtx); err != nil {
+			return nil, err
+		}
+
+		return nil, exception.ErrorFrom(statErr)
+	}
+
+	if err := obj.LoadETag(); err != nil {
+		_ = obj.Close()
+		return nil, exception.ErrorFrom(err)
+	}
+
+	// Each copy source condition is evaluated like the equivalent conditional request header,
+	// but any condition that does not hold fails the request.
+	var conditional = make(http.Header)
+	for copyHeader, header := range copySourceConditionalHeaders {
+		if values, ok := ctx.Request.Header[textproto.CanonicalMIMEHeaderKey(copyHeader)]; ok {
+			conditional[textproto.CanonicalMIMEHeaderKey(header)] = values
+		}
+	}
+
+	status, err := checkConditionalRequest(conditional, obj)
+	if err == nil && status != 0 {
+		err = &exception.Error{
+			ErrorCode: exception.PreconditionFailed,
+			Message:   "At least one of the pre-conditions you specified did not hold.",
+		}
+	}
+	if err != nil {
+		_ = obj.Close()
+		return nil, exception.ErrorFrom(err)
+	}
+
+	return &copySource{
+		Object:    obj,
+		Bucket:    bucket,
+		Key:       key,
+		VersionId: versionId,
+	}, nil
+}
+
+func (s *Server) CopyObject(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	if !isWritableKey(key) || strings.HasSuffix(ctx.Request.URL.Path, "/") {
+		return errInvalidObjectKey
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	src, serr := s.openCopySource(ctx)
+	if serr != nil {
+		return serr
+	}
+
+	defer src.Close()
+
+	var meta = src.Metadata
+	switch directive := ctx.Request.Header.Get("x-amz-metadata-directive"); directive {
+	case "", "COPY":
+		if src.Bucket == ctx.Bucket && src.Key == key && (src.VersionId == "" || src.VersionId == nullVersionId) {
+			return &exception.Error{
+				ErrorCode: exception.InvalidRequest,
+				Message:   "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata.",
+			}
+		}
+	case "REPLACE":
+		meta = metadataFromHeader(ctx.Request.Header)
+	default:
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "Unknown metadata directive.",
+		}
+	}
+
+	tempName, _, err := writeTemp(wfs, path.Dir(key), src.ReadCloser, nil)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	// An ETag that is not the MD5 sum of the content, such as the ETag of a multipart upload, is stored with the copy
+	var storedETag string
+	if src.Metadata != nil && src.Metadata.ETag != nil && src.Metadata.ETag.ETag == src.ETag {
+		storedETag = src.ETag
+	}
+
+	fi, err := replaceObject(wfs, tempName, key, meta, storedETag)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	// The copy has the same content as the source, so it also has the same ETag
+	ctx.etags.Store(ctx.Bucket+"/"+key, fi, src.ETag)
+
+	if src.VersionId != "" {
+		ctx.Header().Set("x-amz-copy-source-version-id", src.VersionId)
+	}
+
+	ctx.SendXML(http.StatusOK, &CopyObjectResult{
+		ETag:         strconv.Quote(src.ETag),
+		LastModified: fi.ModTime().UTC(),
+	})
+	return nil
+}
@@ -0,0 +1,190 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCopySource(t *testing.T) {
+	tests := []struct {
+		value     string
+		bucket    string
+		key       string
+		versionId string
+		err       bool
+	}{
+		{value: "bucket/key.txt", bucket: "bucket", key: "key.txt"},
+		{value: "/bucket/dir/key.txt", bucket: "bucket", key: "dir/key.txt"},
+		{value: "/bucket/with%20space.txt?versionId=abc", bucket: "bucket", key: "with space.txt", versionId: "abc"},
+		{value: "/bucket/../../key.txt", bucket: "bucket", key: "key.txt"},
+		{value: "bucket", err: true},
+		{value: "/bucket/", err: true},
+		{value: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			bucket, key, versionId, err := parseCopySource(tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.bucket, bucket)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.versionId, versionId)
+		})
+	}
+}
+
+func TestServer_CopyObject(t *testing.T) {
+	t.Run("copy", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, ".ls3meta"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("Hello, World!"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, ".ls3meta", "object.txt.json"), []byte(`{"Content-Type": "text/plain"}`), 0644)
+
+		srv := testWritableServer(dir)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/copy/object.txt", "", http.Header{
+			"X-Amz-Copy-Source": []string{"/bucket/object.txt"},
+		}, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var result CopyObjectResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Equal(t, `"65a8e27d8879283831b664bd8b7f0ad4"`, result.ETag)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/copy/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "Hello, World!", rw.Body.String())
+		assert.Equal(t, "text/plain", rw.Header().Get("Content-Type"))
+		assert.Equal(t, `"65a8e27d8879283831b664bd8b7f0ad4"`, rw.Header().Get("ETag"))
+	})
+
+	t.Run("across buckets", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "src"), 0755)
+		_ = os.MkdirAll(filepath.Join(dir, "dst"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "src", "object.txt"), []byte("object"), 0644)
+
+		srv := testServer()
+		srv.filesystemProvider = &SubdirBucketFilesystem{
+			FS:       DirFS(dir),
+			Writable: []string{"dst"},
+		}
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/dst/object.txt", "", http.Header{
+			"X-Amz-Copy-Source": []string{"src/object.txt"},
+		}, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		b, err := os.ReadFile(filepath.Join(dir, "dst", "object.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "object", string(b))
+	})
+
+	t.Run("replace metadata", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		srv := testWritableServer(dir)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", http.Header{
+			"X-Amz-Copy-Source":        []string{"bucket/object.txt"},
+			"X-Amz-Metadata-Directive": []string{"REPLACE"},
+			"Content-Type":             []string{"text/csv"},
+		}, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodHead, "/bucket/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, "text/csv", rw.Header().Get("Content-Type"))
+	})
+
+	t.Run("copy to itself", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", http.Header{
+			"X-Amz-Copy-Source": []string{"bucket/object.txt"},
+		}, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidRequest)
+	})
+
+	t.Run("precondition failed", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/copy.txt", "", http.Header{
+			"X-Amz-Copy-Source":          []string{"bucket/object.txt"},
+			"X-Amz-Copy-Source-If-Match": []string{`"d41d8cd98f00b204e9800998ecf8427e"`},
+		}, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.PreconditionFailed)
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("no such key", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/copy.txt", "", http.Header{
+			"X-Amz-Copy-Source": []string{"bucket/object.txt"},
+		}, nil)
+		testWritableServer(t.TempDir()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchKey)
+	})
+}
+
+func TestServer_UploadPartCopy(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("Hello, World!"), 0644)
+
+	srv := testWritableServer(dir)
+	uploadId := testCreateMultipartUpload(t, srv, "copy.txt")
+
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/copy.txt", "partNumber=1&uploadId="+uploadId, http.Header{
+		"X-Amz-Copy-Source":       []string{"bucket/object.txt"},
+		"X-Amz-Copy-Source-Range": []string{"bytes=7-11"},
+	}, nil)
+	srv.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	var result CopyPartResult
+	assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+
+	rw = testCompleteMultipartUpload(srv, "copy.txt", uploadId, CompletedPart{PartNumber: 1, ETag: result.ETag})
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	b, err := os.ReadFile(filepath.Join(dir, "copy.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "World", string(b))
+}
@@ -0,0 +1,90 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+// maxCreateBucketRequestSize is the maximum size of a CreateBucket request body.
+const maxCreateBucketRequestSize = 64 * 1024
+
+type CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string
+}
+
+func (s *Server) CreateBucket(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.CreateBucket, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	// The bucket configuration is optional
+	var config CreateBucketConfiguration
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ReadXML(&config, maxCreateBucketRequestSize); err != nil {
+			return err
+		}
+	}
+
+	// A request to create a bucket is signed for the region of the server, or the region of the new bucket
+	if signed, ok := signedCredential(ctx.Request); ok && signed.Region != config.LocationConstraint {
+		if err := s.checkSignedRegion(ctx, signed.Region); err != nil {
+			return err
+		}
+	}
+
+	provider, ok := s.filesystemProvider.(ManagedBucketFilesystemProvider)
+	if !ok || !s.manageBuckets {
+		return errBucketsNotManaged
+	}
+
+	if !validBucketName(ctx.Bucket) {
+		return &exception.Error{
+			ErrorCode: exception.InvalidBucketName,
+			Message:   "The specified bucket is not valid.",
+		}
+	}
+
+	// A bucket in a region other than the region of the server stores its region in its configuration
+	var region *BucketRegion
+	if config.LocationConstraint != "" && config.LocationConstraint != s.region {
+		if !validRegionName(config.LocationConstraint) {
+			return &exception.Error{
+				ErrorCode: exception.InvalidLocationConstraint,
+				Message:   "The specified location-constraint is not valid.",
+			}
+		}
+
+		if _, ok := provider.(BucketConfigFilesystemProvider); !ok {
+			return &exception.Error{
+				ErrorCode: exception.InvalidLocationConstraint,
+				Message:   "Buckets can only be created in the region of this server.",
+			}
+		}
+
+		region = &BucketRegion{Region: config.LocationConstraint}
+	}
+
+	err := provider.CreateBucket(ctx.Bucket)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	if region != nil {
+		wfs, werr := s.openBucketConfig(ctx)
+		if werr != nil {
+			return werr
+		}
+
+		err = writeBucketConfig(wfs, bucketRegionFile, region)
+		if err != nil {
+			return unwrapWriteError(err)
+		}
+	}
+
+	ctx.Header().Set("Location", "/"+ctx.Bucket)
+	ctx.SendPlain(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,171 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testManagedServer is like testServer, but serves each subdirectory of dir as a bucket that can be created and deleted.
+func testManagedServer(dir string) *Server {
+	srv := testServer()
+	srv.filesystemProvider = &SubdirBucketFilesystem{
+		FS:       DirFS(dir),
+		Writable: []string{"*"},
+	}
+	srv.manageBuckets = true
+
+	return srv
+}
+
+func TestServer_CreateBucket(t *testing.T) {
+	t.Run("create", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/new-bucket/", "", nil, []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>us-east-1</LocationConstraint>
+</CreateBucketConfiguration>`))
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "/new-bucket", rw.Header().Get("Location"))
+		assertDirEntries(t, dir, "new-bucket")
+	})
+
+	t.Run("location constraint", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/new-bucket/", "", nil, []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>eu-west-1</LocationConstraint>
+</CreateBucketConfiguration>`))
+		req.ContentLength, _ = strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, "new-bucket"), bucketRegionFile)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "new-bucket", bucketRegionFile))
+		assert.Contains(t, string(b), `"eu-west-1"`)
+	})
+
+	t.Run("signed for location constraint", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{Region: "eu-west-1"}, http.MethodPut, "/new-bucket/", "", nil, []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>eu-west-1</LocationConstraint>
+</CreateBucketConfiguration>`))
+		req.ContentLength, _ = strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, "new-bucket"), bucketRegionFile)
+	})
+
+	t.Run("signed for other region", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{Region: "eu-west-1"}, http.MethodPut, "/new-bucket/", "", nil, []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>eu-central-1</LocationConstraint>
+</CreateBucketConfiguration>`))
+		req.ContentLength, _ = strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.AuthorizationHeaderMalformed)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("invalid location constraint", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/new-bucket/", "", nil, []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <LocationConstraint>EU West</LocationConstraint>
+</CreateBucketConfiguration>`))
+		req.ContentLength, _ = strconv.ParseInt(req.Header.Get("Content-Length"), 10, 64)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidLocationConstraint)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("already exists", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.Mkdir(filepath.Join(dir, "bucket"), 0755)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/", "", nil, nil)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BucketAlreadyOwnedByYou)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/Invalid_Bucket/", "", nil, nil)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidBucketName)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		dir := t.TempDir()
+
+		srv := testManagedServer(dir)
+		srv.manageBuckets = false
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/new-bucket/", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+		assertDirEntries(t, dir)
+	})
+}
+
+func TestServer_DeleteBucket(t *testing.T) {
+	t.Run("delete", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "bucket", ".ls3uploads", "upload"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "bucket", ".ls3uploads", "upload", "upload.json"), []byte("{}"), 0644)
+		_ = os.Mkdir(filepath.Join(dir, "other"), 0755)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "", nil, nil)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir, "other")
+	})
+
+	t.Run("not empty", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "bucket", "dir"), 0755)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "", nil, nil)
+		testManagedServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BucketNotEmpty)
+		assertDirEntries(t, filepath.Join(dir, "bucket"), "dir")
+	})
+
+	t.Run("no such bucket", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/", "", nil, nil)
+		testManagedServer(t.TempDir()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchBucket)
+	})
+}
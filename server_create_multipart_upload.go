@@ -0,0 +1,58 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+func (s *Server) CreateMultipartUpload(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	// Directories cannot be uploaded in parts
+	if !isWritableKey(key) || strings.HasSuffix(ctx.Request.URL.Path, "/") {
+		return errInvalidObjectKey
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	var upload = multipartUpload{
+		UploadId:  newUploadId(),
+		Key:       key,
+		Initiated: time.Now().UTC(),
+		Initiator: ctx.Identity.Name,
+		Metadata:  metadataFromHeader(ctx.Request.Header),
+	}
+
+	err = createMultipartUpload(wfs, &upload)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.SendXML(http.StatusOK, &InitiateMultipartUploadResult{
+		Bucket:   ctx.Bucket,
+		Key:      key,
+		UploadId: upload.UploadId,
+	})
+	return nil
+}
@@ -0,0 +1,26 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) DeleteBucket(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.DeleteBucket, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	provider, ok := s.filesystemProvider.(ManagedBucketFilesystemProvider)
+	if !ok || !s.manageBuckets {
+		return errBucketsNotManaged
+	}
+
+	err := provider.DeleteBucket(ctx.Bucket)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.SendPlain(http.StatusNoContent)
+	return nil
+}
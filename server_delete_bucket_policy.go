@@ -0,0 +1,26 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) DeleteBucketPolicy(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.DeleteBucketPolicy, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	wfs, werr := s.openBucketConfig(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	err := writeBucketConfig(wfs, bucketPolicyFile, nil)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.SendPlain(http.StatusNoContent)
+	return nil
+}
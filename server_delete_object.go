@@ -0,0 +1,110 @@
+package ls3
+
+import (
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// pruneEmptyDirs removes each empty parent directory of name, up to but not including the root of the filesystem.
+// Directories created as objects are never empty, because they contain a directory marker.
+func pruneEmptyDirs(fsys WritableFS, name string) {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		// Remove fails if the directory is not empty
+		if fsys.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// deleteObject removes the object key and its metadata, then prunes any parent directories that are now empty.
+// If isDir is true, then key is the empty directory key.
+// Deleting an object that does not exist is not an error.
+func deleteObject(fsys WritableFS, key string, isDir bool) error {
+	// Objects reserved for use by ls3 never exist
+	if key == "" || key == "." || isReservedPath(key) {
+		return nil
+	}
+
+	fi, err := fs.Stat(fsys, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// A directory can only be deleted using its directory key, and only if it is empty.
+	// A file can only be deleted using its file key.
+	if fi.IsDir() != isDir {
+		return nil
+	}
+
+	if isDir {
+		entries, err := fs.ReadDir(fsys, key)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.Name() != directoryMarkerName {
+				return nil
+			}
+		}
+
+		err = fsys.Remove(path.Join(key, directoryMarkerName))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	err = fsys.Remove(key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if !isDir {
+		err = writeObjectMetadata(fsys, key, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	pruneEmptyDirs(fsys, key)
+	return nil
+}
+
+func (s *Server) DeleteObject(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.DeleteObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	if ctx.VersionId != "" && ctx.VersionId != nullVersionId {
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "Previous versions of an object cannot be deleted.",
+		}
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	err = deleteObject(wfs, key, strings.HasSuffix(ctx.Request.URL.Path, "/"))
+	if err != nil {
+		return unwrapFsError(err)
+	}
+
+	ctx.SendPlain(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,277 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_DeleteObject(t *testing.T) {
+	t.Run("delete", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a", "b", ".ls3meta"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "a", "b", "object.txt"), []byte("object"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "a", "b", ".ls3meta", "object.txt.json"), []byte("{}"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "a", "other.txt"), []byte("other"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a/b/object.txt", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, "a"), "other.txt")
+		assertDirEntries(t, dir, "a")
+	})
+
+	t.Run("prune to root", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "a", "b", "object.txt"), []byte("object"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a/b/object.txt", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/object.txt", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+	})
+
+	t.Run("directory without directory key", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a"), 0755)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir, "a")
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a/b/", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("directory object", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "a", "b", directoryMarkerName), nil, 0644)
+		_ = os.WriteFile(filepath.Join(dir, "a", "b", "object.txt"), []byte("object"), 0644)
+
+		srv := testWritableServer(dir)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a/b/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, "a", "b"), directoryMarkerName)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/a/b/", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, ".ls3meta"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, ".ls3meta", "object.txt.json"), []byte("{}"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/.ls3meta/object.txt.json", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, ".ls3meta"), "object.txt.json")
+	})
+
+	t.Run("snapshot dir", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testSnapshotServer(dir)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/.snapshots/daily/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, filepath.Join(dir, "bucket", ".snapshots", "daily"), "object.txt")
+	})
+
+	t.Run("sub-resource", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/object.txt", "tagging=", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("not writable", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		srv := testWritableServer(dir)
+		srv.filesystemProvider = &SingleBucketFilesystem{FS: DirFS(dir)}
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+		assertDirEntries(t, dir, "object.txt")
+	})
+}
+
+func TestServer_DeleteObjects(t *testing.T) {
+	t.Run("delete", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "a"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "a", "one.txt"), []byte("one"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "two.txt"), []byte("two"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep"), 0644)
+
+		srv := testWritableServer(dir)
+		srv.globalPolicy = append(srv.globalPolicy, &idp.PolicyStatement{
+			Deny:     true,
+			Action:   []idp.Action{idp.DeleteObject},
+			Resource: []idp.Resource{"bucket/keep.txt"},
+		})
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/", "delete", nil, []byte(`<Delete>
+  <Object><Key>a/one.txt</Key></Object>
+  <Object><Key>two.txt</Key></Object>
+  <Object><Key>missing.txt</Key></Object>
+  <Object><Key>keep.txt</Key></Object>
+</Delete>`))
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var result DeleteResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Equal(t, []DeletedObject{
+			{Key: "a/one.txt"},
+			{Key: "two.txt"},
+			{Key: "missing.txt"},
+		}, result.Deleted)
+		assert.Equal(t, []DeleteError{
+			{
+				Key:     "keep.txt",
+				Code:    exception.AccessDenied.Code,
+				Message: "You do not have permission to access this resource.",
+			},
+		}, result.Error)
+
+		assertDirEntries(t, dir, "keep.txt")
+	})
+
+	t.Run("traversal", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "tmp"), 0755)
+		_ = os.MkdirAll(filepath.Join(dir, "important"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "important", "file"), []byte("important"), 0644)
+
+		srv := testWritableServer(dir)
+		srv.globalPolicy = []*idp.PolicyStatement{
+			{
+				Action:   []idp.Action{idp.DeleteObject},
+				Resource: []idp.Resource{"bucket/tmp/*"},
+			},
+		}
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/", "delete", nil, []byte(`<Delete>
+  <Object><Key>tmp/../important/file</Key></Object>
+</Delete>`))
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var result DeleteResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Empty(t, result.Deleted)
+		assert.Equal(t, []DeleteError{
+			{
+				Key:     "tmp/../important/file",
+				Code:    exception.InvalidArgument.Code,
+				Message: "The specified object key is not valid.",
+			},
+		}, result.Error)
+
+		assertDirEntries(t, filepath.Join(dir, "important"), "file")
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "one.txt"), []byte("one"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/", "delete", nil, []byte(`<Delete>
+  <Quiet>true</Quiet>
+  <Object><Key>one.txt</Key></Object>
+</Delete>`))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var result DeleteResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Empty(t, result.Deleted)
+		assert.Empty(t, result.Error)
+
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("bad digest", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "one.txt"), []byte("one"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/", "delete", http.Header{
+			"Content-Md5": []string{"ZajifYh5KDgxtmS9i38K1A=="},
+		}, []byte(`<Delete><Object><Key>one.txt</Key></Object></Delete>`))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BadDigest)
+		assertDirEntries(t, dir, "one.txt")
+	})
+
+	t.Run("no objects", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/", "delete", nil, []byte(`<Delete></Delete>`))
+		testWritableServer(t.TempDir()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MalformedXML)
+	})
+}
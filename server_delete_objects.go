@@ -0,0 +1,121 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strings"
+)
+
+// maxDeleteObjects is the maximum number of objects that can be deleted in a single DeleteObjects request.
+const maxDeleteObjects = 1000
+
+// maxDeleteRequestSize is the maximum size of a DeleteObjects request body.
+const maxDeleteRequestSize = 2 * 1024 * 1024
+
+type ObjectIdentifier struct {
+	Key       string
+	VersionId string `xml:",omitempty"`
+}
+
+type DeleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool
+	Object  []ObjectIdentifier
+}
+
+type DeletedObject struct {
+	Key       string
+	VersionId string `xml:",omitempty"`
+}
+
+type DeleteError struct {
+	Key       string
+	VersionId string `xml:",omitempty"`
+	Code      string
+	Message   string
+}
+
+type DeleteResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []DeletedObject
+	Error   []DeleteError
+}
+
+func (s *Server) DeleteObjects(ctx *RequestContext) *exception.Error {
+	var req DeleteRequest
+	if err := ctx.ReadXML(&req, maxDeleteRequestSize); err != nil {
+		return err
+	}
+
+	if len(req.Object) == 0 || len(req.Object) > maxDeleteObjects {
+		return &exception.Error{
+			ErrorCode: exception.MalformedXML,
+			Message:   "The XML you provided was not well-formed or did not validate against our published schema.",
+		}
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	var (
+		result DeleteResult
+		log    = ctx.Logger
+	)
+
+	for _, obj := range req.Object {
+		objErr := func() *exception.Error {
+			// Access to each object is checked individually
+			defer func() {
+				ctx.Logger = log
+			}()
+
+			key, ok := cleanObjectKey(obj.Key)
+			if !ok {
+				return errInvalidObjectKey
+			}
+
+			if err := ctx.CheckAccess(idp.DeleteObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+				return err
+			}
+
+			if obj.VersionId != "" && obj.VersionId != nullVersionId {
+				return &exception.Error{
+					ErrorCode: exception.InvalidArgument,
+					Message:   "Previous versions of an object cannot be deleted.",
+				}
+			}
+
+			err := deleteObject(wfs, key, strings.HasSuffix(obj.Key, "/"))
+			if err != nil {
+				return unwrapFsError(err)
+			}
+
+			return nil
+		}()
+
+		if objErr != nil {
+			result.Error = append(result.Error, DeleteError{
+				Key:       obj.Key,
+				VersionId: obj.VersionId,
+				Code:      objErr.Code,
+				Message:   objErr.Message,
+			})
+			continue
+		}
+
+		// In quiet mode, only errors are returned
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, DeletedObject{
+				Key:       obj.Key,
+				VersionId: obj.VersionId,
+			})
+		}
+	}
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
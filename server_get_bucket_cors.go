@@ -0,0 +1,33 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) GetBucketCors(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.GetBucketCORS, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	fsys, err := s.filesystemProvider.Open(ctx.Bucket)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	config, err := readBucketCors(fsys)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if config == nil {
+		return &exception.Error{
+			ErrorCode: exception.NoSuchCORSConfiguration,
+			Message:   "The CORS configuration does not exist.",
+		}
+	}
+
+	ctx.SendXML(http.StatusOK, config)
+	return nil
+}
@@ -17,6 +17,12 @@ func (s *Server) GetBucketLocation(ctx *RequestContext) *exception.Error {
 		return err
 	}
 
-	ctx.SendXML(http.StatusOK, &LocationConstraint{})
+	// Buckets in us-east-1 have an empty location constraint
+	var location LocationConstraint
+	if region := s.bucketRegion(ctx); region != "us-east-1" {
+		location.LocationConstraint = region
+	}
+
+	ctx.SendXML(http.StatusOK, &location)
 	return nil
 }
@@ -0,0 +1,35 @@
+package ls3
+
+import (
+	"encoding/json"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) GetBucketPolicy(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.GetBucketPolicy, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	policy, err := s.readBucketPolicy(ctx.Bucket)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if policy == nil {
+		return &exception.Error{
+			ErrorCode: exception.NoSuchBucketPolicy,
+			Message:   "The bucket policy does not exist.",
+		}
+	}
+
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	ctx.Header().Set("Content-Type", "application/json")
+	_, _ = ctx.SendPlain(http.StatusOK).Write(b)
+	return nil
+}
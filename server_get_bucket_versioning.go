@@ -0,0 +1,29 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) GetBucketVersioning(ctx *RequestContext) *exception.Error {
+	type VersioningConfiguration struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+		Status  string   `xml:",omitempty"`
+	}
+
+	if err := ctx.CheckAccess(idp.GetBucketVersioning, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	var result VersioningConfiguration
+
+	// A bucket that has never had versioning enabled returns no status
+	if versioned, ok := s.filesystemProvider.(VersionedBucketFilesystemProvider); ok && versioned.VersioningEnabled(ctx.Bucket) {
+		result.Status = "Enabled"
+	}
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
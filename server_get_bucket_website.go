@@ -0,0 +1,33 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+func (s *Server) GetBucketWebsite(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.GetBucketWebsite, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	fsys, err := s.filesystemProvider.Open(ctx.Bucket)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	config, err := readBucketWebsite(fsys)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if config == nil {
+		return &exception.Error{
+			ErrorCode: exception.NoSuchWebsiteConfiguration,
+			Message:   "The specified bucket does not have a website configuration.",
+		}
+	}
+
+	ctx.SendXML(http.StatusOK, config)
+	return nil
+}
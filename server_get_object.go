@@ -33,7 +33,7 @@ func (s *Server) GetObject(ctx *RequestContext) *exception.Error {
 		objCtx = obj
 	}
 
-	if err := ctx.CheckAccess(idp.GetObject, idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
+	if err := ctx.CheckAccess(ctx.objectReadAction(), idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
 		return err
 	}
 
@@ -46,6 +46,11 @@ func (s *Server) GetObject(ctx *RequestContext) *exception.Error {
 		return exception.ErrorFrom(statErr)
 	}
 
+	// The ETag is only computed once access to the object is allowed
+	if err := obj.LoadETag(); err != nil {
+		return exception.ErrorFrom(err)
+	}
+
 	var (
 		query  = ctx.Request.URL.Query()
 		header = ctx.Header()
@@ -54,6 +59,14 @@ func (s *Server) GetObject(ctx *RequestContext) *exception.Error {
 	header.Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
 	header.Set("ETag", strconv.Quote(obj.ETag))
 
+	if ctx.VersionId != "" {
+		header.Set("x-amz-version-id", ctx.VersionId)
+	}
+
+	if len(obj.Tags) > 0 {
+		header.Set("x-amz-tagging-count", strconv.Itoa(len(obj.Tags)))
+	}
+
 	// Conditional Response
 	conditional, err := checkConditionalRequest(ctx.Request.Header, obj)
 	if err != nil {
@@ -64,19 +77,12 @@ func (s *Server) GetObject(ctx *RequestContext) *exception.Error {
 		return nil
 	}
 
-	var responseCode = http.StatusOK
-	var contentLength = obj.Size
-
-	if obj.Range != nil {
-		// Set accepted range
-		responseCode = http.StatusPartialContent
-		contentLength = obj.Range.Length
-		header.Set("Content-Range", obj.Range.ContentRange(obj.Size))
+	err = limitRange(ctx.Request, obj)
+	if err != nil {
+		return exception.ErrorFrom(err)
 	}
 
-	header.Set("Content-Length", strconv.Itoa(int(contentLength)))
-	header.Set("Content-Type", obj.ContentType)
-	header.Set("Accept-Ranges", "bytes")
+	setMetadataHeaders(header, obj)
 
 	// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html -> Overriding Response Header Values
 	modQueryResponseHeader(query, header, "response-content-type", "Content-Type")
@@ -86,8 +92,11 @@ func (s *Server) GetObject(ctx *RequestContext) *exception.Error {
 	modQueryResponseHeader(query, header, "response-content-disposition", "Content-Disposition")
 	modQueryResponseHeader(query, header, "response-content-encoding", "Content-Encoding")
 
+	responseCode, body, contentLength := rangeResponse(header, obj)
+	header.Set("Content-Length", strconv.Itoa(int(contentLength)))
+
 	// Write the response
-	bytesSent, _ := io.Copy(ctx.SendPlain(responseCode), obj)
+	bytesSent, _ := io.Copy(ctx.SendPlain(responseCode), body)
 
 	// Update statistics
 	statBytesTransferredOut.WithLabelValues(ctx.Bucket, key, ctx.Identity.Name, ctx.RemoteIP.String()).Add(float64(bytesSent))
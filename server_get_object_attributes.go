@@ -0,0 +1,122 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strings"
+)
+
+type ObjectAttributesChecksum struct {
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+type GetObjectAttributesResponse struct {
+	XMLName      xml.Name                  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ GetObjectAttributesResponse"`
+	ETag         string                    `xml:",omitempty"`
+	Checksum     *ObjectAttributesChecksum `xml:",omitempty"`
+	StorageClass string                    `xml:",omitempty"`
+	ObjectSize   *int64                    `xml:",omitempty"`
+}
+
+// objectAttributes returns the set of attributes requested in x-amz-object-attributes.
+func objectAttributes(r *http.Request) (map[string]bool, error) {
+	var attributes = make(map[string]bool)
+
+	for _, hdr := range r.Header.Values("x-amz-object-attributes") {
+		for _, attribute := range strings.Split(hdr, ",") {
+			attribute = strings.TrimSpace(attribute)
+
+			switch attribute {
+			case "ETag", "Checksum", "ObjectParts", "StorageClass", "ObjectSize":
+				attributes[attribute] = true
+			case "":
+			default:
+				return nil, &exception.Error{
+					ErrorCode: exception.InvalidArgument,
+					Message:   "Invalid attribute name specified.",
+				}
+			}
+		}
+	}
+
+	if len(attributes) == 0 {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "The x-amz-object-attributes header specifying the attributes to be retrieved is either missing or empty.",
+		}
+	}
+
+	return attributes, nil
+}
+
+func (s *Server) GetObjectAttributes(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	attributes, err := objectAttributes(ctx.Request)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	// Try to stat the object first, to allow authentication context with the object.
+	obj, statErr := stat(ctx, key)
+	var objCtx idp.PolicyContextVars = idp.NullContext{}
+	if obj != nil {
+		defer obj.Close()
+		objCtx = obj
+	}
+
+	var action = idp.GetObjectAttributes
+	if ctx.VersionId != "" {
+		action = idp.GetObjectVersionAttributes
+	}
+
+	if err := ctx.CheckAccess(action, idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
+		return err
+	}
+
+	if statErr != nil {
+		// The request must have ListBucket access to see the real error behind accessing the object
+		if err := ctx.CheckAccess(idp.ListBucket, idp.Resource(ctx.Bucket), objCtx); err != nil {
+			return err
+		}
+
+		return exception.ErrorFrom(statErr)
+	}
+
+	var result GetObjectAttributesResponse
+
+	if attributes["ETag"] {
+		if err := obj.LoadETag(); err != nil {
+			return exception.ErrorFrom(err)
+		}
+
+		result.ETag = obj.ETag
+	}
+	if attributes["StorageClass"] {
+		result.StorageClass = "STANDARD"
+	}
+	if attributes["ObjectSize"] {
+		result.ObjectSize = &obj.Size
+	}
+	if attributes["Checksum"] {
+		checksum, err := obj.ChecksumSHA256()
+		if err != nil {
+			return exception.ErrorFrom(err)
+		}
+
+		result.Checksum = &ObjectAttributesChecksum{
+			ChecksumSHA256: checksum,
+		}
+	}
+
+	// ObjectParts is never returned, because the parts of a completed multipart upload are not kept
+
+	ctx.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
@@ -0,0 +1,42 @@
+package ls3
+
+import (
+	"github.com/psanford/memfs"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_GetObjectAttributes(t *testing.T) {
+	fsys := memfs.New()
+	_ = fsys.WriteFile("object.txt", []byte("Hello, World!"), 0644)
+
+	t.Run("attributes", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "attributes=", http.Header{
+			"X-Amz-Object-Attributes": []string{"ETag,ObjectSize", "StorageClass,Checksum"},
+		}, nil)
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<GetObjectAttributesResponse xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <ETag>65a8e27d8879283831b664bd8b7f0ad4</ETag>
+  <Checksum>
+    <ChecksumSHA256>3/1gIbsr1bCvZ2KQgJ7DpTGR3YHH9wpLKGiKNiGCmG8=</ChecksumSHA256>
+  </Checksum>
+  <StorageClass>STANDARD</StorageClass>
+  <ObjectSize>13</ObjectSize>
+</GetObjectAttributesResponse>`, rw.Body.String())
+	})
+
+	t.Run("missing_attributes", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "attributes=", nil, nil)
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidArgument)
+	})
+}
@@ -0,0 +1,71 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"sort"
+)
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type Tagging struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Tagging"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+func (s *Server) GetObjectTagging(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	// Try to stat the object first, to allow authentication context with the object.
+	obj, statErr := stat(ctx, key)
+	var objCtx idp.PolicyContextVars = idp.NullContext{}
+	if obj != nil {
+		_ = obj.Close()
+		objCtx = obj
+	}
+
+	var action = idp.GetObjectTagging
+	if ctx.VersionId != "" {
+		action = idp.GetObjectVersionTagging
+	}
+
+	if err := ctx.CheckAccess(action, idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
+		return err
+	}
+
+	if statErr != nil {
+		// The request must have ListBucket access to see the real error behind accessing the object
+		if err := ctx.CheckAccess(idp.ListBucket, idp.Resource(ctx.Bucket), objCtx); err != nil {
+			return err
+		}
+
+		return exception.ErrorFrom(statErr)
+	}
+
+	var result = Tagging{
+		TagSet: make([]Tag, 0, len(obj.Tags)),
+	}
+
+	for k, v := range obj.Tags {
+		result.TagSet = append(result.TagSet, Tag{Key: k, Value: v})
+	}
+
+	sort.Slice(result.TagSet, func(i, j int) bool {
+		return result.TagSet[i].Key < result.TagSet[j].Key
+	})
+
+	if ctx.VersionId != "" {
+		ctx.Header().Set("x-amz-version-id", ctx.VersionId)
+	}
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
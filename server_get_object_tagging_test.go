@@ -0,0 +1,61 @@
+//go:build linux
+
+package ls3
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_GetObjectTagging(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "object.txt")
+	_ = os.WriteFile(name, []byte("Hello, World!"), 0644)
+
+	err := unix.Setxattr(name, "user.s3.tag.classification", []byte("public"), 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		t.Skip("Extended attributes are not supported by the temporary directory")
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_ = unix.Setxattr(name, "user.s3.tag.owner", []byte("ls3"), 0)
+	_ = unix.Setxattr(name, "user.comment", []byte("not a tag"), 0)
+
+	t.Run("tagging", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "tagging=", nil, nil)
+		testServerFS(os.DirFS(dir)).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <TagSet>
+    <Tag>
+      <Key>classification</Key>
+      <Value>public</Value>
+    </Tag>
+    <Tag>
+      <Key>owner</Key>
+      <Value>ls3</Value>
+    </Tag>
+  </TagSet>
+</Tagging>`, rw.Body.String())
+	})
+
+	t.Run("tagging_count", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		testServerFS(os.DirFS(dir)).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "2", rw.Header().Get("x-amz-tagging-count"))
+	})
+}
@@ -1,14 +1,107 @@
 package ls3
 
 import (
+	"github.com/psanford/memfs"
 	"github.com/relvacode/ls3/exception"
 	"github.com/relvacode/ls3/idp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 )
 
 func TestServer_GetObject(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		fsys := memfs.New()
+		_ = fsys.WriteFile("object.txt", []byte("Hello, World!"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `"65a8e27d8879283831b664bd8b7f0ad4"`, rw.Header().Get("ETag"))
+		assert.Equal(t, "Hello, World!", rw.Body.String())
+	})
+
+	t.Run("not_modified", func(t *testing.T) {
+		fsys := memfs.New()
+		_ = fsys.WriteFile("object.txt", []byte("Hello, World!"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", http.Header{
+			"If-None-Match": []string{`"65a8e27d8879283831b664bd8b7f0ad4"`},
+		}, nil)
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNotModified, rw.Code)
+	})
+
+	t.Run("multiple_ranges", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("0123456789abcdefghij"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", http.Header{
+			"Range": []string{"bytes=0-1,15-,1-3"},
+		}, nil)
+		testServerFS(os.DirFS(dir)).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusPartialContent, rw.Code)
+		assert.Equal(t, strconv.Itoa(rw.Body.Len()), rw.Header().Get("Content-Length"))
+
+		mediaType, params, err := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/byteranges", mediaType)
+
+		var (
+			mr            = multipart.NewReader(rw.Body, params["boundary"])
+			contentRanges []string
+			contents      []string
+		)
+
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+
+			b, _ := io.ReadAll(part)
+			contentRanges = append(contentRanges, part.Header.Get("Content-Range"))
+			contents = append(contents, string(b))
+		}
+
+		assert.Equal(t, []string{"bytes 0-3/20", "bytes 15-19/20"}, contentRanges)
+		assert.Equal(t, []string{"0123", "fghij"}, contents)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		fsys := memfs.New()
+		_ = fsys.WriteFile("object.txt", []byte("Hello, World!"), 0644)
+
+		srv := testServerFS(fsys, &idp.PolicyStatement{
+			Deny:     true,
+			Action:   idp.OptionalList[idp.Action]{idp.GetObject},
+			Resource: idp.OptionalList[idp.Resource]{"*"},
+		})
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+
+		// The object is not read to compute its ETag unless access is allowed
+		assert.Empty(t, srv.etags.entries)
+	})
+
 	t.Run("not_found", func(t *testing.T) {
 		t.Run("with_bucket_access", func(t *testing.T) {
 			rw := httptest.NewRecorder()
@@ -6,9 +6,6 @@ import (
 	"net/http"
 )
 
-// amzRegion is a constant pretend region for all filesystemProvider provided by this server.
-const amzRegion = "us-east-1"
-
 func (s *Server) HeadBucket(ctx *RequestContext) *exception.Error {
 	err := ctx.CheckAccess(idp.ListBucket, idp.Resource(ctx.Bucket), idp.NullContext{})
 	if err != nil {
@@ -17,7 +14,7 @@ func (s *Server) HeadBucket(ctx *RequestContext) *exception.Error {
 		return nil
 	}
 
-	ctx.Header().Set("x-amz-bucket-region", amzRegion)
+	ctx.Header().Set("x-amz-bucket-region", s.bucketRegion(ctx))
 	ctx.SendPlain(http.StatusOK)
 	return nil
 }
@@ -19,11 +19,11 @@ func (s *Server) HeadObject(ctx *RequestContext) *exception.Error {
 	obj, statErr := stat(ctx, key)
 	var objCtx idp.PolicyContextVars = idp.NullContext{}
 	if obj != nil {
-		_ = obj.Close()
+		defer obj.Close()
 		objCtx = obj
 	}
 
-	if err := ctx.CheckAccess(idp.GetObject, idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
+	if err := ctx.CheckAccess(ctx.objectReadAction(), idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
 		// HEAD request that errors contains no response body
 		ctx.SendPlain(err.StatusCode)
 		return nil
@@ -42,11 +42,22 @@ func (s *Server) HeadObject(ctx *RequestContext) *exception.Error {
 		return nil
 	}
 
+	// The ETag is only computed once access to the object is allowed
+	if err := obj.LoadETag(); err != nil {
+		// HEAD request that errors contains no response body
+		ctx.SendPlain(exception.ErrorFrom(err).StatusCode)
+		return nil
+	}
+
 	var header = ctx.Header()
 
 	header.Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
 	header.Set("ETag", strconv.Quote(obj.ETag))
 
+	if ctx.VersionId != "" {
+		header.Set("x-amz-version-id", ctx.VersionId)
+	}
+
 	// Conditional Response
 	conditional, err := checkConditionalRequest(ctx.Request.Header, obj)
 	if err != nil {
@@ -59,16 +70,18 @@ func (s *Server) HeadObject(ctx *RequestContext) *exception.Error {
 		return nil
 	}
 
-	var contentLength = obj.Size
-
-	if obj.Range != nil {
-		contentLength = obj.Range.Length
-		header.Set("Accept-Ranges", "bytes")
+	err = limitRange(ctx.Request, obj)
+	if err != nil {
+		// HEAD request that errors contains no response body
+		ctx.SendPlain(exception.ErrorFrom(err).StatusCode)
+		return nil
 	}
 
+	// The response has the same status and headers as GetObject, without the body
+	setMetadataHeaders(header, obj)
+	responseCode, _, contentLength := rangeResponse(header, obj)
 	header.Set("Content-Length", strconv.Itoa(int(contentLength)))
-	header.Set("Content-Type", obj.ContentType)
 
-	ctx.SendPlain(http.StatusOK)
+	ctx.SendPlain(responseCode)
 	return nil
 }
@@ -0,0 +1,60 @@
+package ls3
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer_HeadObject(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("0123456789abcdefghij"), 0644)
+
+	head := func(rangeHeader string) *httptest.ResponseRecorder {
+		var headers http.Header
+		if rangeHeader != "" {
+			headers = http.Header{"Range": []string{rangeHeader}}
+		}
+
+		rw := httptest.NewRecorder()
+		testServerFS(os.DirFS(dir)).ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodHead, "/bucket/object.txt", "", headers, nil))
+		return rw
+	}
+
+	t.Run("full", func(t *testing.T) {
+		rw := head("")
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "bytes", rw.Header().Get("Accept-Ranges"))
+		assert.Equal(t, "20", rw.Header().Get("Content-Length"))
+		assert.Equal(t, 0, rw.Body.Len())
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rw := head("bytes=2-4")
+
+		assert.Equal(t, http.StatusPartialContent, rw.Code)
+		assert.Equal(t, "bytes", rw.Header().Get("Accept-Ranges"))
+		assert.Equal(t, "bytes 2-4/20", rw.Header().Get("Content-Range"))
+		assert.Equal(t, "3", rw.Header().Get("Content-Length"))
+		assert.Equal(t, 0, rw.Body.Len())
+	})
+
+	t.Run("multiple ranges", func(t *testing.T) {
+		rw := head("bytes=0-1,15-")
+
+		assert.Equal(t, http.StatusPartialContent, rw.Code)
+		assert.True(t, strings.HasPrefix(rw.Header().Get("Content-Type"), "multipart/byteranges; boundary="))
+		assert.Equal(t, 0, rw.Body.Len())
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		rw := head("bytes=30-")
+
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rw.Code)
+	})
+}
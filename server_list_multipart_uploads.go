@@ -0,0 +1,145 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MultipartUpload struct {
+	Key          string
+	UploadId     string
+	Initiator    Initiator
+	StorageClass string
+	Initiated    time.Time
+}
+
+type ListMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket             string
+	KeyMarker          string
+	UploadIdMarker     string
+	NextKeyMarker      string `xml:",omitempty"`
+	NextUploadIdMarker string `xml:",omitempty"`
+	Prefix             string
+	Delimiter          string `xml:",omitempty"`
+	MaxUploads         int
+	IsTruncated        bool
+	Upload             []MultipartUpload
+	CommonPrefixes     []CommonPrefixes
+}
+
+// Get implements PolicyContextVars based on parameters set for a list multipart uploads request
+func (r *ListMultipartUploadsResult) Get(k string) (string, bool) {
+	switch k {
+	case "s3:delimiter":
+		return r.Delimiter, true
+	case "s3:prefix":
+		return r.Prefix, true
+	case "s3:max-uploads":
+		return strconv.Itoa(r.MaxUploads), true
+	default:
+		return "", false
+	}
+}
+
+func (s *Server) ListMultipartUploads(ctx *RequestContext) *exception.Error {
+	maxUploads, err := listQueryInt(ctx.Request, "max-uploads", 1000)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var query = ctx.Request.URL.Query()
+	var result = ListMultipartUploadsResult{
+		Bucket:         ctx.Bucket,
+		KeyMarker:      query.Get("key-marker"),
+		UploadIdMarker: query.Get("upload-id-marker"),
+		Prefix:         query.Get("prefix"),
+		Delimiter:      query.Get("delimiter"),
+		MaxUploads:     maxUploads,
+	}
+
+	if err := ctx.CheckAccess(idp.ListBucketMultipartUploads, idp.Resource(ctx.Bucket), &result); err != nil {
+		return err
+	}
+
+	// Uploads are only ever created in writable buckets, other buckets have none.
+	uploads, err := listMultipartUploads(ctx.Filesystem)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var (
+		count          int
+		commonPrefixes = make(map[string]bool)
+	)
+
+	for _, upload := range uploads {
+		// Continue after the marker.
+		// The upload ID marker is only used together with the key marker.
+		if result.KeyMarker != "" {
+			if upload.Key < result.KeyMarker {
+				continue
+			}
+			if upload.Key == result.KeyMarker && (result.UploadIdMarker == "" || upload.UploadId <= result.UploadIdMarker) {
+				continue
+			}
+		}
+
+		if !strings.HasPrefix(upload.Key, result.Prefix) {
+			continue
+		}
+
+		var commonPrefix string
+		if result.Delimiter != "" {
+			if i := strings.Index(upload.Key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				commonPrefix = upload.Key[:len(result.Prefix)+i+len(result.Delimiter)]
+			}
+		}
+
+		// Each common prefix counts once towards the maximum number of uploads
+		if commonPrefix != "" && commonPrefixes[commonPrefix] {
+			result.NextKeyMarker = upload.Key
+			result.NextUploadIdMarker = upload.UploadId
+			continue
+		}
+
+		if count == maxUploads {
+			result.IsTruncated = true
+			break
+		}
+
+		count++
+
+		if commonPrefix != "" {
+			commonPrefixes[commonPrefix] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefixes{Prefix: commonPrefix})
+		} else {
+			result.Upload = append(result.Upload, MultipartUpload{
+				Key:      upload.Key,
+				UploadId: upload.UploadId,
+				Initiator: Initiator{
+					ID:          upload.Initiator,
+					DisplayName: upload.Initiator,
+				},
+				StorageClass: "STANDARD",
+				Initiated:    upload.Initiated,
+			})
+		}
+
+		result.NextKeyMarker = upload.Key
+		result.NextUploadIdMarker = upload.UploadId
+	}
+
+	if !result.IsTruncated {
+		result.NextKeyMarker = ""
+		result.NextUploadIdMarker = ""
+	}
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
@@ -0,0 +1,225 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ObjectVersion struct {
+	ETag         string
+	IsLatest     bool
+	Key          string
+	LastModified time.Time
+	Size         int
+	StorageClass string
+	VersionId    string
+}
+
+type ListVersionsResult struct {
+	XMLName             xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	IsTruncated         bool
+	KeyMarker           string
+	VersionIdMarker     string
+	NextKeyMarker       string `xml:",omitempty"`
+	NextVersionIdMarker string `xml:",omitempty"`
+	Name                string
+	Prefix              string
+	Delimiter           string
+	MaxKeys             int
+	EncodingType        string `xml:",omitempty"`
+	Version             []ObjectVersion
+	CommonPrefixes      []CommonPrefixes
+}
+
+// Get implements PolicyContextVars based on parameters set for a list versions request
+func (r *ListVersionsResult) Get(k string) (string, bool) {
+	switch k {
+	case "s3:delimiter":
+		return r.Delimiter, true
+	case "s3:prefix":
+		return r.Prefix, true
+	case "s3:max-keys":
+		return strconv.Itoa(r.MaxKeys), true
+	default:
+		return "", false
+	}
+}
+
+// bucketVersions returns the current version of the bucket followed by all previous versions, newest first.
+func (s *Server) bucketVersions(ctx *RequestContext) ([]*BucketVersion, error) {
+	var versions = []*BucketVersion{
+		{
+			VersionId: nullVersionId,
+			FS:        ctx.Filesystem,
+		},
+	}
+
+	versioned, ok := s.filesystemProvider.(VersionedBucketFilesystemProvider)
+	if !ok || !versioned.VersioningEnabled(ctx.Bucket) {
+		return versions, nil
+	}
+
+	previous, err := versioned.Versions(ctx.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(versions, previous...), nil
+}
+
+func (s *Server) ListObjectVersions(ctx *RequestContext) *exception.Error {
+	maxKeys, err := listObjectsMaxKeys(ctx.Request)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	objectKeyEncoding, err := listObjectsUrlEncodingType(ctx.Request)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var query = ctx.Request.URL.Query()
+	var result = ListVersionsResult{
+		Name:            ctx.Bucket,
+		Prefix:          query.Get("prefix"),
+		MaxKeys:         maxKeys,
+		Delimiter:       query.Get("delimiter"),
+		KeyMarker:       query.Get("key-marker"),
+		VersionIdMarker: query.Get("version-id-marker"),
+		EncodingType:    objectKeyEncoding,
+	}
+
+	if err := ctx.CheckAccess(idp.ListBucketVersions, idp.Resource(ctx.Bucket), &result); err != nil {
+		return err
+	}
+
+	versions, err := s.bucketVersions(ctx)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var (
+		objects  = make(map[string][]ObjectVersion)
+		prefixes = make(map[string]struct{})
+		// scannedTo is the last key scanned in a version that has more objects than were scanned.
+		// Keys after it are listed by the next request, as they may be missing versions.
+		scannedTo string
+	)
+
+	for i, v := range versions {
+		var it = NewBucketIterator(v.FS)
+		it.UseETagCache(ctx.etags, versionCacheKey(ctx.Bucket, v.VersionId))
+		it.StartAt(result.KeyMarker)
+
+		// Each key has at least one version, so no more than max-keys keys after the key marker are listed from any version
+		contents, err := it.PrefixScan(result.Prefix, result.Delimiter, false, result.MaxKeys+1)
+		if err != nil {
+			return exception.ErrorFrom(err)
+		}
+
+		if it.IsTruncated && (scannedTo == "" || it.Continue < scannedTo) {
+			scannedTo = it.Continue
+		}
+
+		for _, c := range contents {
+			objectVersions := objects[c.Key]
+
+			// An object that is unchanged since the next newest version is the same version of that object
+			if n := len(objectVersions); n > 0 && objectVersions[n-1].Size == c.Size && objectVersions[n-1].LastModified.Equal(c.LastModified) {
+				continue
+			}
+
+			objects[c.Key] = append(objectVersions, ObjectVersion{
+				ETag:         c.ETag,
+				IsLatest:     i == 0,
+				Key:          c.Key,
+				LastModified: c.LastModified,
+				Size:         c.Size,
+				StorageClass: "STANDARD",
+				VersionId:    v.VersionId,
+			})
+		}
+
+		for _, p := range it.CommonPrefixes() {
+			prefixes[p.Prefix] = struct{}{}
+		}
+	}
+
+	var keys = make([]string, 0, len(objects))
+	for k := range objects {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	// If a key marker is given then versions start after the key marker,
+	// or after the version of the key marker given by the version id marker.
+	var skipping = result.KeyMarker != ""
+
+	for _, k := range keys {
+		if scannedTo != "" && k > scannedTo {
+			result.IsTruncated = true
+
+			if n := len(result.Version); n > 0 {
+				result.NextKeyMarker = result.Version[n-1].Key
+				result.NextVersionIdMarker = result.Version[n-1].VersionId
+			}
+			break
+		}
+
+		for _, v := range objects[k] {
+			if skipping {
+				if k < result.KeyMarker || (k == result.KeyMarker && result.VersionIdMarker == "") {
+					continue
+				}
+
+				if k == result.KeyMarker {
+					if v.VersionId == result.VersionIdMarker {
+						skipping = false
+					}
+					continue
+				}
+
+				skipping = false
+			}
+
+			if len(result.Version) >= result.MaxKeys {
+				result.IsTruncated = true
+
+				if n := len(result.Version); n > 0 {
+					result.NextKeyMarker = result.Version[n-1].Key
+					result.NextVersionIdMarker = result.Version[n-1].VersionId
+				}
+				break
+			}
+
+			result.Version = append(result.Version, v)
+		}
+
+		if result.IsTruncated {
+			break
+		}
+	}
+
+	if objectKeyEncoding == "url" {
+		for i := range result.Version {
+			result.Version[i].Key = encodePath(result.Version[i].Key)
+		}
+	}
+
+	for p := range prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefixes{Prefix: p})
+	}
+
+	sort.Slice(result.CommonPrefixes, func(i, j int) bool {
+		return result.CommonPrefixes[i].Prefix < result.CommonPrefixes[j].Prefix
+	})
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
@@ -0,0 +1,196 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/psanford/memfs"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testVersionedServer() *Server {
+	fsys := memfs.New()
+	_ = fsys.MkdirAll("bucket/.zfs/snapshot/daily-1", 0755)
+	_ = fsys.MkdirAll("bucket/.zfs/snapshot/daily-2", 0755)
+
+	_ = fsys.WriteFile("bucket/.zfs/snapshot/daily-1/a.txt", []byte("version 1"), 0644)
+	_ = fsys.WriteFile("bucket/.zfs/snapshot/daily-1/b.txt", []byte("deleted"), 0644)
+	_ = fsys.WriteFile("bucket/.zfs/snapshot/daily-2/a.txt", []byte("version two"), 0644)
+	_ = fsys.WriteFile("bucket/a.txt", []byte("current version"), 0644)
+
+	srv := testServer()
+	srv.filesystemProvider = &SubdirBucketFilesystem{
+		FS:          fsys,
+		SnapshotDir: ".zfs/snapshot",
+	}
+
+	return srv
+}
+
+func TestServer_ListObjectVersions(t *testing.T) {
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "versions=&prefix=a", nil, nil)
+	testVersionedServer().ServeHTTP(rw, req)
+
+	if !assert.Equal(t, http.StatusOK, rw.Code) {
+		return
+	}
+
+	var result ListVersionsResult
+	assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+
+	if assert.Len(t, result.Version, 3) {
+		assert.Equal(t, "a.txt", result.Version[0].Key)
+		assert.Equal(t, "null", result.Version[0].VersionId)
+		assert.True(t, result.Version[0].IsLatest)
+		assert.Equal(t, 15, result.Version[0].Size)
+
+		// Snapshots with the same modification time are ordered by name, newest first
+		assert.Equal(t, snapshotVersionId("daily-2"), result.Version[1].VersionId)
+		assert.False(t, result.Version[1].IsLatest)
+		assert.Equal(t, snapshotVersionId("daily-1"), result.Version[2].VersionId)
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "versions=&prefix=a&max-keys=2", nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		var result ListVersionsResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.True(t, result.IsTruncated)
+		assert.Equal(t, "a.txt", result.NextKeyMarker)
+		assert.Equal(t, snapshotVersionId("daily-2"), result.NextVersionIdMarker)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/",
+			"versions=&prefix=a&key-marker=a.txt&version-id-marker="+result.NextVersionIdMarker, nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		result = ListVersionsResult{}
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.False(t, result.IsTruncated)
+		if assert.Len(t, result.Version, 1) {
+			assert.Equal(t, snapshotVersionId("daily-1"), result.Version[0].VersionId)
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		fsys := memfs.New()
+		_ = fsys.MkdirAll("bucket/.zfs/snapshot/daily-1/d", 0755)
+		_ = fsys.MkdirAll("bucket/d", 0755)
+
+		_ = fsys.WriteFile("bucket/.zfs/snapshot/daily-1/b.txt", []byte("old version of b"), 0644)
+		_ = fsys.WriteFile("bucket/.zfs/snapshot/daily-1/d/e.txt", []byte("old version of e"), 0644)
+		for _, key := range []string{"a.txt", "b.txt", "c.txt", "d/e.txt", "f.txt"} {
+			_ = fsys.WriteFile("bucket/"+key, []byte(key), 0644)
+		}
+
+		srv := testServer()
+		srv.filesystemProvider = &SubdirBucketFilesystem{
+			FS:          fsys,
+			SnapshotDir: ".zfs/snapshot",
+		}
+
+		var (
+			listed []string
+			query  = "versions=&max-keys=2"
+		)
+
+		for i := 0; i < 10; i++ {
+			rw := httptest.NewRecorder()
+			srv.ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", query, nil, nil))
+
+			var result ListVersionsResult
+			assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+			assert.LessOrEqual(t, len(result.Version), 2)
+
+			for _, v := range result.Version {
+				listed = append(listed, v.Key+"@"+v.VersionId)
+			}
+
+			if !result.IsTruncated {
+				break
+			}
+
+			query = "versions=&max-keys=2&key-marker=" + result.NextKeyMarker + "&version-id-marker=" + result.NextVersionIdMarker
+		}
+
+		assert.Equal(t, []string{
+			"a.txt@null",
+			"b.txt@null",
+			"b.txt@" + snapshotVersionId("daily-1"),
+			"c.txt@null",
+			"d/e.txt@null",
+			"d/e.txt@" + snapshotVersionId("daily-1"),
+			"f.txt@null",
+		}, listed)
+	})
+}
+
+func TestSubdirBucketFilesystem_SnapshotDir(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "prefix=.zfs/", nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		var result ListBucketResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Empty(t, result.Contents)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/.zfs/snapshot/daily-1/b.txt", "", nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchKey)
+	})
+}
+
+func TestServer_GetObject_Version(t *testing.T) {
+	t.Run("snapshot", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/b.txt", "versionId="+snapshotVersionId("daily-1"), nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, snapshotVersionId("daily-1"), rw.Header().Get("x-amz-version-id"))
+
+		body, _ := io.ReadAll(rw.Body)
+		assert.Equal(t, "deleted", string(body))
+	})
+
+	t.Run("null", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/a.txt", "versionId=null", nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "null", rw.Header().Get("x-amz-version-id"))
+		assert.Equal(t, "current version", rw.Body.String())
+	})
+
+	t.Run("no_such_version", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/a.txt", "versionId=unknown", nil, nil)
+		testVersionedServer().ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchVersion)
+	})
+}
+
+func TestServer_GetBucketVersioning(t *testing.T) {
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "versioning=", nil, nil)
+	testVersionedServer().ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Status>Enabled</Status>
+</VersioningConfiguration>`, rw.Body.String())
+}
@@ -62,6 +62,7 @@ func (s *Server) ListObjects(ctx *RequestContext) *exception.Error {
 	}
 
 	var it = NewBucketIterator(ctx.Filesystem)
+	it.UseETagCache(ctx.etags, ctx.Bucket)
 
 	if result.Marker != "" {
 		it.Seek(result.Marker)
@@ -65,6 +65,7 @@ func (s *Server) ListObjectsV2(ctx *RequestContext) *exception.Error {
 	}
 
 	var it = NewBucketIterator(ctx.Filesystem)
+	it.UseETagCache(ctx.etags, ctx.Bucket)
 
 	// Seek bucket iterator.
 	// Prefer a continuation token to the request start after
@@ -0,0 +1,131 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Initiator struct {
+	ID          string
+	DisplayName string
+}
+
+type Part struct {
+	PartNumber   int
+	LastModified time.Time
+	ETag         string
+	Size         int64
+}
+
+type ListPartsResult struct {
+	XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string
+	Key                  string
+	UploadId             string
+	Initiator            Initiator
+	StorageClass         string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Part                 []Part
+}
+
+// Get implements PolicyContextVars based on parameters set for a list parts request
+func (r *ListPartsResult) Get(k string) (string, bool) {
+	switch k {
+	case "s3:max-parts":
+		return strconv.Itoa(r.MaxParts), true
+	default:
+		return "", false
+	}
+}
+
+// listQueryInt parses the integer query parameter name of the request, or returns defaultValue if it is not set.
+func listQueryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "Invalid value for " + name,
+		}
+	}
+
+	return i, nil
+}
+
+func (s *Server) ListParts(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	maxParts, err := listQueryInt(ctx.Request, "max-parts", 1000)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	partNumberMarker, err := listQueryInt(ctx.Request, "part-number-marker", 0)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var result = ListPartsResult{
+		Bucket:           ctx.Bucket,
+		Key:              key,
+		UploadId:         ctx.Request.URL.Query().Get("uploadId"),
+		StorageClass:     "STANDARD",
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+	}
+
+	if err := ctx.CheckAccess(idp.ListMultipartUploadParts, idp.Resource(ctx.Bucket+"/"+key), &result); err != nil {
+		return err
+	}
+
+	upload, err := readMultipartUpload(ctx.Filesystem, result.UploadId, key)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	result.Initiator = Initiator{
+		ID:          upload.Initiator,
+		DisplayName: upload.Initiator,
+	}
+
+	parts, err := listMultipartParts(ctx.Filesystem, upload.UploadId)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	for _, part := range parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+
+		if len(result.Part) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+
+		result.Part = append(result.Part, Part{
+			PartNumber:   part.PartNumber,
+			LastModified: part.LastModified,
+			ETag:         strconv.Quote(part.ETag),
+			Size:         part.Size,
+		})
+		result.NextPartNumberMarker = part.PartNumber
+	}
+
+	ctx.SendXML(http.StatusOK, &result)
+	return nil
+}
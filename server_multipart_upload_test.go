@@ -0,0 +1,289 @@
+package ls3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testCreateMultipartUpload creates a new multipart upload of key and returns its upload ID.
+func testCreateMultipartUpload(t *testing.T, srv *Server, key string) string {
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/"+key, "uploads", http.Header{
+		"Content-Type": []string{"text/plain"},
+	}, nil)
+	srv.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	var result InitiateMultipartUploadResult
+	assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+	assert.Equal(t, key, result.Key)
+
+	return result.UploadId
+}
+
+// testUploadPart uploads a part of a multipart upload and returns its ETag.
+func testUploadPart(t *testing.T, srv *Server, key, uploadId string, partNumber int, data []byte) string {
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/"+key, fmt.Sprintf("partNumber=%d&uploadId=%s", partNumber, uploadId), nil, data)
+	srv.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	return rw.Header().Get("ETag")
+}
+
+func testCompleteMultipartUpload(srv *Server, key, uploadId string, parts ...CompletedPart) *httptest.ResponseRecorder {
+	body, _ := xml.Marshal(&CompleteMultipartUpload{Part: parts})
+
+	rw := httptest.NewRecorder()
+	req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/"+key, "uploadId="+uploadId, nil, body)
+	srv.ServeHTTP(rw, req)
+
+	return rw
+}
+
+func TestServer_MultipartUpload(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testWritableServer(dir)
+
+		var (
+			part1 = bytes.Repeat([]byte{'a'}, minPartSize)
+			part2 = []byte("Hello, World!")
+		)
+
+		uploadId := testCreateMultipartUpload(t, srv, "dir/object.txt")
+		etag1 := testUploadPart(t, srv, "dir/object.txt", uploadId, 1, part1)
+		etag2 := testUploadPart(t, srv, "dir/object.txt", uploadId, 2, part2)
+
+		// Staged parts are not visible in the bucket
+		assertDirEntries(t, dir, ".ls3uploads")
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/dir/object.txt", "uploadId="+uploadId, nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var parts ListPartsResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &parts))
+		if assert.Len(t, parts.Part, 2) {
+			assert.Equal(t, etag1, parts.Part[0].ETag)
+			assert.Equal(t, int64(minPartSize), parts.Part[0].Size)
+			assert.Equal(t, etag2, parts.Part[1].ETag)
+		}
+
+		rw = testCompleteMultipartUpload(srv, "dir/object.txt", uploadId,
+			CompletedPart{PartNumber: 1, ETag: etag1},
+			CompletedPart{PartNumber: 2, ETag: etag2},
+		)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		sum1, sum2 := md5.Sum(part1), md5.Sum(part2)
+		expectETag := md5.Sum(append(sum1[:], sum2[:]...))
+
+		var result CompleteMultipartUploadResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Equal(t, strconv.Quote(hex.EncodeToString(expectETag[:])+"-2"), result.ETag)
+
+		b, err := os.ReadFile(filepath.Join(dir, "dir", "object.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, append(part1, part2...), b)
+		assertDirEntries(t, dir, "dir")
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodHead, "/bucket/dir/object.txt", "", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, result.ETag, rw.Header().Get("ETag"))
+		assert.Equal(t, "text/plain", rw.Header().Get("Content-Type"))
+
+		// The ETag is stored with the object, so it does not depend on the ETag cache
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodHead, "/bucket/dir/object.txt", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, result.ETag, rw.Header().Get("ETag"))
+	})
+
+	t.Run("replace part", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testWritableServer(dir)
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("old"))
+		etag := testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("new"))
+
+		rw := testCompleteMultipartUpload(srv, "object.txt", uploadId, CompletedPart{PartNumber: 1, ETag: etag})
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "object.txt"))
+		assert.Equal(t, "new", string(b))
+	})
+
+	t.Run("invalid part order", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		etag1 := testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("one"))
+		etag2 := testUploadPart(t, srv, "object.txt", uploadId, 2, []byte("two"))
+
+		rw := testCompleteMultipartUpload(srv, "object.txt", uploadId,
+			CompletedPart{PartNumber: 2, ETag: etag2},
+			CompletedPart{PartNumber: 1, ETag: etag1},
+		)
+		AssertIsResponseError(t, rw, exception.InvalidPartOrder)
+	})
+
+	t.Run("invalid part", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("one"))
+
+		rw := testCompleteMultipartUpload(srv, "object.txt", uploadId, CompletedPart{PartNumber: 1, ETag: `"d41d8cd98f00b204e9800998ecf8427e"`})
+		AssertIsResponseError(t, rw, exception.InvalidPart)
+	})
+
+	t.Run("invalid part etag", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("one"))
+
+		rw := testCompleteMultipartUpload(srv, "object.txt", uploadId, CompletedPart{PartNumber: 1, ETag: `"../../../object"`})
+		AssertIsResponseError(t, rw, exception.InvalidPart)
+	})
+
+	t.Run("entity too small", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		etag1 := testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("one"))
+		etag2 := testUploadPart(t, srv, "object.txt", uploadId, 2, []byte("two"))
+
+		rw := testCompleteMultipartUpload(srv, "object.txt", uploadId,
+			CompletedPart{PartNumber: 1, ETag: etag1},
+			CompletedPart{PartNumber: 2, ETag: etag2},
+		)
+		AssertIsResponseError(t, rw, exception.EntityTooSmall)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testWritableServer(dir)
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+		testUploadPart(t, srv, "object.txt", uploadId, 1, []byte("one"))
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodDelete, "/bucket/object.txt", "uploadId="+uploadId, nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+		assertDirEntries(t, dir)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "partNumber=2&uploadId="+uploadId, nil, []byte("two"))
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchUpload)
+	})
+
+	t.Run("no such upload", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "partNumber=1&uploadId=../../etc", nil, []byte("one"))
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchUpload)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		srv := testWritableServer(t.TempDir())
+
+		uploadId := testCreateMultipartUpload(t, srv, "object.txt")
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/other.txt", "partNumber=1&uploadId="+uploadId, nil, []byte("one"))
+		srv.ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchUpload)
+	})
+}
+
+func TestServer_ListMultipartUploads(t *testing.T) {
+	srv := testWritableServer(t.TempDir())
+
+	testCreateMultipartUpload(t, srv, "a/one.txt")
+	testCreateMultipartUpload(t, srv, "a/two.txt")
+	uploadId := testCreateMultipartUpload(t, srv, "b.txt")
+
+	t.Run("all", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "uploads", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var result ListMultipartUploadsResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		if assert.Len(t, result.Upload, 3) {
+			assert.Equal(t, "a/one.txt", result.Upload[0].Key)
+			assert.Equal(t, "a/two.txt", result.Upload[1].Key)
+			assert.Equal(t, "b.txt", result.Upload[2].Key)
+			assert.Equal(t, uploadId, result.Upload[2].UploadId)
+		}
+	})
+
+	t.Run("delimiter", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "uploads&delimiter=/", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		var result ListMultipartUploadsResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Equal(t, []CommonPrefixes{{Prefix: "a/"}}, result.CommonPrefixes)
+		if assert.Len(t, result.Upload, 1) {
+			assert.Equal(t, "b.txt", result.Upload[0].Key)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "uploads&max-uploads=2", nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		var result ListMultipartUploadsResult
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.True(t, result.IsTruncated)
+		assert.Len(t, result.Upload, 2)
+		assert.Equal(t, "a/two.txt", result.NextKeyMarker)
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "uploads&max-uploads=2&key-marker="+result.NextKeyMarker+"&upload-id-marker="+result.NextUploadIdMarker, nil, nil)
+		srv.ServeHTTP(rw, req)
+
+		result = ListMultipartUploadsResult{}
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &result))
+		assert.False(t, result.IsTruncated)
+		if assert.Len(t, result.Upload, 1) {
+			assert.Equal(t, "b.txt", result.Upload[0].Key)
+		}
+	})
+}
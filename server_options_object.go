@@ -0,0 +1,78 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"net/http"
+	"strings"
+)
+
+// OptionsObject answers a CORS preflight request using the CORS configuration of the bucket.
+// Preflight requests are never signed, so this is handled before the request is verified.
+func (s *Server) OptionsObject(ctx *RequestContext) *exception.Error {
+	var (
+		origin = ctx.Request.Header.Get("Origin")
+		method = ctx.Request.Header.Get("Access-Control-Request-Method")
+	)
+
+	if origin == "" || method == "" {
+		return &exception.Error{
+			ErrorCode: exception.BadRequest,
+			Message:   "Insufficient information. Origin and Access-Control-Request-Method request headers needed.",
+		}
+	}
+
+	bucket, ok, err := bucketFromRequest(ctx.Request, s.domain)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var errForbidden = &exception.Error{
+		ErrorCode: exception.AccessForbidden,
+		Message:   "CORSResponse: This CORS request is not allowed. This is usually because the evaluation of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+	}
+
+	if !ok {
+		return errForbidden
+	}
+
+	fsys, err := s.filesystemProvider.Open(bucket)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	config, err := readBucketCors(fsys)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if config == nil {
+		return &exception.Error{
+			ErrorCode: exception.AccessForbidden,
+			Message:   "CORSResponse: CORS is not enabled for this bucket.",
+		}
+	}
+
+	var headers []string
+	for _, header := range strings.Split(ctx.Request.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	rule := config.Match(origin, method, headers)
+	if rule == nil {
+		return errForbidden
+	}
+
+	header := ctx.Header()
+	setCorsHeaders(header, rule, origin)
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Add("Vary", "Access-Control-Request-Method")
+
+	if len(headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+
+	ctx.SendPlain(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,348 @@
+package ls3
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPostObjectFieldsSize is the maximum total size of the form fields before the file of a POST Object request.
+const maxPostObjectFieldsSize = 20 * 1024
+
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// isPostObjectRequest returns true if the request is a browser based upload using POST Object.
+func isPostObjectRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+var errMalformedPostRequest = &exception.Error{
+	ErrorCode: exception.MalformedPOSTRequest,
+	Message:   "The body of your POST request is not well-formed multipart/form-data.",
+}
+
+// readPostObjectForm reads the form fields of a POST Object request up to the file field.
+// Field names are case-insensitive, so they are returned in lower case. Any fields after the file are ignored.
+func readPostObjectForm(r *http.Request) (map[string]string, *multipart.Part, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, nil, errMalformedPostRequest
+	}
+
+	var (
+		mr        = multipart.NewReader(r.Body, params["boundary"])
+		fields    = make(map[string]string)
+		remaining = int64(maxPostObjectFieldsSize)
+	)
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, nil, &exception.Error{
+				ErrorCode: exception.InvalidArgument,
+				Message:   "POST requires exactly one file upload per request.",
+			}
+		}
+		if err != nil {
+			return nil, nil, errMalformedPostRequest
+		}
+
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			return fields, part, nil
+		}
+
+		b, err := io.ReadAll(io.LimitReader(part, remaining+1))
+		if err != nil {
+			return nil, nil, errMalformedPostRequest
+		}
+
+		remaining -= int64(len(b))
+		if remaining < 0 {
+			return nil, nil, &exception.Error{
+				ErrorCode: exception.MaxMessageLengthExceeded,
+				Message:   "Your POST request fields exceed the maximum allowed size.",
+			}
+		}
+
+		fields[name] = string(b)
+	}
+}
+
+// verifyPostObjectPolicy verifies the signature of the policy document of a POST Object request,
+// and returns the identity that signed it along with the policy.
+// The policy is signed using the same signing key as a request signed using Signature Version 4,
+// so its credential scope must be valid for the region of the bucket.
+func (s *Server) verifyPostObjectPolicy(ctx *RequestContext, fields map[string]string) (*idp.Identity, *PostPolicy, error) {
+	if algorithm := fields["x-amz-algorithm"]; algorithm != awsSignatureVersionV4 {
+		return nil, nil, &exception.Error{
+			ErrorCode: exception.InvalidRequest,
+			Message:   "The request is using the wrong signature version. Use AWS4-HMAC-SHA256 (Signature Version 4).",
+		}
+	}
+
+	credential, err := ParseCredential(fields["x-amz-credential"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The date of the credential scope must match the x-amz-date field, if it is set
+	var at = credential.Date
+	if date, ok := fields["x-amz-date"]; ok {
+		at, err = ParseAmzTime(date)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = checkCredentialScope(*credential, at, exception.InvalidArgument)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.checkSignedScope(ctx, *credential); err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := idp.GetIdentity(s.identities, credential.AccessKeyID, fields["x-amz-security-token"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signingKey := SignAWSV4{}.computeSigningKey(credential.Date, credential.Region, credential.Service, identity)
+
+	// We can ignore any errors or length to the request signature.
+	// An invalid signature will not match the computed signature
+	requestSignature, _ := hex.DecodeString(fields["x-amz-signature"])
+
+	if subtle.ConstantTimeCompare(sumHmacSha256(signingKey, []byte(fields["policy"])), requestSignature) != 1 {
+		return nil, nil, &exception.Error{
+			ErrorCode: exception.SignatureDoesNotMatch,
+			Message:   "The request signature that the server calculated does not match the signature that you provided.",
+		}
+	}
+
+	document, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return nil, nil, &exception.Error{
+			ErrorCode: exception.InvalidPolicyDocument,
+			Message:   "Invalid Policy: Policy could not be decoded.",
+		}
+	}
+
+	policy, err := ParsePostPolicy(document)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !time.Now().Before(policy.Expiration) {
+		return nil, nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "Invalid according to Policy: Policy expired.",
+		}
+	}
+
+	return identity, policy, nil
+}
+
+// contentLengthRangeReader returns an error if the length of the content read from the reader is outside a range.
+type contentLengthRangeReader struct {
+	io.Reader
+	min int64
+	max int64
+	n   int64
+}
+
+func (r *contentLengthRangeReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += int64(n)
+
+	if r.n > r.max {
+		return n, &exception.Error{
+			ErrorCode: exception.EntityTooLarge,
+			Message:   "Your proposed upload exceeds the maximum allowed size.",
+		}
+	}
+
+	if err == io.EOF && r.n < r.min {
+		return n, &exception.Error{
+			ErrorCode: exception.EntityTooSmall,
+			Message:   "Your proposed upload is smaller than the minimum allowed size.",
+		}
+	}
+
+	return n, err
+}
+
+// postObjectRedirect returns the URL to redirect to after a successful upload, if success_action_redirect is valid.
+func postObjectRedirect(redirect string, bucket, key, etag string) (string, bool) {
+	if redirect == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(redirect)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+
+	query := u.Query()
+	query.Set("bucket", bucket)
+	query.Set("key", key)
+	query.Set("etag", strconv.Quote(etag))
+	u.RawQuery = query.Encode()
+
+	return u.String(), true
+}
+
+// PostObject uploads an object using a browser based HTML form.
+// If the form contains a policy document, the upload uses the identity that signed the policy,
+// and the form fields must satisfy the conditions of the policy.
+func (s *Server) PostObject(ctx *RequestContext) *exception.Error {
+	fields, file, err := readPostObjectForm(ctx.Request)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var policy *PostPolicy
+	if _, ok := fields["policy"]; ok {
+		var identity *idp.Identity
+		identity, policy, err = s.verifyPostObjectPolicy(ctx, fields)
+		if err != nil {
+			return exception.ErrorFrom(err)
+		}
+
+		ctx.Identity = identity
+		ctx.Logger = ctx.Logger.With(
+			zap.String("identity", ctx.Identity.Name),
+		)
+	} else if _, ok := fields["x-amz-signature"]; ok {
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "Bucket POST must contain a field named 'policy'. If it is specified, please check the order of the fields.",
+		}
+	}
+
+	key, ok := fields["key"]
+	if !ok {
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "Bucket POST must contain a field named 'key'. If it is specified, please check the order of the fields.",
+		}
+	}
+
+	// ${filename} is replaced by the name of the uploaded file
+	fields["key"] = strings.ReplaceAll(key, "${filename}", path.Base("/"+strings.ReplaceAll(file.FileName(), "\\", "/")))
+
+	// The key must be checked against the policy exactly as it is written
+	if strings.HasSuffix(fields["key"], "/") {
+		return errInvalidObjectKey
+	}
+
+	key, ok = cleanObjectKey(fields["key"])
+	if !ok {
+		return errInvalidObjectKey
+	}
+
+	if policy != nil {
+		if err := policy.Check(ctx.Bucket, fields); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	if !isWritableKey(key) {
+		return errInvalidObjectKey
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	// Form fields are used in place of the headers of PutObject
+	var header = make(http.Header)
+	for k, v := range fields {
+		header.Set(k, v)
+	}
+
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", file.Header.Get("Content-Type"))
+	}
+
+	expectMD5, err := contentMD5(header)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	var body io.Reader = file
+	if policy != nil {
+		if minLength, maxLength, ok := policy.ContentLengthRange(); ok {
+			body = &contentLengthRangeReader{Reader: file, min: minLength, max: maxLength}
+		}
+	}
+
+	etag, bytesReceived, err := s.writeObject(ctx, wfs, key, body, expectMD5, metadataFromHeader(header))
+
+	// Update statistics
+	statBytesTransferredIn.WithLabelValues(ctx.Bucket, key, ctx.Identity.Name, ctx.RemoteIP.String()).Add(float64(bytesReceived))
+
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	var scheme = "http"
+	if ctx.Secure {
+		scheme = "https"
+	}
+
+	requestPath, _, _ := strings.Cut(ctx.Request.RequestURI, "?")
+	location := scheme + "://" + ctx.Request.Host + strings.TrimSuffix(requestPath, "/") + "/" + key
+
+	ctx.Header().Set("ETag", strconv.Quote(etag))
+	ctx.Header().Set("Location", location)
+
+	if redirect, ok := postObjectRedirect(fields["success_action_redirect"], ctx.Bucket, key, etag); ok {
+		ctx.Header().Set("Location", redirect)
+		ctx.SendPlain(http.StatusSeeOther)
+		return nil
+	}
+
+	switch fields["success_action_status"] {
+	case "200":
+		ctx.SendPlain(http.StatusOK)
+	case "201":
+		ctx.SendXML(http.StatusCreated, &PostResponse{
+			Location: location,
+			Bucket:   ctx.Bucket,
+			Key:      key,
+			ETag:     strconv.Quote(etag),
+		})
+	default:
+		ctx.SendPlain(http.StatusNoContent)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPostPolicy returns the base64 encoded policy document with the given conditions,
+// and the form fields that sign it with idp.TestIdentity.
+func testPostPolicy(expiration time.Time, conditions string) map[string]string {
+	var (
+		date       = time.Now().UTC()
+		credential = fmt.Sprintf("%s/%s/us-east-1/s3/aws4_request", idp.TestIdentity.AccessKeyId, date.Format(amzDateFormat))
+		policy     = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"expiration": %q, "conditions": [
+			{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+			{"x-amz-credential": %q},
+			%s
+		]}`, expiration.UTC().Format(time.RFC3339), credential, conditions)))
+		signingKey = SignAWSV4{}.computeSigningKey(date, "us-east-1", "s3", idp.TestIdentity)
+	)
+
+	return map[string]string{
+		"X-Amz-Algorithm":  "AWS4-HMAC-SHA256",
+		"X-Amz-Credential": credential,
+		"Policy":           policy,
+		"X-Amz-Signature":  hex.EncodeToString(sumHmacSha256(signingKey, []byte(policy))),
+	}
+}
+
+// testPostObjectRequest returns a POST Object request for bucket with the given form fields and file content.
+func testPostObjectRequest(fields map[string]string, content string) *http.Request {
+	var b bytes.Buffer
+
+	w := multipart.NewWriter(&b)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+
+	file, _ := w.CreateFormFile("file", "upload.txt")
+	_, _ = file.Write([]byte(content))
+	_ = w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "http://bucket.testing/bucket", &b)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	return req
+}
+
+func TestServer_PostObject(t *testing.T) {
+	const conditions = `
+		{"bucket": "bucket"},
+		["starts-with", "$key", "uploads/"],
+		["starts-with", "$Content-Type", "text/"],
+		{"success_action_status": "201"},
+		["content-length-range", 1, 16]`
+
+	post := func(dir string, fields map[string]string, content string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		testWritableServer(dir).ServeHTTP(rw, testPostObjectRequest(fields, content))
+		return rw
+	}
+
+	withFields := func(fields map[string]string, extra map[string]string) map[string]string {
+		for k, v := range extra {
+			fields[k] = v
+		}
+		return fields
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := post(dir, withFields(testPostPolicy(time.Now().Add(time.Hour), conditions), map[string]string{
+			"key":                   "uploads/${filename}",
+			"Content-Type":          "text/plain",
+			"success_action_status": "201",
+		}), "content")
+
+		if !assert.Equal(t, http.StatusCreated, rw.Code, rw.Body.String()) {
+			return
+		}
+
+		var response PostResponse
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &response))
+		assert.Equal(t, "bucket", response.Bucket)
+		assert.Equal(t, "uploads/upload.txt", response.Key)
+		assert.Equal(t, `"9a0364b9e99bb480dd25e1f0284c8555"`, response.ETag)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "uploads", "upload.txt"))
+		assert.Equal(t, "content", string(b))
+
+		meta, _ := os.ReadFile(filepath.Join(dir, "uploads", metadataSidecarDir, "upload.txt.json"))
+		assert.Contains(t, string(meta), "text/plain")
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rw := post(t.TempDir(), withFields(testPostPolicy(time.Now().Add(time.Hour), `
+			{"key": "object.txt"},
+			["starts-with", "$success_action_redirect", "https://example.com/"]`), map[string]string{
+			"key":                     "object.txt",
+			"success_action_redirect": "https://example.com/uploaded",
+		}), "content")
+
+		if !assert.Equal(t, http.StatusSeeOther, rw.Code, rw.Body.String()) {
+			return
+		}
+
+		location, _ := url.Parse(rw.Header().Get("Location"))
+		assert.Equal(t, "example.com", location.Host)
+		assert.Equal(t, "object.txt", location.Query().Get("key"))
+		assert.Equal(t, `"9a0364b9e99bb480dd25e1f0284c8555"`, location.Query().Get("etag"))
+	})
+
+	t.Run("key condition", func(t *testing.T) {
+		rw := post(t.TempDir(), withFields(testPostPolicy(time.Now().Add(time.Hour), conditions), map[string]string{
+			"key":                   "other/object.txt",
+			"Content-Type":          "text/plain",
+			"success_action_status": "201",
+		}), "content")
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("key traversal", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := post(dir, withFields(testPostPolicy(time.Now().Add(time.Hour), conditions), map[string]string{
+			"key":                   "uploads/../index.html",
+			"Content-Type":          "text/plain",
+			"success_action_status": "201",
+		}), "content")
+
+		AssertIsResponseError(t, rw, exception.InvalidArgument)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("extra field", func(t *testing.T) {
+		rw := post(t.TempDir(), withFields(testPostPolicy(time.Now().Add(time.Hour), conditions), map[string]string{
+			"key":                   "uploads/object.txt",
+			"Content-Type":          "text/plain",
+			"success_action_status": "201",
+			"x-amz-meta-uncovered":  "value",
+		}), "content")
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("content length range", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := post(dir, withFields(testPostPolicy(time.Now().Add(time.Hour), conditions), map[string]string{
+			"key":                   "uploads/object.txt",
+			"Content-Type":          "text/plain",
+			"success_action_status": "201",
+		}), "content that is too large")
+
+		AssertIsResponseError(t, rw, exception.EntityTooLarge)
+
+		_, err := os.Stat(filepath.Join(dir, "uploads", "object.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		rw := post(t.TempDir(), withFields(testPostPolicy(time.Now().Add(-time.Hour), `{"key": "object.txt"}`), map[string]string{
+			"key": "object.txt",
+		}), "content")
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("credential scope", func(t *testing.T) {
+		fields := testPostPolicy(time.Now().Add(time.Hour), `{"key": "object.txt"}`)
+		fields["X-Amz-Credential"] = strings.Replace(fields["X-Amz-Credential"], "/s3/", "/sts/", 1)
+		fields["key"] = "object.txt"
+
+		AssertIsResponseError(t, post(t.TempDir(), fields, "content"), exception.AuthorizationHeaderMalformed)
+	})
+
+	t.Run("wrong region", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, bucketRegionFile), []byte(`{"Region": "eu-west-1"}`), 0644)
+
+		fields := testPostPolicy(time.Now().Add(time.Hour), `{"key": "object.txt"}`)
+		fields["key"] = "object.txt"
+
+		AssertIsResponseError(t, post(dir, fields, "content"), exception.AuthorizationHeaderMalformed)
+		assertDirEntries(t, dir, bucketRegionFile)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		fields := testPostPolicy(time.Now().Add(time.Hour), `{"key": "object.txt"}`)
+		fields["X-Amz-Signature"] = hex.EncodeToString(make([]byte, 32))
+		fields["key"] = "object.txt"
+
+		AssertIsResponseError(t, post(t.TempDir(), fields, "content"), exception.SignatureDoesNotMatch)
+	})
+}
+
+func TestParsePostPolicy(t *testing.T) {
+	policy, err := ParsePostPolicy([]byte(`{
+		"expiration": "2007-12-01T12:00:00.000Z",
+		"conditions": [
+			{"acl": "public-read"},
+			["eq", "$Content-Type", "image/jpeg"],
+			["starts-with", "$key", "user/eric/"],
+			["content-length-range", 1048576, 10485760]
+		]
+	}`))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, time.Date(2007, 12, 1, 12, 0, 0, 0, time.UTC), policy.Expiration)
+	assert.Equal(t, []*PostPolicyCondition{
+		{Operator: "eq", Field: "acl", Value: "public-read"},
+		{Operator: "eq", Field: "content-type", Value: "image/jpeg"},
+		{Operator: "starts-with", Field: "key", Value: "user/eric/"},
+		{Operator: "content-length-range", Min: 1048576, Max: 10485760},
+	}, policy.Conditions)
+
+	_, err = ParsePostPolicy([]byte(`{"expiration": "2007-12-01T12:00:00.000Z", "conditions": [["not-an-operator", "$key", ""]]}`))
+	assert.Equal(t, exception.InvalidPolicyDocument, err.(*exception.Error).ErrorCode)
+}
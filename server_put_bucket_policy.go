@@ -0,0 +1,39 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+)
+
+// maxBucketPolicySize is the maximum size of a bucket policy.
+const maxBucketPolicySize = 20 * 1024
+
+func (s *Server) PutBucketPolicy(ctx *RequestContext) *exception.Error {
+	if err := ctx.CheckAccess(idp.PutBucketPolicy, idp.Resource(ctx.Bucket), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	b, rerr := ctx.ReadBody(maxBucketPolicySize)
+	if rerr != nil {
+		return rerr
+	}
+
+	policy, err := parseBucketPolicy(b, ctx.Bucket)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	wfs, werr := s.openBucketConfig(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	err = writeBucketConfig(wfs, bucketPolicyFile, policy)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.SendPlain(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,179 @@
+package ls3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// contentMD5 returns the decoded value of the Content-MD5 header of the request, or nil if not set.
+func contentMD5(header http.Header) ([]byte, error) {
+	value := header.Get("Content-MD5")
+	if value == "" {
+		return nil, nil
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != md5.Size {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidDigest,
+			Message:   "The Content-MD5 you specified is not valid.",
+		}
+	}
+
+	return sum, nil
+}
+
+// unwrapWriteError returns the error that occurred while writing an object.
+// Errors from reading the request body are returned as-is.
+func unwrapWriteError(err error) *exception.Error {
+	var known *exception.Error
+	if errors.As(err, &known) {
+		return known
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &exception.Error{
+			ErrorCode: exception.IncompleteBody,
+			Message:   "You did not provide the number of bytes specified by the Content-Length HTTP header.",
+		}
+	}
+
+	return unwrapFsError(err)
+}
+
+// isWritableKey returns true if an object can be written to key.
+func isWritableKey(key string) bool {
+	return key != "" && key != "." && !isReservedPath(key)
+}
+
+var errInvalidObjectKey = &exception.Error{
+	ErrorCode: exception.InvalidArgument,
+	Message:   "The specified object key is not valid.",
+}
+
+// openWritable returns the writable filesystem of the bucket in the request.
+func (s *Server) openWritable(ctx *RequestContext) (WritableFS, *exception.Error) {
+	provider, ok := s.filesystemProvider.(WritableBucketFilesystemProvider)
+	if !ok {
+		return nil, errBucketNotWritable
+	}
+
+	wfs, err := provider.OpenWritable(ctx.Bucket)
+	if err != nil {
+		return nil, exception.ErrorFrom(err)
+	}
+
+	return wfs, nil
+}
+
+// writeObject writes the object key from r, and returns the ETag of the new object.
+// If expectMD5 is not nil, the object is only written if the MD5 sum of r matches.
+func (s *Server) writeObject(ctx *RequestContext, wfs WritableFS, key string, r io.Reader, expectMD5 []byte, meta *ObjectMetadata) (string, int64, error) {
+	h := md5.New()
+
+	tempName, n, err := writeTemp(wfs, path.Dir(key), io.TeeReader(r, h), func() error {
+		if expectMD5 != nil && !bytes.Equal(h.Sum(nil), expectMD5) {
+			return &exception.Error{
+				ErrorCode: exception.BadDigest,
+				Message:   "The Content-MD5 you specified did not match what we received.",
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", n, err
+	}
+
+	fi, err := replaceObject(wfs, tempName, key, meta, "")
+	if err != nil {
+		return "", n, err
+	}
+
+	etag := hex.EncodeToString(h.Sum(nil))
+
+	// Prime the ETag cache so that the new object does not need to be read again
+	ctx.etags.Store(ctx.Bucket+"/"+key, fi, etag)
+
+	return etag, n, nil
+}
+
+func (s *Server) PutObject(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	if !isWritableKey(key) {
+		return errInvalidObjectKey
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	expectMD5, err := contentMD5(ctx.Request.Header)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	// A key that ends with a slash is a directory
+	if strings.HasSuffix(ctx.Request.URL.Path, "/") {
+		// The body is read, even if it has no declared length, so that its checksums are verified
+		n, err := io.Copy(io.Discard, io.LimitReader(ctx.Request.Body, 1))
+		if err != nil {
+			return unwrapWriteError(err)
+		}
+
+		if n > 0 || ctx.Request.ContentLength > 0 {
+			return &exception.Error{
+				ErrorCode: exception.InvalidRequest,
+				Message:   "A directory object must not have any content.",
+			}
+		}
+
+		if expectMD5 != nil && !bytes.Equal(md5.New().Sum(nil), expectMD5) {
+			return &exception.Error{
+				ErrorCode: exception.BadDigest,
+				Message:   "The Content-MD5 you specified did not match what we received.",
+			}
+		}
+
+		// The directory marker keeps the directory when the last object it contains is deleted
+		_, err = writeAtomic(wfs, path.Join(key, directoryMarkerName), bytes.NewReader(nil), nil)
+		if err != nil {
+			return unwrapWriteError(err)
+		}
+
+		ctx.Header().Set("ETag", strconv.Quote(hex.EncodeToString(md5.New().Sum(nil))))
+		ctx.SendPlain(http.StatusOK)
+		return nil
+	}
+
+	etag, bytesReceived, err := s.writeObject(ctx, wfs, key, ctx.Request.Body, expectMD5, metadataFromHeader(ctx.Request.Header))
+
+	// Update statistics
+	statBytesTransferredIn.WithLabelValues(ctx.Bucket, key, ctx.Identity.Name, ctx.RemoteIP.String()).Add(float64(bytesReceived))
+
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.Header().Set("ETag", strconv.Quote(etag))
+	ctx.SendPlain(http.StatusOK)
+	return nil
+}
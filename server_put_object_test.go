@@ -0,0 +1,273 @@
+package ls3
+
+import (
+	"bytes"
+	"github.com/psanford/memfs"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testWritableServer is like testServer, but serves a writable filesystem in the given directory for any bucket.
+func testWritableServer(dir string) *Server {
+	srv := testServer()
+	srv.filesystemProvider = &SingleBucketFilesystem{
+		FS:       DirFS(dir),
+		Writable: true,
+	}
+
+	return srv
+}
+
+// testSnapshotServer returns a server for the writable bucket "bucket" in dir, with snapshots in bucket/.snapshots.
+func testSnapshotServer(dir string) *Server {
+	_ = os.MkdirAll(filepath.Join(dir, "bucket", ".snapshots", "daily"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "bucket", ".snapshots", "daily", "object.txt"), []byte("old"), 0644)
+
+	srv := testServer()
+	srv.filesystemProvider = &SubdirBucketFilesystem{
+		FS:          DirFS(dir),
+		SnapshotDir: ".snapshots",
+		Writable:    []string{"*"},
+	}
+
+	return srv
+}
+
+// assertDirEntries asserts that the directory contains exactly the given names.
+func assertDirEntries(t *testing.T, dir string, names ...string) {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+
+	var actual = make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actual = append(actual, entry.Name())
+	}
+
+	assert.ElementsMatch(t, names, actual)
+}
+
+func TestServer_PutObject(t *testing.T) {
+	t.Run("put", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/dir/object.txt", "", http.Header{
+			"Content-Md5":       []string{"ZajifYh5KDgxtmS9i38K1A=="},
+			"Content-Type":      []string{"text/plain"},
+			"X-Amz-Meta-Author": []string{"ls3"},
+		}, []byte("Hello, World!"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, `"65a8e27d8879283831b664bd8b7f0ad4"`, rw.Header().Get("ETag"))
+
+		b, err := os.ReadFile(filepath.Join(dir, "dir", "object.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello, World!", string(b))
+		assertDirEntries(t, filepath.Join(dir, "dir"), "object.txt", ".ls3meta")
+
+		rw = httptest.NewRecorder()
+		req = testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/dir/object.txt", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "text/plain", rw.Header().Get("Content-Type"))
+		assert.Equal(t, "ls3", rw.Header().Get("x-amz-meta-author"))
+		assert.Equal(t, "Hello, World!", rw.Body.String())
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("old"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", nil, []byte("new"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "object.txt"))
+		assert.Equal(t, "new", string(b))
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("guessed content type", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", http.Header{
+			"Content-Type": []string{"binary/octet-stream"},
+		}, []byte("Hello, World!"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("default content type", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", http.Header{
+			"Content-Type": []string{"application/octet-stream"},
+		}, []byte("Hello, World!"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assertDirEntries(t, dir, "object.txt")
+	})
+
+	t.Run("replace failed", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "folder", "child"), 0755)
+		_ = os.MkdirAll(filepath.Join(dir, ".ls3meta"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, ".ls3meta", "folder.json"), []byte(`{"Content-Type": "text/plain"}`), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/folder", "", http.Header{
+			"Content-Type": []string{"text/html"},
+		}, []byte("new"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.NotEqual(t, http.StatusOK, rw.Code)
+		assertDirEntries(t, dir, "folder", ".ls3meta")
+
+		b, _ := os.ReadFile(filepath.Join(dir, ".ls3meta", "folder.json"))
+		assert.Equal(t, `{"Content-Type": "text/plain"}`, string(b))
+	})
+
+	t.Run("sub-resource", func(t *testing.T) {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "object.txt"), []byte("object"), 0644)
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "tagging=", nil, []byte(`<Tagging><TagSet></TagSet></Tagging>`))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "object.txt"))
+		assert.Equal(t, "object", string(b))
+	})
+
+	t.Run("object query", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "x-id=PutObject", nil, []byte("new"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		b, _ := os.ReadFile(filepath.Join(dir, "object.txt"))
+		assert.Equal(t, "new", string(b))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/folder/", "", nil, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		fi, err := os.Stat(filepath.Join(dir, "folder"))
+		if assert.NoError(t, err) {
+			assert.True(t, fi.IsDir())
+		}
+
+		assertDirEntries(t, filepath.Join(dir, "folder"), directoryMarkerName)
+	})
+
+	t.Run("directory with content", func(t *testing.T) {
+		dir := t.TempDir()
+
+		// The content length of the request is not set
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/folder/", "", nil, []byte("content"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidRequest)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("directory bad_md5", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/folder/", "", http.Header{
+			"Content-Md5": []string{"ZajifYh5KDgxtmS9i38K1A=="},
+		}, nil)
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BadDigest)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("bad_md5", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", http.Header{
+			"Content-Md5": []string{"1B2M2Y8AsgTpgAmY7PhCfg=="},
+		}, []byte("Hello, World!"))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BadDigest)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("bad_sha256", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", nil, []byte("Hello, World!"))
+		req.Body = io.NopCloser(bytes.NewReader([]byte("Hello, Moon!!")))
+		testWritableServer(dir).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.BadDigest)
+		assertDirEntries(t, dir)
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/.ls3meta/object.txt.json", "", nil, []byte("{}"))
+		testWritableServer(t.TempDir()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.InvalidArgument)
+	})
+
+	t.Run("snapshot dir", func(t *testing.T) {
+		dir := t.TempDir()
+		srv := testSnapshotServer(dir)
+
+		for _, key := range []string{".snapshots", ".snapshots/daily/object.txt", ".snapshots/new/object.txt"} {
+			rw := httptest.NewRecorder()
+			req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/"+key, "", nil, []byte("new"))
+			srv.ServeHTTP(rw, req)
+
+			AssertIsResponseError(t, rw, exception.AccessDenied)
+		}
+
+		assertDirEntries(t, filepath.Join(dir, "bucket"), ".snapshots")
+		assertDirEntries(t, filepath.Join(dir, "bucket", ".snapshots"), "daily")
+
+		b, _ := os.ReadFile(filepath.Join(dir, "bucket", ".snapshots", "daily", "object.txt"))
+		assert.Equal(t, "old", string(b))
+	})
+
+	t.Run("not_writable", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPut, "/bucket/object.txt", "", nil, []byte("Hello, World!"))
+		testServerFS(memfs.New()).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MethodNotAllowed)
+	})
+}
@@ -0,0 +1,277 @@
+package ls3
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSecurityTokenServiceRequestSize is the maximum size of the form body of a request to the security token service.
+	maxSecurityTokenServiceRequestSize = 64 * 1024
+	// maxSessionPolicySize is the maximum size of a session policy.
+	maxSessionPolicySize = 2048
+)
+
+type AssumedRoleUser struct {
+	Arn           string
+	AssumedRoleId string
+}
+
+type ResponseMetadata struct {
+	RequestId string
+}
+
+type AssumeRoleResult struct {
+	Credentials     *idp.Credentials
+	AssumedRoleUser *AssumedRoleUser
+}
+
+type AssumeRoleResponse struct {
+	XMLName          xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+	AssumeRoleResult *AssumeRoleResult
+	ResponseMetadata *ResponseMetadata
+}
+
+type AssumeRoleWithWebIdentityResult struct {
+	Credentials                 *idp.Credentials
+	SubjectFromWebIdentityToken string
+	AssumedRoleUser             *AssumedRoleUser
+	Provider                    string
+	Audience                    string
+}
+
+type AssumeRoleWithWebIdentityResponse struct {
+	XMLName                         xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleWithWebIdentityResponse"`
+	AssumeRoleWithWebIdentityResult *AssumeRoleWithWebIdentityResult
+	ResponseMetadata                *ResponseMetadata
+}
+
+type GetSessionTokenResult struct {
+	Credentials *idp.Credentials
+}
+
+type GetSessionTokenResponse struct {
+	XMLName               xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ GetSessionTokenResponse"`
+	GetSessionTokenResult *GetSessionTokenResult
+	ResponseMetadata      *ResponseMetadata
+}
+
+// parseSessionPolicy parses an inline session policy, which is a list of policy statements.
+func parseSessionPolicy(v string) ([]*idp.PolicyStatement, error) {
+	if len(v) > maxSessionPolicySize {
+		return nil, &exception.Error{
+			ErrorCode: exception.MalformedPolicy,
+			Message:   "The session policy is too large.",
+		}
+	}
+
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+
+	var policy []*idp.PolicyStatement
+	err := dec.Decode(&policy)
+	if err != nil || len(policy) == 0 {
+		return nil, &exception.Error{
+			ErrorCode: exception.MalformedPolicy,
+			Message:   "The session policy must be a list of at least one policy statement.",
+		}
+	}
+
+	for _, statement := range policy {
+		if statement == nil {
+			return nil, &exception.Error{
+				ErrorCode: exception.MalformedPolicy,
+				Message:   "The session policy must be a list of at least one policy statement.",
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// roleNameFromArn returns the name of the role in an ARN such as arn:aws:iam::<account>:role/<name>.
+// Any value that is not an ARN is itself the name of the role.
+func roleNameFromArn(arn string) string {
+	if !strings.HasPrefix(arn, "arn:") {
+		return arn
+	}
+
+	if i := strings.LastIndex(arn, ":role/"); i >= 0 {
+		return arn[i+len(":role/"):]
+	}
+
+	return ""
+}
+
+// securityTokenServiceParams returns the parameters of a request to the security token service,
+// which can be in the query or the form body of the request.
+func securityTokenServiceParams(ctx *RequestContext) (url.Values, *exception.Error) {
+	b, err := ctx.ReadBody(maxSecurityTokenServiceRequestSize)
+	if err != nil {
+		return nil, err
+	}
+
+	params, perr := url.ParseQuery(string(bytes.TrimSpace(b)))
+	if perr != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "The request body must be form encoded.",
+		}
+	}
+
+	for k, v := range ctx.Request.URL.Query() {
+		params[k] = append(params[k], v...)
+	}
+
+	return params, nil
+}
+
+// SecurityTokenService implements the AssumeRole, AssumeRoleWithWebIdentity and GetSessionToken actions of AWS STS,
+// which create temporary credentials for the identity of the request, or for a web identity token.
+func (s *Server) SecurityTokenService(ctx *RequestContext) *exception.Error {
+	issuer, ok := s.identities.(idp.SessionIssuer)
+	if !ok {
+		return &exception.Error{
+			ErrorCode: exception.MethodNotAllowed,
+			Message:   "Temporary credentials are not enabled on this server.",
+		}
+	}
+
+	params, err := securityTokenServiceParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	var duration = idp.DefaultSessionDuration
+	if v := params.Get("DurationSeconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return &exception.Error{
+				ErrorCode: exception.InvalidArgument,
+				Message:   "DurationSeconds must be an integer.",
+			}
+		}
+
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	var policy []*idp.PolicyStatement
+	if v := params.Get("Policy"); v != "" {
+		var perr error
+		policy, perr = parseSessionPolicy(v)
+		if perr != nil {
+			return exception.ErrorFrom(perr)
+		}
+	}
+
+	responseMetadata := &ResponseMetadata{
+		RequestId: ctx.ID.String(),
+	}
+
+	var (
+		action      = params.Get("Action")
+		role        = roleNameFromArn(params.Get("RoleArn"))
+		sessionName = params.Get("RoleSessionName")
+	)
+
+	switch action {
+	case "AssumeRole", "GetSessionToken":
+		if ctx.Identity.AccessKeyId == idp.IdentityUnauthenticatedPublic {
+			return &exception.Error{
+				ErrorCode: exception.AccessDenied,
+				Message:   "Temporary credentials can only be created by an authenticated identity.",
+			}
+		}
+	}
+
+	switch action {
+	case "AssumeRole", "AssumeRoleWithWebIdentity":
+		if role == "" || sessionName == "" {
+			return &exception.Error{
+				ErrorCode: exception.InvalidArgument,
+				Message:   action + " requires a RoleArn and RoleSessionName.",
+			}
+		}
+	}
+
+	switch action {
+	case "AssumeRole":
+		if err := ctx.CheckAccess(idp.AssumeRole, idp.Resource("role/"+role), idp.NullContext{}); err != nil {
+			return err
+		}
+
+		credentials, cerr := issuer.NewSession(ctx.Identity, role, policy, duration)
+		if cerr != nil {
+			return exception.ErrorFrom(cerr)
+		}
+
+		ctx.SendXML(http.StatusOK, &AssumeRoleResponse{
+			AssumeRoleResult: &AssumeRoleResult{
+				Credentials: credentials,
+				AssumedRoleUser: &AssumedRoleUser{
+					Arn:           "arn:aws:sts::ls3:assumed-role/" + role + "/" + sessionName,
+					AssumedRoleId: credentials.AccessKeyId + ":" + sessionName,
+				},
+			},
+			ResponseMetadata: responseMetadata,
+		})
+
+	case "AssumeRoleWithWebIdentity":
+		// Web identity requests are not signed, so only the global policy applies to them
+		if err := idp.EvaluatePolicy(idp.AssumeRoleWithWebIdentity, idp.Resource("role/"+role), ctx.globalPolicy, ctx); err != nil {
+			return err
+		}
+
+		credentials, identity, cerr := issuer.NewWebIdentitySession(params.Get("WebIdentityToken"), role, policy, duration)
+		if cerr != nil {
+			return exception.ErrorFrom(cerr)
+		}
+
+		ctx.SendXML(http.StatusOK, &AssumeRoleWithWebIdentityResponse{
+			AssumeRoleWithWebIdentityResult: &AssumeRoleWithWebIdentityResult{
+				Credentials:                 credentials,
+				SubjectFromWebIdentityToken: identity.Subject,
+				AssumedRoleUser: &AssumedRoleUser{
+					Arn:           "arn:aws:sts::ls3:assumed-role/" + role + "/" + sessionName,
+					AssumedRoleId: credentials.AccessKeyId + ":" + sessionName,
+				},
+				Provider: identity.Issuer,
+				Audience: identity.Audience,
+			},
+			ResponseMetadata: responseMetadata,
+		})
+
+	case "GetSessionToken":
+		if err := ctx.CheckAccess(idp.GetSessionToken, "", idp.NullContext{}); err != nil {
+			return err
+		}
+
+		credentials, cerr := issuer.NewSession(ctx.Identity, "", policy, duration)
+		if cerr != nil {
+			return exception.ErrorFrom(cerr)
+		}
+
+		ctx.SendXML(http.StatusOK, &GetSessionTokenResponse{
+			GetSessionTokenResult: &GetSessionTokenResult{
+				Credentials: credentials,
+			},
+			ResponseMetadata: responseMetadata,
+		})
+
+	default:
+		return &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "The security token service action " + strconv.Quote(action) + " is not supported.",
+		}
+	}
+
+	return nil
+}
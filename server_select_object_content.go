@@ -0,0 +1,88 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"github.com/relvacode/ls3/s3select"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+// maxSelectRequestSize is the maximum size of a SelectObjectContent request body.
+const maxSelectRequestSize = 256 * 1024
+
+// deferredResponse sends the response status code on the first write to the response body.
+// It allows a streaming response to return an error before any response has been sent.
+type deferredResponse struct {
+	ctx        *RequestContext
+	statusCode int
+	w          io.Writer
+	n          int64
+}
+
+func (d *deferredResponse) Write(b []byte) (int, error) {
+	if d.w == nil {
+		d.w = d.ctx.SendPlain(d.statusCode)
+	}
+
+	n, err := d.w.Write(b)
+	d.n += int64(n)
+	return n, err
+}
+
+func (s *Server) SelectObjectContent(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	// Try to stat the object first, to allow authentication context with the object.
+	obj, statErr := stat(ctx, key)
+	var objCtx idp.PolicyContextVars = idp.NullContext{}
+	if obj != nil {
+		defer obj.Close()
+		objCtx = obj
+	}
+
+	if err := ctx.CheckAccess(ctx.objectReadAction(), idp.Resource(ctx.Bucket+"/"+key), objCtx); err != nil {
+		return err
+	}
+
+	if statErr != nil {
+		// The request must have ListBucket access to see the real error behind accessing the object
+		if err := ctx.CheckAccess(idp.ListBucket, idp.Resource(ctx.Bucket), objCtx); err != nil {
+			return err
+		}
+
+		return exception.ErrorFrom(statErr)
+	}
+
+	var req s3select.Request
+	if err := ctx.ReadXML(&req, maxSelectRequestSize); err != nil {
+		return err
+	}
+
+	ctx.Header().Set("Content-Type", "application/octet-stream")
+
+	var response = &deferredResponse{
+		ctx:        ctx,
+		statusCode: http.StatusOK,
+	}
+
+	err = s3select.Run(&req, obj, response)
+
+	// Update statistics
+	statBytesTransferredOut.WithLabelValues(ctx.Bucket, key, ctx.Identity.Name, ctx.RemoteIP.String()).Add(float64(response.n))
+
+	if err != nil {
+		if response.w == nil {
+			ctx.Header().Del("Content-Type")
+			return exception.ErrorFrom(err)
+		}
+
+		ctx.Error("Failed to write select response", zap.Error(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package ls3
+
+import (
+	"github.com/psanford/memfs"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testSelectRequest(expression string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Expression>` + expression + `</Expression>
+  <ExpressionType>SQL</ExpressionType>
+  <InputSerialization>
+    <CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>
+  </InputSerialization>
+  <OutputSerialization>
+    <CSV/>
+  </OutputSerialization>
+</SelectObjectContentRequest>`)
+}
+
+func TestServer_SelectObjectContent(t *testing.T) {
+	fsys := memfs.New()
+	_ = fsys.WriteFile("people.csv", []byte("name,city\nAlice,London\nBob,Paris\n"), 0644)
+
+	t.Run("select", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/people.csv", "select=&select-type=2", nil,
+			testSelectRequest("SELECT s.name FROM S3Object s WHERE s.city = 'Paris'"))
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "application/octet-stream", rw.Header().Get("Content-Type"))
+		assert.Contains(t, rw.Body.String(), ":event-type\x07\x00\x07Records")
+		assert.Contains(t, rw.Body.String(), "Bob\n")
+		assert.NotContains(t, rw.Body.String(), "Alice")
+	})
+
+	t.Run("invalid_expression", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/people.csv", "select=&select-type=2", nil,
+			testSelectRequest("SELECT FROM S3Object"))
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.ParseUnexpectedToken)
+	})
+
+	t.Run("malformed_xml", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/people.csv", "select=&select-type=2", nil,
+			[]byte("not xml"))
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.MalformedXML)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := testSignedRequest(SignAWSV4{}, http.MethodPost, "/bucket/missing.csv", "select=&select-type=2", nil,
+			testSelectRequest("SELECT * FROM S3Object"))
+		testServerFS(fsys).ServeHTTP(rw, req)
+
+		AssertIsResponseError(t, rw, exception.NoSuchKey)
+	})
+}
@@ -3,6 +3,9 @@ package ls3
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/xml"
 	"github.com/google/uuid"
 	"github.com/psanford/memfs"
@@ -12,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -43,6 +47,14 @@ func testServer(additionalPolicyStatements ...*idp.PolicyStatement) *Server {
 	return srv
 }
 
+// testServerFS is like testServer, but serves the given filesystem for any bucket.
+func testServerFS(fsys fs.FS, additionalPolicyStatements ...*idp.PolicyStatement) *Server {
+	srv := testServer(additionalPolicyStatements...)
+	srv.filesystemProvider = &SingleBucketFilesystem{FS: fsys}
+
+	return srv
+}
+
 func testSignedRequest(signer Signer, method, path, query string, headers http.Header, payload []byte) *http.Request {
 	req := &http.Request{
 		Method: method,
@@ -119,3 +131,68 @@ func AssertIsResponseError(t *testing.T, rw *httptest.ResponseRecorder, expect e
 	assert.NoError(t, err)
 	assert.Equal(t, expect.Code, resp.Code)
 }
+
+func TestServer_CertificateIdentity(t *testing.T) {
+	srv := testServer()
+	srv.identities = &idp.CertificateProvider{
+		Provider: idp.Keyring{
+			idp.IdentityUnauthenticatedPublic: &idp.Identity{
+				Name:        "public",
+				AccessKeyId: idp.IdentityUnauthenticatedPublic,
+			},
+		},
+		Identities: []*idp.CertificateIdentity{
+			{
+				Name:    "machine",
+				Subject: "CN=machine,O=Example",
+				Policy: []*idp.PolicyStatement{
+					{
+						Action:   []idp.Action{idp.ListBucket},
+						Resource: []idp.Resource{"bucket"},
+						Condition: idp.PolicyConditions{
+							idp.StringEquals: {"ls3:tls:san": {"machine.example.com"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	request := func(cert *x509.Certificate) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://bucket.testing/bucket/", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			}
+		}
+
+		return req
+	}
+
+	t.Run("verified certificate", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, request(&x509.Certificate{
+			Subject:  pkix.Name{CommonName: "machine", Organization: []string{"Example"}},
+			DNSNames: []string{"machine.example.com"},
+		}))
+
+		assert.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, request(&x509.Certificate{
+			Subject:  pkix.Name{CommonName: "machine", Organization: []string{"Example"}},
+			DNSNames: []string{"other.example.com"},
+		}))
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, request(nil))
+
+		AssertIsResponseError(t, rw, exception.AccessDenied)
+	})
+}
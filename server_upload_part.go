@@ -0,0 +1,54 @@
+package ls3
+
+import (
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"strconv"
+)
+
+func (s *Server) UploadPart(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	var query = ctx.Request.URL.Query()
+
+	partNumber, err := parsePartNumber(query.Get("partNumber"))
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	upload, err := readMultipartUpload(wfs, query.Get("uploadId"), key)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	expectMD5, err := contentMD5(ctx.Request.Header)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	etag, bytesReceived, err := writeMultipartPart(wfs, upload.UploadId, partNumber, ctx.Request.Body, expectMD5)
+
+	// Update statistics
+	statBytesTransferredIn.WithLabelValues(ctx.Bucket, key, ctx.Identity.Name, ctx.RemoteIP.String()).Add(float64(bytesReceived))
+
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	ctx.Header().Set("ETag", strconv.Quote(etag))
+	ctx.SendPlain(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,110 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/gotd/contrib/http_range"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CopyPartResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyPartResult"`
+	ETag         string
+	LastModified time.Time
+}
+
+// copySourceRange returns a reader of the x-amz-copy-source-range of the source object, or the entire object if not set.
+func copySourceRange(header http.Header, src *copySource) (io.Reader, error) {
+	value := header.Get("x-amz-copy-source-range")
+	if value == "" {
+		return src.ReadCloser, nil
+	}
+
+	// The range must be an absolute range of bytes
+	ranges, err := http_range.ParseRange(value, src.Size)
+	if err != nil || len(ranges) != 1 || strings.HasPrefix(value, "bytes=-") || strings.HasSuffix(value, "-") {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidArgument,
+			Message:   "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy.",
+		}
+	}
+
+	if seeker, ok := src.ReadCloser.(io.Seeker); ok {
+		_, err = seeker.Seek(ranges[0].Start, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, src, ranges[0].Start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return io.LimitReader(src, ranges[0].Length), nil
+}
+
+func (s *Server) UploadPartCopy(ctx *RequestContext) *exception.Error {
+	key, err := urlPathObjectKey(ctx.Request.URL.Path)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	if err := ctx.CheckAccess(idp.PutObject, idp.Resource(ctx.Bucket+"/"+key), idp.NullContext{}); err != nil {
+		return err
+	}
+
+	var query = ctx.Request.URL.Query()
+
+	partNumber, err := parsePartNumber(query.Get("partNumber"))
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	wfs, werr := s.openWritable(ctx)
+	if werr != nil {
+		return werr
+	}
+
+	upload, err := readMultipartUpload(wfs, query.Get("uploadId"), key)
+	if err != nil {
+		return exception.ErrorFrom(err)
+	}
+
+	src, serr := s.openCopySource(ctx)
+	if serr != nil {
+		return serr
+	}
+
+	defer src.Close()
+
+	r, err := copySourceRange(ctx.Request.Header, src)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	etag, _, err := writeMultipartPart(wfs, upload.UploadId, partNumber, r, nil)
+	if err != nil {
+		return unwrapWriteError(err)
+	}
+
+	dir, _ := multipartUploadPath(upload.UploadId)
+	fi, err := fs.Stat(wfs, path.Join(dir, multipartPartName(partNumber, etag)))
+	if err != nil {
+		return unwrapFsError(err)
+	}
+
+	if src.VersionId != "" {
+		ctx.Header().Set("x-amz-copy-source-version-id", src.VersionId)
+	}
+
+	ctx.SendXML(http.StatusOK, &CopyPartResult{
+		ETag:         strconv.Quote(etag),
+		LastModified: fi.ModTime().UTC(),
+	})
+	return nil
+}
@@ -30,6 +30,75 @@ type Signer interface {
 	Verify(r *http.Request, provider idp.Provider) (*idp.Identity, error)
 }
 
+// signatureDetector is implemented by a Signer that can tell if a request is signed using its algorithm.
+type signatureDetector interface {
+	IsSigned(r *http.Request) bool
+}
+
+var _ Signer = MultiSigner(nil)
+
+// MultiSigner is a Signer that verifies requests signed using the algorithm of any of its signers, in order.
+// Requests are signed using the first signer.
+type MultiSigner []Signer
+
+func (m MultiSigner) Sign(r *http.Request, identity *idp.Identity, payload []byte) error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m[0].Sign(r, identity, payload)
+}
+
+// Verify verifies the request using the first signer that detects its algorithm.
+// If no signer detects the algorithm of the request then it is verified by the first signer.
+func (m MultiSigner) Verify(r *http.Request, provider idp.Provider) (*idp.Identity, error) {
+	for _, signer := range m {
+		if detector, ok := signer.(signatureDetector); ok && !detector.IsSigned(r) {
+			continue
+		}
+
+		return signer.Verify(r, provider)
+	}
+
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	return m[0].Verify(r, provider)
+}
+
+// requestAccessKeyId returns the access key id that the request claims to be signed by, without verifying the request.
+func requestAccessKeyId(r *http.Request) string {
+	q := r.URL.Query()
+
+	if q.Get(xAmzSignature) != "" {
+		credential, err := ParseCredential(q.Get(xAmzCredential))
+		if err != nil {
+			return ""
+		}
+		return credential.AccessKeyID
+	}
+
+	if q.Get(amzSignatureV2) != "" {
+		return q.Get(amzAccessKeyIdV2)
+	}
+
+	hdr := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(hdr, awsSignatureVersionV4+" "):
+		auth, err := ParseAuthorizationHeader(hdr)
+		if err != nil {
+			return ""
+		}
+		return auth.Credentials.AccessKeyID
+	case strings.HasPrefix(hdr, awsSignatureVersionV2+" "):
+		keyId, _, _ := strings.Cut(strings.TrimPrefix(hdr, awsSignatureVersionV2+" "), ":")
+		return keyId
+	default:
+		return ""
+	}
+}
+
 func sumHmacSha256(secret, data []byte) []byte {
 	h := hmac.New(sha256.New, secret)
 	h.Write(data)
@@ -40,6 +109,7 @@ func sumHmacSha256(secret, data []byte) []byte {
 var timeFormats = []string{
 	amzDateTimeFormat,
 	http.TimeFormat,
+	time.RFC1123Z,
 	time.RFC850,
 	time.ANSIC,
 }
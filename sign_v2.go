@@ -0,0 +1,321 @@
+package ls3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	awsSignatureVersionV2 = "AWS"
+	amzAccessKeyIdV2      = "AWSAccessKeyId"
+	amzSignatureV2        = "Signature"
+	amzExpiresV2          = "Expires"
+)
+
+// signV2SubResources are the query parameters that are included in the canonicalized resource of a Signature Version 2 request.
+// They must be sorted.
+var signV2SubResources = []string{
+	"acl",
+	"attributes",
+	"cors",
+	"delete",
+	"lifecycle",
+	"location",
+	"logging",
+	"notification",
+	"partNumber",
+	"policy",
+	"requestPayment",
+	"response-cache-control",
+	"response-content-disposition",
+	"response-content-encoding",
+	"response-content-language",
+	"response-content-type",
+	"response-expires",
+	"restore",
+	"select",
+	"select-type",
+	"tagging",
+	"torrent",
+	"uploadId",
+	"uploads",
+	"versionId",
+	"versioning",
+	"versions",
+	"website",
+}
+
+func sumHmacSha1(secret, data []byte) []byte {
+	h := hmac.New(sha1.New, secret)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+var _ Signer = (*SignAWSV2)(nil)
+
+// SignAWSV2 implements the legacy AWS Signature Version 2 for clients that do not support Signature Version 4.
+// Signature Version 2 does not sign the request payload.
+type SignAWSV2 struct {
+	// Domain is the server domain used for host style addressing.
+	// The canonicalized resource of a host style request includes the bucket from the request host.
+	Domain string
+	// MaxSkew is the maximum difference between the time of a request signed in its headers and the server time.
+	// If zero then DefaultMaxRequestSkew is used.
+	MaxSkew time.Duration
+	// Replay rejects an exact replay of a request signed in its headers within the skew window.
+	// If nil then replays are not detected.
+	Replay *ReplayCache
+
+	// timeNow is a function that returns the current time.
+	// Used for testing, if nil then time.Now is used.
+	timeNow func() time.Time
+}
+
+func (s SignAWSV2) now() time.Time {
+	if s.timeNow == nil {
+		return time.Now()
+	}
+	return s.timeNow()
+}
+
+func (s SignAWSV2) maxSkew() time.Duration {
+	if s.MaxSkew <= 0 {
+		return DefaultMaxRequestSkew
+	}
+	return s.MaxSkew
+}
+
+// canonicalizedResource returns the bucket, path and sub-resources of the request.
+func (s SignAWSV2) canonicalizedResource(r *http.Request) string {
+	var b strings.Builder
+
+	if s.Domain != "" {
+		domain := strings.Split(s.Domain, ".")
+		if hostComponents := requestHostComponents(r); isDomainRequest(r, domain) && len(hostComponents) == len(domain)+1 {
+			b.WriteRune('/')
+			b.WriteString(hostComponents[0])
+		}
+	}
+
+	b.WriteString(r.URL.EscapedPath())
+
+	var (
+		query = r.URL.Query()
+		first = true
+	)
+
+	for _, k := range signV2SubResources {
+		v, ok := query[k]
+		if !ok {
+			continue
+		}
+
+		if first {
+			b.WriteRune('?')
+			first = false
+		} else {
+			b.WriteRune('&')
+		}
+
+		b.WriteString(k)
+		if len(v) > 0 && v[0] != "" {
+			b.WriteRune('=')
+			b.WriteString(v[0])
+		}
+	}
+
+	return b.String()
+}
+
+// computeStringToSign returns the string to sign of the request.
+// date is either the Date header, or the Expires parameter of a pre-signed request.
+func (s SignAWSV2) computeStringToSign(r *http.Request, date string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(r.Method)
+	b.WriteRune('\n')
+	b.WriteString(r.Header.Get("Content-MD5"))
+	b.WriteRune('\n')
+	b.WriteString(r.Header.Get("Content-Type"))
+	b.WriteRune('\n')
+	b.WriteString(date)
+	b.WriteRune('\n')
+
+	// CanonicalizedAmzHeaders
+	var amzHeaders []string
+	for k := range r.Header {
+		if lower := strings.ToLower(k); strings.HasPrefix(lower, "x-amz-") {
+			amzHeaders = append(amzHeaders, lower)
+		}
+	}
+
+	sort.Strings(amzHeaders)
+
+	for _, hdr := range amzHeaders {
+		b.WriteString(hdr)
+		b.WriteRune(':')
+
+		for idx, v := range r.Header.Values(hdr) {
+			if idx > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(signV4TrimAll(v))
+		}
+		b.WriteRune('\n')
+	}
+
+	// CanonicalizedResource
+	b.WriteString(s.canonicalizedResource(r))
+
+	return b.Bytes()
+}
+
+// computeSignature returns the signature of the string to sign using the secret of identity.
+func (s SignAWSV2) computeSignature(identity *idp.Identity, stringToSign []byte) []byte {
+	return sumHmacSha1([]byte(identity.SecretAccessKey), stringToSign)
+}
+
+func (s SignAWSV2) Sign(r *http.Request, identity *idp.Identity, payload []byte) error {
+	r.Header.Set("Date", s.now().UTC().Format(http.TimeFormat))
+
+	signature := s.computeSignature(identity, s.computeStringToSign(r, r.Header.Get("Date")))
+
+	r.Header.Set("Authorization", awsSignatureVersionV2+" "+identity.AccessKeyId+":"+base64.StdEncoding.EncodeToString(signature))
+
+	return nil
+}
+
+// verifySignature checks that the base64 encoded signature of the request matches the computed signature.
+func (s SignAWSV2) verifySignature(identity *idp.Identity, stringToSign []byte, signature string) error {
+	requestSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || subtle.ConstantTimeCompare(s.computeSignature(identity, stringToSign), requestSignature) != 1 {
+		return &exception.Error{
+			ErrorCode: exception.SignatureDoesNotMatch,
+			Message:   "The request signature that the server calculated does not match the signature that you provided.",
+		}
+	}
+
+	return nil
+}
+
+func (s SignAWSV2) VerifyHeaders(r *http.Request, provider idp.Provider) (*idp.Identity, error) {
+	keyId, signature, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("Authorization"), awsSignatureVersionV2+" "), ":")
+	if !ok || keyId == "" || signature == "" {
+		return nil, &exception.Error{
+			ErrorCode: exception.AuthorizationHeaderMalformed,
+			Message:   "The authorization header that you provided is not valid.",
+		}
+	}
+
+	// The Date header is not signed if X-Amz-Date is present
+	var date, requestDate = "", r.Header.Get(xAmzDate)
+	if requestDate == "" {
+		date = r.Header.Get("Date")
+		requestDate = date
+	}
+
+	at, err := ParseAmzTime(requestDate)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "AWS authentication requires a valid Date or x-amz-date header.",
+		}
+	}
+
+	err = checkRequestSkew(at, s.now(), s.maxSkew())
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := idp.GetIdentity(provider, keyId, r.Header.Get(xAmzSecurityToken))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifySignature(identity, s.computeStringToSign(r, date), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// An exact replay of the request is rejected until the request time is outside the skew window
+	if s.Replay != nil && s.Replay.Seen(signature, at.Add(s.maxSkew()), s.now()) {
+		return nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "The request signature has already been used.",
+		}
+	}
+
+	return identity, nil
+}
+
+func (s SignAWSV2) VerifyQuery(r *http.Request, provider idp.Provider) (*idp.Identity, error) {
+	var q = r.URL.Query()
+
+	expires, err := strconv.ParseInt(q.Get(amzExpiresV2), 10, 64)
+	if err != nil {
+		return nil, &exception.Error{
+			ErrorCode: exception.InvalidRequest,
+			Message:   "Invalid value for Expires.",
+		}
+	}
+
+	// Check that the pre-signed request has not expired
+	if s.now().After(time.Unix(expires, 0)) {
+		return nil, &exception.Error{
+			ErrorCode: exception.ExpiredToken,
+			Message:   "The provided token has expired.",
+		}
+	}
+
+	identity, err := idp.GetIdentity(provider, q.Get(amzAccessKeyIdV2), q.Get(xAmzSecurityToken))
+	if err != nil {
+		return nil, err
+	}
+
+	// The security token of a pre-signed request is signed as if it were a header
+	if token := q.Get(xAmzSecurityToken); token != "" && r.Header.Get(xAmzSecurityToken) == "" {
+		clone := r.Clone(r.Context())
+		clone.Header.Set(xAmzSecurityToken, token)
+		r = clone
+	}
+
+	err = s.verifySignature(identity, s.computeStringToSign(r, q.Get(amzExpiresV2)), q.Get(amzSignatureV2))
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// IsSigned returns true if the request is signed using Signature Version 2.
+func (s SignAWSV2) IsSigned(r *http.Request) bool {
+	if q := r.URL.Query(); q.Get(amzSignatureV2) != "" && q.Get(amzAccessKeyIdV2) != "" {
+		return true
+	}
+
+	return strings.HasPrefix(r.Header.Get("Authorization"), awsSignatureVersionV2+" ")
+}
+
+func (s SignAWSV2) Verify(r *http.Request, provider idp.Provider) (*idp.Identity, error) {
+	if q := r.URL.Query(); q.Get(amzSignatureV2) != "" && q.Get(amzAccessKeyIdV2) != "" {
+		return s.VerifyQuery(r, provider)
+	}
+
+	if strings.HasPrefix(r.Header.Get("Authorization"), awsSignatureVersionV2+" ") {
+		return s.VerifyHeaders(r, provider)
+	}
+
+	// Return no identity.
+	return nil, nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/relvacode/ls3/exception"
 	"github.com/relvacode/ls3/idp"
+	"hash"
 	"io"
 	"net/http"
 	"net/textproto"
@@ -25,6 +26,7 @@ const (
 	xAmzExpires        = "X-Amz-Expires"
 	xAmzSignedHeaders  = "X-Amz-SignedHeaders"
 	xAmzSignature      = "X-Amz-Signature"
+	xAmzSecurityToken  = "X-Amz-Security-Token"
 )
 
 const (
@@ -32,6 +34,50 @@ const (
 	maximumPresignedExpiry  = time.Second * 604800
 )
 
+// DefaultMaxRequestSkew is the default maximum difference between the time of a signed request and the server time.
+const DefaultMaxRequestSkew = 15 * time.Minute
+
+// signV4Services are the services that requests to the server can be signed for.
+// The service of each request is checked against the services of its route by the server, see Server.checkSignedScope.
+var signV4Services = map[string]bool{
+	"s3":  true,
+	"sts": true,
+}
+
+var (
+	// s3Services are the services that requests for buckets and objects can be signed for.
+	s3Services = map[string]bool{
+		"s3": true,
+	}
+	// stsServices are the services that requests to the security token service can be signed for.
+	stsServices = map[string]bool{
+		"s3":  true,
+		"sts": true,
+	}
+)
+
+// checkCredentialScope returns an error with the given code if the credential scope of a request signed at the time at
+// is not valid for this server.
+func checkCredentialScope(credential Credential, at time.Time, code exception.ErrorCode) error {
+	var message string
+
+	switch {
+	case credential.Date.Format(amzDateFormat) != at.Format(amzDateFormat):
+		message = "The credential date does not match the date of the request."
+	case !signV4Services[credential.Service]:
+		message = "The credential scope is for a service that is not supported by this server."
+	case credential.Type != "aws4_request":
+		message = "The credential scope must end with aws4_request."
+	default:
+		return nil
+	}
+
+	return &exception.Error{
+		ErrorCode: code,
+		Message:   message,
+	}
+}
+
 // Trim leading and trailing spaces and replace sequential spaces with one space, following Trimall()
 // in http://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
 func signV4TrimAll(input string) string {
@@ -40,44 +86,86 @@ func signV4TrimAll(input string) string {
 	return strings.Join(strings.Fields(input), " ")
 }
 
+// payloadSha256Reader verifies the SHA256 sum of a request body as it is read.
+// Once the end of the body is reached, Read returns BadDigest if the sum does not match the expected sum.
+type payloadSha256Reader struct {
+	io.ReadCloser
+	hash   hash.Hash
+	expect []byte
+}
+
+func (r *payloadSha256Reader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.hash.Write(b[:n])
+
+	if err == io.EOF && subtle.ConstantTimeCompare(r.hash.Sum(nil), r.expect) != 1 {
+		return n, &exception.Error{
+			ErrorCode: exception.BadDigest,
+			Message:   "The Content-MD5 or checksum value that you specified did not match what the server received.",
+		}
+	}
+
+	return n, err
+}
+
+// payloadSha256Hex returns the hashed payload of the request used in the canonical request.
+// The request body is not read, instead it is replaced with a reader that verifies the payload against contentSha256.
+// This allows large request bodies to be streamed, so handlers must read the body to the end before acting on it.
+// Streaming aws-chunked payloads are decoded by the signer once the signature of the request is verified.
 func payloadSha256Hex(r *http.Request, contentSha256 string) ([]byte, error) {
 	switch contentSha256 {
 	case "", amzUnsignedPayload:
 		return []byte(amzUnsignedPayload), nil
+	case amzStreamingPayload, amzStreamingPayloadTrailer, amzStreamingUnsignedPayloadTrailer:
+		return []byte(contentSha256), nil
 	default:
-		contentShaRaw, _ := hex.DecodeString(contentSha256)
-
-		// Read request payload
-		var payload bytes.Buffer
-		_, err := io.Copy(&payload, r.Body)
-		closeErr := r.Body.Close()
-		if err != nil {
-			return nil, err
+		contentShaRaw, err := hex.DecodeString(contentSha256)
+		if err != nil || len(contentShaRaw) != sha256.Size {
+			return nil, &exception.Error{
+				ErrorCode: exception.InvalidArgument,
+				Message:   "Invalid value for x-amz-content-sha256.",
+			}
 		}
-		if closeErr != nil {
-			return nil, closeErr
+
+		r.Body = &payloadSha256Reader{
+			ReadCloser: r.Body,
+			hash:       sha256.New(),
+			expect:     contentShaRaw,
 		}
 
-		// After body has been read by signature verification,
-		// replace the original request body with the raw payload
-		r.Body = io.NopCloser(&payload)
+		return []byte(contentSha256), nil
+	}
+}
 
-		// Compute payload SHA256
-		computedPayloadShaHasher := sha256.New()
-		computedPayloadShaHasher.Write(payload.Bytes())
-		computedPayloadSha := computedPayloadShaHasher.Sum(nil)
+// maxUnsignedPayloadSize is the maximum size of the body of a request to a service other than S3,
+// which is read in full to compute the hashed payload.
+const maxUnsignedPayloadSize = 1024 * 1024
 
-		if payload.Len() > 0 && subtle.ConstantTimeCompare(computedPayloadSha, contentShaRaw) != 1 {
-			return nil, &exception.Error{
-				ErrorCode: exception.BadDigest,
-				Message:   "The Content-MD5 or checksum value that you specified did not match what the server received.",
-			}
-		}
+// readPayloadSha256Hex reads the request body, and returns its SHA256 sum.
+// Services other than S3 do not send x-amz-content-sha256, but still sign the hashed payload of the request.
+// The body is replaced so that it can be read again.
+func readPayloadSha256Hex(r *http.Request) (string, error) {
+	if r.Body == nil {
+		return hex.EncodeToString(sha256.New().Sum(nil)), nil
+	}
 
-		var payloadShaHex = make([]byte, hex.EncodedLen(len(computedPayloadSha)))
-		hex.Encode(payloadShaHex, computedPayloadSha)
-		return payloadShaHex, nil
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxUnsignedPayloadSize+1))
+	if err != nil {
+		return "", err
 	}
+
+	if len(b) > maxUnsignedPayloadSize {
+		return "", &exception.Error{
+			ErrorCode: exception.MaxMessageLengthExceeded,
+			Message:   "Your request was too big.",
+		}
+	}
+
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 func awsV4CanonicalRequest(r *http.Request, payloadShaHex []byte, signedHeaders []string) []byte {
@@ -147,6 +235,16 @@ func awsV4CanonicalRequest(r *http.Request, payloadShaHex []byte, signedHeaders
 var _ Signer = (*SignAWSV4)(nil)
 
 type SignAWSV4 struct {
+	// Region is the region that requests are signed for by Sign.
+	// If empty then DefaultRegion is used.
+	Region string
+	// MaxSkew is the maximum difference between the time of a request signed in its headers and the server time.
+	// If zero then DefaultMaxRequestSkew is used.
+	MaxSkew time.Duration
+	// Replay rejects an exact replay of a request signed in its headers within the skew window.
+	// If nil then replays are not detected.
+	Replay *ReplayCache
+
 	// timeNow is a function that returns the current time.
 	// Used for testing, if nil then time.Now is used.
 	timeNow func() time.Time
@@ -159,7 +257,43 @@ func (s SignAWSV4) now() time.Time {
 	return s.timeNow()
 }
 
-func (s SignAWSV4) computeStringToSign(at time.Time, region string, canonicalRequest []byte) []byte {
+func (s SignAWSV4) region() string {
+	if s.Region == "" {
+		return DefaultRegion
+	}
+	return s.Region
+}
+
+func (s SignAWSV4) maxSkew() time.Duration {
+	if s.MaxSkew <= 0 {
+		return DefaultMaxRequestSkew
+	}
+	return s.MaxSkew
+}
+
+// checkRequestSkew returns an error if the time of a request signed at the time at differs from now by more than maxSkew.
+func checkRequestSkew(at time.Time, now time.Time, maxSkew time.Duration) error {
+	skew := now.Sub(at)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > maxSkew {
+		return &exception.Error{
+			ErrorCode: exception.RequestTimeTooSkewed,
+			Message:   "The difference between the request time and the server's time is too large.",
+		}
+	}
+
+	return nil
+}
+
+// checkRequestTime returns an error if the time of a request signed at the time at is too far from the server time.
+func (s SignAWSV4) checkRequestTime(at time.Time) error {
+	return checkRequestSkew(at, s.now(), s.maxSkew())
+}
+
+func (s SignAWSV4) computeStringToSign(at time.Time, region, service string, canonicalRequest []byte) []byte {
 	var b bytes.Buffer
 
 	b.WriteString(awsSignatureVersionV4)
@@ -173,7 +307,9 @@ func (s SignAWSV4) computeStringToSign(at time.Time, region string, canonicalReq
 	b.WriteString(at.Format(amzDateFormat))
 	b.WriteRune('/')
 	b.WriteString(region)
-	b.WriteString("/s3/aws4_request\n")
+	b.WriteRune('/')
+	b.WriteString(service)
+	b.WriteString("/aws4_request\n")
 
 	// Hex(SHA256Hash(<CanonicalRequest>))
 	h := sha256.New()
@@ -183,10 +319,10 @@ func (s SignAWSV4) computeStringToSign(at time.Time, region string, canonicalReq
 	return b.Bytes()
 }
 
-func (s SignAWSV4) computeSigningKey(at time.Time, region string, identity *idp.Identity) []byte {
+func (s SignAWSV4) computeSigningKey(at time.Time, region, service string, identity *idp.Identity) []byte {
 	var signed = sumHmacSha256([]byte("AWS4"+identity.SecretAccessKey), at.AppendFormat(nil, amzDateFormat))
 	signed = sumHmacSha256(signed, []byte(region))
-	signed = sumHmacSha256(signed, []byte("s3"))
+	signed = sumHmacSha256(signed, []byte(service))
 	signed = sumHmacSha256(signed, []byte("aws4_request"))
 
 	return signed
@@ -209,14 +345,15 @@ func (s SignAWSV4) Sign(r *http.Request, identity *idp.Identity, payload []byte)
 
 	var (
 		canonicalRequest = awsV4CanonicalRequest(r, payloadShaHex, []string{"host", "x-amz-content-sha256", "x-amz-date"})
-		signature        = sumHmacSha256(s.computeSigningKey(t, "us-east-1", identity), s.computeStringToSign(t, "us-east-1", canonicalRequest))
+		signature        = sumHmacSha256(s.computeSigningKey(t, s.region(), "s3", identity), s.computeStringToSign(t, s.region(), "s3", canonicalRequest))
 	)
 
 	r.Header.Set("Authorization", fmt.Sprintf(
-		"%s Credential=%s/%s/us-east-1/s3/aws4_request,SignedHeaders=host;x-amz-content-sha256;x-amz-date,Signature=%x",
+		"%s Credential=%s/%s/%s/s3/aws4_request,SignedHeaders=host;x-amz-content-sha256;x-amz-date,Signature=%x",
 		awsSignatureVersionV4,
 		identity.AccessKeyId,
 		t.Format(amzDateFormat),
+		s.region(),
 		signature,
 	))
 
@@ -229,6 +366,11 @@ func (s SignAWSV4) VerifyHeaders(r *http.Request, provider idp.Provider) (*idp.I
 		return nil, err
 	}
 
+	err = s.checkRequestTime(at)
+	if err != nil {
+		return nil, err
+	}
+
 	auth, err := ParseAuthorizationHeader(r.Header.Get("Authorization"))
 	if err != nil {
 		return nil, &exception.Error{
@@ -237,19 +379,32 @@ func (s SignAWSV4) VerifyHeaders(r *http.Request, provider idp.Provider) (*idp.I
 		}
 	}
 
-	identity, err := provider.Get(auth.Credentials.AccessKeyID)
+	err = checkCredentialScope(auth.Credentials, at, exception.AuthorizationHeaderMalformed)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := idp.GetIdentity(provider, auth.Credentials.AccessKeyID, r.Header.Get(xAmzSecurityToken))
 	if err != nil {
 		return nil, err
 	}
 
-	payloadShaHex, err := payloadSha256Hex(r, r.Header.Get(xAmzContextSha256))
+	contentSha256 := r.Header.Get(xAmzContextSha256)
+	if contentSha256 == "" && auth.Credentials.Service != "s3" {
+		contentSha256, err = readPayloadSha256Hex(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	payloadShaHex, err := payloadSha256Hex(r, contentSha256)
 	if err != nil {
 		return nil, err
 	}
 
 	var (
 		req       = awsV4CanonicalRequest(r, payloadShaHex, auth.SignedHeaders)
-		signature = sumHmacSha256(s.computeSigningKey(at, auth.Credentials.Region, identity), s.computeStringToSign(at, auth.Credentials.Region, req))
+		signature = sumHmacSha256(s.computeSigningKey(at, auth.Credentials.Region, auth.Credentials.Service, identity), s.computeStringToSign(at, auth.Credentials.Region, auth.Credentials.Service, req))
 	)
 
 	// Check request signature is equal to the computed signature
@@ -260,6 +415,21 @@ func (s SignAWSV4) VerifyHeaders(r *http.Request, provider idp.Provider) (*idp.I
 		}
 	}
 
+	// An exact replay of the request is rejected until the request time is outside the skew window
+	if s.Replay != nil && s.Replay.Seen(hex.EncodeToString(signature), at.Add(s.maxSkew()), s.now()) {
+		return nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "The request signature has already been used.",
+		}
+	}
+
+	if isStreamingPayload(contentSha256) {
+		err = s.decodeStreamingPayload(r, contentSha256, at, auth.Credentials, identity, signature)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return identity, nil
 }
 
@@ -299,13 +469,25 @@ func (s SignAWSV4) VerifyQuery(r *http.Request, provider idp.Provider) (*idp.Ide
 		}
 	}
 
+	if at.After(s.now().Add(s.maxSkew())) {
+		return nil, &exception.Error{
+			ErrorCode: exception.AccessDenied,
+			Message:   "Request is not valid yet.",
+		}
+	}
+
 	// Parse credentials from the credential parameter
 	credential, err := ParseCredential(q.Get(xAmzCredential))
 	if err != nil {
 		return nil, err
 	}
 
-	identity, err := provider.Get(credential.AccessKeyID)
+	err = checkCredentialScope(*credential, at, exception.InvalidSecurity)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := idp.GetIdentity(provider, credential.AccessKeyID, q.Get(xAmzSecurityToken))
 	if err != nil {
 		return nil, err
 	}
@@ -322,7 +504,7 @@ func (s SignAWSV4) VerifyQuery(r *http.Request, provider idp.Provider) (*idp.Ide
 
 	var (
 		req       = awsV4CanonicalRequest(r, payloadShaHex, q[xAmzSignedHeaders])
-		signature = sumHmacSha256(s.computeSigningKey(at, credential.Region, identity), s.computeStringToSign(at, credential.Region, req))
+		signature = sumHmacSha256(s.computeSigningKey(at, credential.Region, credential.Service, identity), s.computeStringToSign(at, credential.Region, credential.Service, req))
 	)
 
 	// Check request signature is equal to the computed signature
@@ -333,9 +515,44 @@ func (s SignAWSV4) VerifyQuery(r *http.Request, provider idp.Provider) (*idp.Ide
 		}
 	}
 
+	if contentSha256 := q.Get(xAmzContextSha256); isStreamingPayload(contentSha256) {
+		err = s.decodeStreamingPayload(r, contentSha256, at, *credential, identity, signature)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return identity, nil
 }
 
+// signedCredential returns the credential scope of a request signed using Signature Version 4.
+func signedCredential(r *http.Request) (Credential, bool) {
+	if q := r.URL.Query(); q.Get(xAmzSignature) != "" {
+		parsed, err := ParseCredential(q.Get(xAmzCredential))
+		if err != nil {
+			return Credential{}, false
+		}
+		return *parsed, true
+	}
+
+	hdr := r.Header.Get("Authorization")
+	if !strings.HasPrefix(hdr, awsSignatureVersionV4+" ") {
+		return Credential{}, false
+	}
+
+	auth, err := ParseAuthorizationHeader(hdr)
+	if err != nil {
+		return Credential{}, false
+	}
+
+	return auth.Credentials, true
+}
+
+// IsSigned returns true if the request is signed using Signature Version 4.
+func (s SignAWSV4) IsSigned(r *http.Request) bool {
+	return r.URL.Query().Get(xAmzSignature) != "" || strings.HasPrefix(r.Header.Get("Authorization"), awsSignatureVersionV4+" ")
+}
+
 func (s SignAWSV4) Verify(r *http.Request, provider idp.Provider) (*idp.Identity, error) {
 	// If X-Amz-Signature is provided in the request URL query then use query parameter based authorization
 	if r.URL.Query().Get(xAmzSignature) != "" {
@@ -0,0 +1,405 @@
+package ls3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	amzStreamingPayload                = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	amzStreamingPayloadTrailer         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	amzStreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	amzChunkedEncoding         = "aws-chunked"
+	xAmzDecodedContentLength   = "X-Amz-Decoded-Content-Length"
+	xAmzTrailer                = "X-Amz-Trailer"
+	amzTrailerSignature        = "x-amz-trailer-signature"
+	awsSignatureV4Payload      = "AWS4-HMAC-SHA256-PAYLOAD"
+	awsSignatureV4Trailer      = "AWS4-HMAC-SHA256-TRAILER"
+	emptySha256Hex             = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxChunkedPayloadLineBytes = 4096
+)
+
+// isStreamingPayload returns true if the value of x-amz-content-sha256 is for an aws-chunked payload.
+func isStreamingPayload(contentSha256 string) bool {
+	switch contentSha256 {
+	case amzStreamingPayload, amzStreamingPayloadTrailer, amzStreamingUnsignedPayloadTrailer:
+		return true
+	default:
+		return false
+	}
+}
+
+// newTrailingChecksum returns the hash of the trailing checksum header, such as x-amz-checksum-crc32.
+func newTrailingChecksum(name string) (hash.Hash, bool) {
+	switch strings.ToLower(name) {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE(), true
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), true
+	case "x-amz-checksum-sha1":
+		return sha1.New(), true
+	case "x-amz-checksum-sha256":
+		return sha256.New(), true
+	default:
+		return nil, false
+	}
+}
+
+var errInvalidChunk = &exception.Error{
+	ErrorCode: exception.InvalidChunkSizeError,
+	Message:   "The chunked payload of the request is not valid.",
+}
+
+var errChunkSignatureDoesNotMatch = &exception.Error{
+	ErrorCode: exception.SignatureDoesNotMatch,
+	Message:   "The chunk signature that the server calculated does not match the signature that you provided.",
+}
+
+// chunkedPayloadReader decodes an aws-chunked request body as it is read.
+// If the payload is signed, the signature of each chunk is verified once the chunk has been read,
+// so handlers must read the body to the end before acting on it.
+type chunkedPayloadReader struct {
+	io.Closer
+	r *bufio.Reader
+
+	// signingKey is the signing key of the request, or nil if the chunks are not signed.
+	signingKey []byte
+	// stringToSignPrefix is the timestamp and scope of the request, used in the string to sign of each chunk.
+	stringToSignPrefix string
+	// signature is the signature of the previous chunk, or the seed signature of the request.
+	signature []byte
+
+	// trailer is the name of the trailing checksum header, or empty if the payload has no trailer.
+	trailer  string
+	checksum hash.Hash
+
+	chunkHash      hash.Hash
+	chunkSignature []byte
+	remaining      int64
+	inChunk        bool
+
+	decodedLength  int64
+	expectedLength int64
+
+	err error
+}
+
+// readLine reads a line ending with CRLF, without the CRLF.
+func (c *chunkedPayloadReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == io.EOF {
+		return "", &exception.Error{
+			ErrorCode: exception.IncompleteBody,
+			Message:   "The request body terminated unexpectedly.",
+		}
+	}
+	if err == bufio.ErrBufferFull {
+		return "", errInvalidChunk
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", errInvalidChunk
+	}
+
+	return string(line[:len(line)-2]), nil
+}
+
+// verify verifies the signature of a chunk or the trailer against the previous signature.
+func (c *chunkedPayloadReader) verify(algorithm string, hashes string, signature []byte) error {
+	if c.signingKey == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString(algorithm)
+	b.WriteRune('\n')
+	b.WriteString(c.stringToSignPrefix)
+	hex.NewEncoder(&b).Write(c.signature)
+	b.WriteRune('\n')
+	b.WriteString(hashes)
+
+	computed := sumHmacSha256(c.signingKey, b.Bytes())
+	if subtle.ConstantTimeCompare(computed, signature) != 1 {
+		return errChunkSignatureDoesNotMatch
+	}
+
+	c.signature = computed
+	return nil
+}
+
+// verifyChunk verifies the signature of the chunk that has just been read.
+func (c *chunkedPayloadReader) verifyChunk() error {
+	return c.verify(awsSignatureV4Payload, emptySha256Hex+"\n"+hex.EncodeToString(c.chunkHash.Sum(nil)), c.chunkSignature)
+}
+
+// readTrailer reads and verifies the trailing headers after the final chunk.
+func (c *chunkedPayloadReader) readTrailer() error {
+	var (
+		trailer   bytes.Buffer
+		signature []byte
+		values    = make(map[string]string)
+	)
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errInvalidChunk
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		if name == amzTrailerSignature {
+			signature, err = hex.DecodeString(value)
+			if err != nil {
+				return errChunkSignatureDoesNotMatch
+			}
+			continue
+		}
+
+		values[name] = value
+
+		trailer.WriteString(name)
+		trailer.WriteRune(':')
+		trailer.WriteString(value)
+		trailer.WriteRune('\n')
+	}
+
+	if c.signingKey != nil {
+		sum := sha256.Sum256(trailer.Bytes())
+		if err := c.verify(awsSignatureV4Trailer, hex.EncodeToString(sum[:]), signature); err != nil {
+			return err
+		}
+	}
+
+	value, ok := values[c.trailer]
+	if !ok {
+		return &exception.Error{
+			ErrorCode: exception.InvalidRequest,
+			Message:   "The request is missing the trailing header " + c.trailer + ".",
+		}
+	}
+
+	if value != base64.StdEncoding.EncodeToString(c.checksum.Sum(nil)) {
+		return &exception.Error{
+			ErrorCode: exception.BadDigest,
+			Message:   "The " + c.trailer + " you specified did not match the calculated checksum.",
+		}
+	}
+
+	return nil
+}
+
+// nextChunk finishes the current chunk and reads the header of the next chunk.
+// It returns io.EOF once the final chunk and any trailer have been read.
+func (c *chunkedPayloadReader) nextChunk() error {
+	if c.inChunk {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "" {
+			return errInvalidChunk
+		}
+
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+
+		c.inChunk = false
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeHex, extension, _ := strings.Cut(line, ";")
+
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 {
+		return errInvalidChunk
+	}
+
+	c.chunkHash.Reset()
+	c.chunkSignature = nil
+
+	if c.signingKey != nil {
+		signature, ok := cutPrefix(extension, "chunk-signature=")
+		if !ok {
+			return errInvalidChunk
+		}
+
+		c.chunkSignature, err = hex.DecodeString(signature)
+		if err != nil {
+			return errChunkSignatureDoesNotMatch
+		}
+	}
+
+	if size > 0 {
+		c.remaining = size
+		c.inChunk = true
+		return nil
+	}
+
+	// The final chunk has no data
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+
+	if c.trailer != "" {
+		err = c.readTrailer()
+	} else {
+		line, err = c.readLine()
+		if err == nil && line != "" {
+			err = errInvalidChunk
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.expectedLength >= 0 && c.decodedLength != c.expectedLength {
+		return &exception.Error{
+			ErrorCode: exception.IncompleteBody,
+			Message:   "You did not provide the number of bytes specified by the x-amz-decoded-content-length HTTP header.",
+		}
+	}
+
+	return io.EOF
+}
+
+func (c *chunkedPayloadReader) Read(b []byte) (int, error) {
+	for c.err == nil && c.remaining == 0 {
+		c.err = c.nextChunk()
+	}
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if int64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.r.Read(b)
+	c.chunkHash.Write(b[:n])
+	if c.checksum != nil {
+		c.checksum.Write(b[:n])
+	}
+
+	c.remaining -= int64(n)
+	c.decodedLength += int64(n)
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	if n > 0 {
+		return n, nil
+	}
+
+	return n, err
+}
+
+// cutPrefix returns s without prefix, and whether s started with prefix.
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+
+	return s[len(prefix):], true
+}
+
+// decodeStreamingPayload replaces the aws-chunked body of the request with a reader of the decoded payload.
+// If the payload is signed, seedSignature is the signature of the request,
+// and the signature of each chunk is verified with the signing key of the request.
+func (s SignAWSV4) decodeStreamingPayload(r *http.Request, contentSha256 string, at time.Time, credential Credential, identity *idp.Identity, seedSignature []byte) error {
+	reader := &chunkedPayloadReader{
+		Closer:         r.Body,
+		r:              bufio.NewReaderSize(r.Body, maxChunkedPayloadLineBytes),
+		signature:      seedSignature,
+		chunkHash:      sha256.New(),
+		expectedLength: -1,
+	}
+
+	if contentSha256 != amzStreamingUnsignedPayloadTrailer {
+		reader.signingKey = s.computeSigningKey(at, credential.Region, credential.Service, identity)
+		reader.stringToSignPrefix = at.Format(amzDateTimeFormat) + "\n" +
+			at.Format(amzDateFormat) + "/" + credential.Region + "/" + credential.Service + "/aws4_request\n"
+	}
+
+	if contentSha256 != amzStreamingPayload {
+		reader.trailer = strings.ToLower(strings.TrimSpace(r.Header.Get(xAmzTrailer)))
+
+		var ok bool
+		reader.checksum, ok = newTrailingChecksum(reader.trailer)
+		if !ok {
+			return &exception.Error{
+				ErrorCode: exception.InvalidRequest,
+				Message:   "The value of x-amz-trailer is not a supported checksum.",
+			}
+		}
+	}
+
+	if v := r.Header.Get(xAmzDecodedContentLength); v != "" {
+		length, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || length < 0 {
+			return &exception.Error{
+				ErrorCode: exception.InvalidArgument,
+				Message:   "Invalid value for x-amz-decoded-content-length.",
+			}
+		}
+
+		reader.expectedLength = length
+		r.ContentLength = length
+	} else {
+		r.ContentLength = -1
+	}
+
+	// aws-chunked is not a content encoding of the object
+	var encodings []string
+	for _, encoding := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != amzChunkedEncoding {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	if len(encodings) > 0 {
+		r.Header.Set("Content-Encoding", strings.Join(encodings, ","))
+	} else {
+		r.Header.Del("Content-Encoding")
+	}
+
+	r.Body = reader
+	return nil
+}
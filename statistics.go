@@ -62,4 +62,47 @@ var (
 			"client_ip",
 		},
 	)
+	statBytesTransferredIn = promauto.With(StatRegistry).NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ls3",
+			Name:      "bytes_transferred_in",
+			Help:      "Number of bytes transferred in by PutObject",
+		},
+		[]string{
+			"bucket",
+			"object",
+			"identity",
+			"client_ip",
+		},
+	)
+	statLockouts = promauto.With(StatRegistry).NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ls3",
+			Subsystem: "security",
+			Name:      "lockouts",
+			Help:      "Total count of client IPs and access key ids locked out after failed signature verifications",
+		},
+		[]string{
+			"kind",
+		},
+	)
+	statLockoutRejections = promauto.With(StatRegistry).NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ls3",
+			Subsystem: "security",
+			Name:      "lockout_rejections",
+			Help:      "Total count of requests rejected because their client IP or access key id is locked out",
+		},
+		[]string{
+			"kind",
+		},
+	)
+	statLockedOut = newLockoutCollector(StatRegistry, prometheus.NewDesc(
+		prometheus.BuildFQName("ls3", "security", "locked_out"),
+		"Number of client IPs and access key ids that are locked out",
+		[]string{
+			"kind",
+		},
+		nil,
+	))
 )
@@ -0,0 +1,366 @@
+package ls3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/relvacode/ls3/idp"
+	"go.uber.org/zap"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// bucketWebsiteFile is the name of the file, at the root of each bucket, that contains the website configuration of the bucket.
+const bucketWebsiteFile = reservedNamePrefix + "website.json"
+
+// IndexDocument is the object suffix that is served for a request to a directory of a website.
+type IndexDocument struct {
+	Suffix string
+}
+
+// ErrorDocument is the object that is served when a request to a website fails.
+type ErrorDocument struct {
+	Key string
+}
+
+// RedirectAllRequestsTo redirects every request to a website to another host.
+type RedirectAllRequestsTo struct {
+	HostName string
+	Protocol string `xml:",omitempty" json:",omitempty"`
+}
+
+// RoutingRuleCondition describes the requests that a RoutingRule applies to.
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `xml:",omitempty" json:",omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:",omitempty" json:",omitempty"`
+}
+
+// RoutingRuleRedirect describes where a RoutingRule redirects a request to.
+type RoutingRuleRedirect struct {
+	HostName             string `xml:",omitempty" json:",omitempty"`
+	HttpRedirectCode     string `xml:",omitempty" json:",omitempty"`
+	Protocol             string `xml:",omitempty" json:",omitempty"`
+	ReplaceKeyPrefixWith string `xml:",omitempty" json:",omitempty"`
+	ReplaceKeyWith       string `xml:",omitempty" json:",omitempty"`
+}
+
+// RoutingRule redirects requests to a website that match a condition.
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:",omitempty" json:",omitempty"`
+	Redirect  RoutingRuleRedirect
+}
+
+// WebsiteConfiguration is the website configuration of a bucket.
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"http://s3.amazonaws.com/doc/2006-03-01/ WebsiteConfiguration" json:"-"`
+	ErrorDocument         *ErrorDocument         `xml:",omitempty" json:",omitempty"`
+	IndexDocument         *IndexDocument         `xml:",omitempty" json:",omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:",omitempty" json:",omitempty"`
+	RoutingRules          []*RoutingRule         `xml:"RoutingRules>RoutingRule,omitempty" json:",omitempty"`
+}
+
+// matches returns true if the rule applies to a request for key that failed with the HTTP status code statusCode.
+// statusCode is zero before the object has been requested.
+func (rule *RoutingRule) matches(key string, statusCode int) bool {
+	if rule.Condition == nil {
+		return statusCode == 0
+	}
+
+	if !strings.HasPrefix(key, rule.Condition.KeyPrefixEquals) {
+		return false
+	}
+
+	if rule.Condition.HttpErrorCodeReturnedEquals == "" {
+		return statusCode == 0
+	}
+
+	return rule.Condition.HttpErrorCodeReturnedEquals == strconv.Itoa(statusCode)
+}
+
+// location returns the URL that a request for key is redirected to.
+// The redirect is relative to the current host unless the rule sets a host name or protocol.
+func (rule *RoutingRule) location(ctx *RequestContext, pathPrefix, key string) string {
+	switch {
+	case rule.Redirect.ReplaceKeyWith != "":
+		key = rule.Redirect.ReplaceKeyWith
+	case rule.Redirect.ReplaceKeyPrefixWith != "":
+		var prefix string
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+
+		key = rule.Redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	if rule.Redirect.HostName == "" && rule.Redirect.Protocol == "" {
+		return pathPrefix + "/" + key
+	}
+
+	return websiteURL(ctx, rule.Redirect.Protocol, rule.Redirect.HostName, key)
+}
+
+// statusCode returns the HTTP status code of the redirect of the rule.
+func (rule *RoutingRule) statusCode() int {
+	if code, err := strconv.Atoi(rule.Redirect.HttpRedirectCode); err == nil {
+		return code
+	}
+
+	return http.StatusMovedPermanently
+}
+
+// websiteURL returns the absolute URL of key on host.
+// The protocol and host of the request are used if protocol or host are empty.
+func websiteURL(ctx *RequestContext, protocol, host, key string) string {
+	if protocol == "" {
+		protocol = "http"
+		if ctx.Secure {
+			protocol = "https"
+		}
+	}
+
+	if host == "" {
+		host = ctx.Request.Host
+	}
+
+	return protocol + "://" + host + "/" + key
+}
+
+// readBucketWebsite reads the website configuration at the root of the bucket filesystem.
+// It returns nil if the bucket has no website configuration.
+func readBucketWebsite(fsys fs.FS) (*WebsiteConfiguration, error) {
+	b, err := readBucketConfig(fsys, bucketWebsiteFile)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	errInvalidWebsite := func(message string) error {
+		return &exception.Error{
+			ErrorCode: exception.InvalidBucketState,
+			Message:   message,
+		}
+	}
+
+	var config WebsiteConfiguration
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, errInvalidWebsite("The website configuration of this bucket is not valid.")
+	}
+
+	if config.RedirectAllRequestsTo != nil {
+		if config.RedirectAllRequestsTo.HostName == "" {
+			return nil, errInvalidWebsite("RedirectAllRequestsTo of this bucket must have a HostName.")
+		}
+
+		return &config, nil
+	}
+
+	if config.IndexDocument == nil || config.IndexDocument.Suffix == "" || strings.Contains(config.IndexDocument.Suffix, "/") {
+		return nil, errInvalidWebsite("The website configuration of this bucket must have an IndexDocument suffix, which may not contain a slash.")
+	}
+
+	for _, rule := range config.RoutingRules {
+		if rule == nil || rule.Redirect.ReplaceKeyWith != "" && rule.Redirect.ReplaceKeyPrefixWith != "" {
+			return nil, errInvalidWebsite("Each routing rule of this bucket may set only one of ReplaceKeyWith and ReplaceKeyPrefixWith.")
+		}
+
+		if code := rule.Redirect.HttpRedirectCode; code != "" {
+			if n, err := strconv.Atoi(code); err != nil || n < 300 || n > 399 {
+				return nil, errInvalidWebsite("The HttpRedirectCode of each routing rule of this bucket must be a 3XX status code.")
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+var websiteErrorTemplate = template.Must(template.New("error").Parse(`<html>
+<head><title>{{ .StatusCode }} {{ .Status }}</title></head>
+<body>
+<h1>{{ .StatusCode }} {{ .Status }}</h1>
+<ul>
+<li>Code: {{ .Code }}</li>
+<li>Message: {{ .Message }}</li>
+<li>RequestId: {{ .RequestID }}</li>
+</ul>
+<hr/>
+</body>
+</html>
+`))
+
+// sendWebsiteError replies to a website request with an HTML description of err.
+func sendWebsiteError(ctx *RequestContext, err *exception.Error) {
+	ctx.Error(err.Message, zap.String("err-code", err.Code), zap.Error(err))
+
+	statApiError.WithLabelValues(ctx.Identity.Name, ctx.RemoteIP.String(), err.Code).Add(1)
+
+	ctx.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	w := ctx.SendPlain(err.StatusCode)
+	if ctx.Request.Method == http.MethodHead {
+		return
+	}
+
+	_ = websiteErrorTemplate.Execute(w, map[string]any{
+		"StatusCode": err.StatusCode,
+		"Status":     http.StatusText(err.StatusCode),
+		"Code":       err.Code,
+		"Message":    err.Message,
+		"RequestID":  ctx.ID.String(),
+	})
+}
+
+// sendWebsiteErrorDocument replies to a website request that failed with err using the error document of the website.
+// It returns false if the error document cannot be read.
+func sendWebsiteErrorDocument(ctx *RequestContext, config *WebsiteConfiguration, err *exception.Error) bool {
+	if config.ErrorDocument == nil || config.ErrorDocument.Key == "" {
+		return false
+	}
+
+	key := config.ErrorDocument.Key
+
+	obj, statErr := stat(ctx, key)
+	if statErr != nil {
+		return false
+	}
+	defer obj.Close()
+
+	if err := ctx.CheckAccess(idp.GetObject, idp.Resource(ctx.Bucket+"/"+key), obj); err != nil {
+		return false
+	}
+
+	ctx.Error(err.Message, zap.String("err-code", err.Code), zap.Error(err))
+
+	statApiError.WithLabelValues(ctx.Identity.Name, ctx.RemoteIP.String(), err.Code).Add(1)
+
+	header := ctx.Header()
+	setMetadataHeaders(header, obj)
+	header.Set("Content-Length", strconv.Itoa(int(obj.Size)))
+
+	w := ctx.SendPlain(err.StatusCode)
+	if ctx.Request.Method != http.MethodHead {
+		_, _ = io.Copy(w, obj)
+	}
+
+	return true
+}
+
+// serveWebsite serves a request to the website endpoint of a bucket.
+// Website requests are always made by the public identity, and can only read objects.
+// Errors are returned as HTML, or as the error document of the website.
+func (s *Server) serveWebsite(ctx *RequestContext) {
+	r := ctx.Request
+
+	identity, err := s.identities.Get(idp.IdentityUnauthenticatedPublic)
+	if err != nil {
+		sendWebsiteError(ctx, exception.ErrorFrom(err))
+		return
+	}
+
+	ctx.Identity = identity
+	ctx.Logger = ctx.Logger.With(
+		zap.String("identity", ctx.Identity.Name),
+		zap.Bool("website", true),
+	)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		sendWebsiteError(ctx, &exception.Error{
+			ErrorCode: exception.MethodNotAllowed,
+			Message:   "The specified method is not allowed against this resource.",
+		})
+		return
+	}
+
+	// Redirects are relative to the path of the bucket, which is only part of the path for path style addressing
+	requestPath := r.URL.Path
+
+	bucket, ok, err := bucketFromRequest(r, s.websiteDomain)
+	if err == nil && !ok {
+		err = &exception.Error{
+			ErrorCode: exception.NoSuchBucket,
+			Message:   "The specified bucket does not exist.",
+		}
+	}
+	if err != nil {
+		sendWebsiteError(ctx, exception.ErrorFrom(err))
+		return
+	}
+
+	pathPrefix := strings.TrimSuffix(requestPath, r.URL.Path)
+
+	ctx.Bucket = bucket
+	ctx.Filesystem, err = s.filesystemProvider.Open(bucket)
+	if err != nil {
+		sendWebsiteError(ctx, exception.ErrorFrom(err))
+		return
+	}
+
+	config, err := readBucketWebsite(ctx.Filesystem)
+	if err == nil && config == nil {
+		err = &exception.Error{
+			ErrorCode: exception.NoSuchWebsiteConfiguration,
+			Message:   "The specified bucket does not have a website configuration.",
+		}
+	}
+	if err != nil {
+		sendWebsiteError(ctx, exception.ErrorFrom(err))
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		ctx.Header().Set("Location", websiteURL(ctx, redirect.Protocol, redirect.HostName, key))
+		ctx.SendPlain(http.StatusMovedPermanently)
+		return
+	}
+
+	for _, rule := range config.RoutingRules {
+		if rule.matches(key, 0) {
+			ctx.Header().Set("Location", rule.location(ctx, pathPrefix, key))
+			ctx.SendPlain(rule.statusCode())
+			return
+		}
+	}
+
+	objectKey := key
+	if objectKey == "" || strings.HasSuffix(objectKey, "/") {
+		objectKey += config.IndexDocument.Suffix
+	} else if fi, err := fs.Stat(ctx.Filesystem, objectKey); err == nil && fi.IsDir() {
+		// A request for a directory without a trailing slash is redirected to the directory, if it has an index document
+		if _, err := fs.Stat(ctx.Filesystem, path.Join(objectKey, config.IndexDocument.Suffix)); err == nil {
+			ctx.Header().Set("Location", pathPrefix+"/"+objectKey+"/")
+			ctx.SendPlain(http.StatusFound)
+			return
+		}
+	}
+
+	r.URL.Path = "/" + objectKey
+
+	var requestErr *exception.Error
+	if r.Method == http.MethodHead {
+		requestErr = s.HeadObject(ctx)
+	} else {
+		requestErr = s.GetObject(ctx)
+	}
+
+	if requestErr == nil {
+		return
+	}
+
+	for _, rule := range config.RoutingRules {
+		if rule.matches(key, requestErr.StatusCode) {
+			ctx.Header().Set("Location", rule.location(ctx, pathPrefix, key))
+			ctx.SendPlain(rule.statusCode())
+			return
+		}
+	}
+
+	if !sendWebsiteErrorDocument(ctx, config, requestErr) {
+		sendWebsiteError(ctx, requestErr)
+	}
+}
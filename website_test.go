@@ -0,0 +1,142 @@
+package ls3
+
+import (
+	"encoding/xml"
+	"github.com/relvacode/ls3/exception"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testWebsiteServer returns a server that serves dir as a website on the domain website.testing.
+// If config is not empty, it is written as the website configuration of the bucket.
+func testWebsiteServer(t *testing.T, config string) *Server {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "index.html"), []byte("home"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("docs"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "404.html"), []byte("not found"), 0644)
+
+	if config != "" {
+		_ = os.WriteFile(filepath.Join(dir, bucketWebsiteFile), []byte(config), 0644)
+	}
+
+	srv := testServerFS(os.DirFS(dir))
+	srv.websiteDomain = []string{"website", "testing"}
+
+	return srv
+}
+
+func testWebsiteRequest(method, path string) *http.Request {
+	return httptest.NewRequest(method, "http://bucket.website.testing"+path, nil)
+}
+
+func TestServer_serveWebsite(t *testing.T) {
+	srv := testWebsiteServer(t, `{
+  "IndexDocument": {"Suffix": "index.html"},
+  "ErrorDocument": {"Key": "404.html"},
+  "RoutingRules": [
+    {
+      "Condition": {"KeyPrefixEquals": "old/"},
+      "Redirect": {"ReplaceKeyPrefixWith": "docs/"}
+    },
+    {
+      "Condition": {"KeyPrefixEquals": "external/", "HttpErrorCodeReturnedEquals": "404"},
+      "Redirect": {"HostName": "example.com", "Protocol": "https", "HttpRedirectCode": "302"}
+    }
+  ]
+}`)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{name: "root index", method: http.MethodGet, path: "/", code: http.StatusOK, body: "home"},
+		{name: "directory index", method: http.MethodGet, path: "/docs/", code: http.StatusOK, body: "docs"},
+		{name: "directory redirect", method: http.MethodGet, path: "/docs", code: http.StatusFound, location: "/docs/"},
+		{name: "object", method: http.MethodGet, path: "/docs/index.html", code: http.StatusOK, body: "docs"},
+		{name: "head", method: http.MethodHead, path: "/docs/", code: http.StatusOK},
+		{name: "error document", method: http.MethodGet, path: "/missing.html", code: http.StatusNotFound, body: "not found"},
+		{name: "routing rule", method: http.MethodGet, path: "/old/index.html", code: http.StatusMovedPermanently, location: "/docs/index.html"},
+		{name: "routing rule on error", method: http.MethodGet, path: "/external/page.html", code: http.StatusFound, location: "https://example.com/external/page.html"},
+		{name: "reserved", method: http.MethodGet, path: "/" + bucketWebsiteFile, code: http.StatusNotFound, body: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			srv.ServeHTTP(rw, testWebsiteRequest(tt.method, tt.path))
+
+			assert.Equal(t, tt.code, rw.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rw.Body.String())
+			}
+			assert.Equal(t, tt.location, rw.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_serveWebsite_Errors(t *testing.T) {
+	t.Run("no error document", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, `{"IndexDocument": {"Suffix": "index.html"}}`).ServeHTTP(rw, testWebsiteRequest(http.MethodGet, "/missing.html"))
+
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+		assert.Contains(t, rw.Body.String(), exception.NoSuchKey.Code)
+	})
+
+	t.Run("not a website", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, "").ServeHTTP(rw, testWebsiteRequest(http.MethodGet, "/"))
+
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Contains(t, rw.Body.String(), exception.NoSuchWebsiteConfiguration.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, `{"IndexDocument": {"Suffix": "index.html"}}`).ServeHTTP(rw, testWebsiteRequest(http.MethodPut, "/index.html"))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+	})
+
+	t.Run("redirect all requests", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, `{"RedirectAllRequestsTo": {"HostName": "example.com"}}`).ServeHTTP(rw, testWebsiteRequest(http.MethodGet, "/docs/"))
+
+		assert.Equal(t, http.StatusMovedPermanently, rw.Code)
+		assert.Equal(t, "http://example.com/docs/", rw.Header().Get("Location"))
+	})
+}
+
+func TestServer_GetBucketWebsite(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, `{"IndexDocument": {"Suffix": "index.html"}, "ErrorDocument": {"Key": "404.html"}}`).
+			ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "website", nil, nil))
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+
+		var config WebsiteConfiguration
+		assert.NoError(t, xml.Unmarshal(rw.Body.Bytes(), &config))
+		if assert.NotNil(t, config.IndexDocument) && assert.NotNil(t, config.ErrorDocument) {
+			assert.Equal(t, "index.html", config.IndexDocument.Suffix)
+			assert.Equal(t, "404.html", config.ErrorDocument.Key)
+		}
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		testWebsiteServer(t, "").ServeHTTP(rw, testSignedRequest(SignAWSV4{}, http.MethodGet, "/bucket/", "website", nil, nil))
+
+		AssertIsResponseError(t, rw, exception.NoSuchWebsiteConfiguration)
+	})
+}
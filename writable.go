@@ -0,0 +1,227 @@
+package ls3
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// A WritableFile is a new file created in a WritableFS.
+type WritableFile interface {
+	io.WriteCloser
+	// Name returns the path of the file within the filesystem.
+	Name() string
+	// Sync commits the contents of the file to stable storage.
+	Sync() error
+}
+
+// WritableFS is a filesystem that can be modified.
+// All paths are slash separated paths that must satisfy fs.ValidPath.
+type WritableFS interface {
+	fs.FS
+
+	// CreateTemp creates a new file in the directory dir with a random name created from pattern, see os.CreateTemp.
+	CreateTemp(dir, pattern string) (WritableFile, error)
+	// Rename renames oldpath to newpath, replacing newpath if it already exists as a file.
+	Rename(oldpath, newpath string) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// MkdirAll creates the directory name, along with any parent directories that do not exist.
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// DirFS is a writable filesystem rooted at a directory of the operating system.
+// Like os.DirFS, it does not prevent symbolic links within the directory from referring to files outside of it.
+type DirFS string
+
+var (
+	_ WritableFS = DirFS("")
+	_ fs.SubFS   = DirFS("")
+)
+
+func (dir DirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return filepath.Join(string(dir), filepath.FromSlash(name)), nil
+}
+
+func (dir DirFS) Open(name string) (fs.File, error) {
+	fullName, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fullName)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Sub returns the filesystem of a subdirectory, which is also writable.
+func (dir DirFS) Sub(name string) (fs.FS, error) {
+	fullName, err := dir.join("sub", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return DirFS(fullName), nil
+}
+
+type dirFile struct {
+	*os.File
+	name string
+}
+
+func (f *dirFile) Name() string {
+	return f.name
+}
+
+// CreateTemp creates a new temporary file in dir.
+// Unlike os.CreateTemp, the file is readable by others, as it is expected to be renamed to an object.
+func (dir DirFS) CreateTemp(name, pattern string) (WritableFile, error) {
+	fullName, err := dir.join("createtemp", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(fullName, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.Chmod(0644)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &dirFile{
+		File: f,
+		name: path.Join(name, filepath.Base(f.Name())),
+	}, nil
+}
+
+func (dir DirFS) Rename(oldpath, newpath string) error {
+	oldName, err := dir.join("rename", oldpath)
+	if err != nil {
+		return err
+	}
+
+	newName, err := dir.join("rename", newpath)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(oldName, newName)
+}
+
+func (dir DirFS) Remove(name string) error {
+	fullName, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(fullName)
+}
+
+func (dir DirFS) MkdirAll(name string, perm fs.FileMode) error {
+	fullName, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(fullName, perm)
+}
+
+// removeAll removes name and any children it contains.
+func removeAll(fsys WritableFS, name string) error {
+	var names []string
+	err := fs.WalkDir(fsys, name, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		names = append(names, p)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Children are always walked after their parent directory
+	for i := len(names) - 1; i >= 0; i-- {
+		err = fsys.Remove(names[i])
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uploadTempPattern is the pattern of temporary files created while writing an object.
+// Temporary files have a reserved name so that they are never listed as objects.
+const uploadTempPattern = reservedNamePrefix + "-upload-*"
+
+// writeTemp writes the contents of r to a new temporary file in dir, and returns the name of the file once it is synced to storage.
+// If verify is not nil, it is called after all data has been written and the temporary file is discarded if it returns an error.
+func writeTemp(fsys WritableFS, dir string, r io.Reader, verify func() error) (string, int64, error) {
+	err := fsys.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", 0, err
+	}
+
+	f, err := fsys.CreateTemp(dir, uploadTempPattern)
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := copyToFile(f, r)
+	if err == nil && verify != nil {
+		err = verify()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = fsys.Remove(f.Name())
+		return "", n, err
+	}
+
+	return f.Name(), n, nil
+}
+
+// writeAtomic writes the contents of r to name.
+// The data is first written to a temporary file in the same directory, which replaces name once it is synced to storage.
+// If verify is not nil, it is called after all data has been written and the temporary file is discarded if it returns an error.
+func writeAtomic(fsys WritableFS, name string, r io.Reader, verify func() error) (int64, error) {
+	tempName, n, err := writeTemp(fsys, path.Dir(name), r, verify)
+	if err != nil {
+		return n, err
+	}
+
+	err = fsys.Rename(tempName, name)
+	if err != nil {
+		_ = fsys.Remove(tempName)
+		return n, err
+	}
+
+	return n, nil
+}